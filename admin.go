@@ -0,0 +1,279 @@
+package dyocsp
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/yuxki/dyocsp/pkg/cache"
+	"github.com/yuxki/dyocsp/pkg/db"
+	"golang.org/x/crypto/ocsp"
+)
+
+// AdminPathPrefix is the prefix of the paths of the admin API.
+const AdminPathPrefix = "/admin/"
+
+var ErrEmptyAdminToken = errors.New("admin API token must not be empty")
+
+// Names of the revocation reasons, which are same as the values of the CA database.
+var revocationReasonNames = map[int]string{
+	ocsp.Unspecified:          db.UnspecifieValue,
+	ocsp.KeyCompromise:        db.KeyCompromisValue,
+	ocsp.CACompromise:         db.CACompromisValue,
+	ocsp.AffiliationChanged:   db.AffiliationChangeValue,
+	ocsp.Superseded:           db.SupersedeValue,
+	ocsp.CessationOfOperation: db.CessationOfOperatioValue,
+	ocsp.CertificateHold:      db.CertificateHolValue,
+	ocsp.RemoveFromCRL:        db.RemoveFromCRValue,
+	ocsp.PrivilegeWithdrawn:   db.PrivilegeWithdrawValue,
+	ocsp.AACompromise:         db.AACompromisValue,
+}
+
+// AdminHandler serves the admin API, which inspects the caches and refreshes
+// them out of the cycle of the batches. The requests must be authenticated
+// with the bearer token. The API is served under AdminPathPrefix.
+//
+//   - GET  /admin/caches: Lists the cache stores and their generations.
+//   - GET  /admin/caches/{ca}/responses/{serial}: Returns the cached response
+//     of the serial number in hex.
+//   - POST /admin/caches/{ca}/refresh: Refreshes the caches of the CA
+//     immediately with dyocsp.CacheBatch.Refresh, and returns the cache store.
+type AdminHandler struct {
+	tokenHash [sha256.Size]byte
+	batches   []*CacheBatch
+	mux       *http.ServeMux
+	logger    *zerolog.Logger
+}
+
+// AdminHandlerOption is type of an functional option for dyocsp.AdminHandler.
+type AdminHandlerOption func(*AdminHandler)
+
+// WithAdminCacheBatch adds the batch of a CA, whose caches are inspected and
+// refreshed by the admin API.
+func WithAdminCacheBatch(batch *CacheBatch) func(*AdminHandler) {
+	return func(h *AdminHandler) {
+		h.batches = append(h.batches, batch)
+	}
+}
+
+// WithAdminLogger sets logger. If not set, global logger is used.
+func WithAdminLogger(logger *zerolog.Logger) func(*AdminHandler) {
+	return func(h *AdminHandler) {
+		h.logger = logger
+	}
+}
+
+// NewAdminHandler creates a new instance of dyocsp.AdminHandler. The token
+// is the bearer token that authenticates the requests, and must not be empty.
+func NewAdminHandler(token []byte, opts ...AdminHandlerOption) (*AdminHandler, error) {
+	if len(token) == 0 {
+		return nil, ErrEmptyAdminToken
+	}
+
+	handler := AdminHandler{
+		// The hashes are compared, so that the length of the token is not leaked
+		tokenHash: sha256.Sum256(token),
+	}
+
+	for _, opt := range opts {
+		opt(&handler)
+	}
+
+	if handler.logger == nil {
+		handler.logger = &log.Logger
+	}
+
+	handler.mux = http.NewServeMux()
+	handler.mux.HandleFunc("GET "+AdminPathPrefix+"caches", handler.listCaches)
+	handler.mux.HandleFunc("GET "+AdminPathPrefix+"caches/{ca}/responses/{serial}", handler.getResponse)
+	handler.mux.HandleFunc("POST "+AdminPathPrefix+"caches/{ca}/refresh", handler.refresh)
+
+	return &handler, nil
+}
+
+// adminGeneration is the generation of the caches in the body of the responses.
+type adminGeneration struct {
+	BatchSerial int       `json:"batch_serial"`
+	Refreshes   int       `json:"refreshes"`
+	ThisUpdate  time.Time `json:"this_update"`
+	NextUpdate  time.Time `json:"next_update"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// adminCacheStore is the state of a cache store in the body of the responses.
+type adminCacheStore struct {
+	cacheHealth
+	Generation *adminGeneration `json:"generation,omitempty"`
+}
+
+// adminResponse is the cached response of a serial number in the body of
+// the responses.
+type adminResponse struct {
+	CA               string     `json:"ca"`
+	Serial           string     `json:"serial"`
+	Subject          string     `json:"subject,omitempty"`
+	Status           string     `json:"status"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	RevocationReason string     `json:"revocation_reason,omitempty"`
+	ThisUpdate       time.Time  `json:"this_update"`
+	NextUpdate       time.Time  `json:"next_update"`
+	ProducedAt       time.Time  `json:"produced_at"`
+	ETag             string     `json:"etag"`
+}
+
+// adminError is the body of the error responses.
+type adminError struct {
+	Error string `json:"error"`
+}
+
+func newAdminCacheStore(batch *CacheBatch) adminCacheStore {
+	stats := batch.cacheStore.Stats()
+	store := adminCacheStore{
+		cacheHealth: cacheHealth{CA: batch.CA(), Entries: stats.Entries, UpdatedAt: stats.UpdatedAt},
+	}
+	if !stats.OldestNextUpdate.IsZero() {
+		oldest := stats.OldestNextUpdate
+		store.OldestNextUpdate = &oldest
+	}
+
+	if gen, ok := batch.Generation(); ok {
+		store.Generation = &adminGeneration{
+			BatchSerial: gen.BatchSerial,
+			Refreshes:   gen.Refreshes,
+			ThisUpdate:  gen.ThisUpdate,
+			NextUpdate:  gen.NextUpdate,
+			GeneratedAt: gen.GeneratedAt,
+		}
+	}
+
+	return store
+}
+
+func newAdminResponse(ca string, resCache *cache.ResponseCache) adminResponse {
+	tmpl := resCache.Template()
+	res := adminResponse{
+		CA:         ca,
+		Serial:     tmpl.SerialNumber.Text(db.SerialBase),
+		Subject:    resCache.Entry().Subject,
+		Status:     certStatusLabels[tmpl.Status],
+		ThisUpdate: tmpl.ThisUpdate,
+		NextUpdate: tmpl.NextUpdate,
+		ProducedAt: tmpl.ProducedAt,
+		ETag:       entityTag(resCache),
+	}
+	if tmpl.Status == ocsp.Revoked {
+		revokedAt := tmpl.RevokedAt
+		res.RevokedAt = &revokedAt
+		res.RevocationReason = revocationReasonNames[tmpl.RevocationReason]
+	}
+
+	return res
+}
+
+func (h *AdminHandler) writeJSON(w http.ResponseWriter, code int, body any) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if _, err := w.Write(b); err != nil {
+		h.logger.Error().Err(err).Msg("")
+	}
+}
+
+func (h *AdminHandler) writeError(w http.ResponseWriter, code int, msg string) {
+	h.writeJSON(w, code, adminError{Error: msg})
+}
+
+func (h *AdminHandler) batch(w http.ResponseWriter, r *http.Request) (*CacheBatch, bool) {
+	ca := r.PathValue("ca")
+	for _, batch := range h.batches {
+		if batch.CA() == ca {
+			return batch, true
+		}
+	}
+
+	h.writeError(w, http.StatusNotFound, "unknown CA: "+ca)
+	return nil, false
+}
+
+func (h *AdminHandler) listCaches(w http.ResponseWriter, _ *http.Request) {
+	stores := make([]adminCacheStore, 0, len(h.batches))
+	for _, batch := range h.batches {
+		stores = append(stores, newAdminCacheStore(batch))
+	}
+
+	h.writeJSON(w, http.StatusOK, stores)
+}
+
+func (h *AdminHandler) getResponse(w http.ResponseWriter, r *http.Request) {
+	batch, ok := h.batch(w, r)
+	if !ok {
+		return
+	}
+
+	serial, ok := new(big.Int).SetString(r.PathValue("serial"), db.SerialBase)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid serial number: "+r.PathValue("serial"))
+		return
+	}
+
+	resCache, ok := batch.cacheStore.Get(serial)
+	if !ok {
+		h.writeError(w, http.StatusNotFound, "no cached response: "+serial.Text(db.SerialBase))
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, newAdminResponse(batch.CA(), resCache))
+}
+
+func (h *AdminHandler) refresh(w http.ResponseWriter, r *http.Request) {
+	batch, ok := h.batch(w, r)
+	if !ok {
+		return
+	}
+
+	h.logger.Info().Str("ca", batch.CA()).Str("ip", r.RemoteAddr).Msg("Out-of-cycle cache refresh requested.")
+	if err := batch.Refresh(r.Context()); err != nil {
+		h.logger.Error().Err(err).Str("ca", batch.CA()).Msg("Out-of-cycle cache refresh failed.")
+		h.writeError(w, http.StatusInternalServerError, "refresh failed: "+err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, newAdminCacheStore(batch))
+}
+
+// authenticated verifies the bearer token of the request (RFC 6750: 2.1.).
+func (h *AdminHandler) authenticated(r *http.Request) bool {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+
+	hash := sha256.Sum256([]byte(token))
+	return subtle.ConstantTimeCompare(hash[:], h.tokenHash[:]) == 1
+}
+
+// ServeHTTP serves the admin API. The requests without the valid bearer token
+// are responded with 401 Unauthorized.
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="dyocsp-admin"`)
+		h.writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	h.mux.ServeHTTP(w, r)
+}
@@ -0,0 +1,128 @@
+package dyocsp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yuxki/dyocsp/pkg/db"
+)
+
+func TestNewAdminHandler_EmptyToken(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewAdminHandler(nil); !errors.Is(err, ErrEmptyAdminToken) {
+		t.Errorf("Expected error is %v but got: %v", ErrEmptyAdminToken, err)
+	}
+}
+
+func TestAdminHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	entries := append(testIntermidiateEntries("01"), db.IntermidiateEntry{
+		Ca:        "test-ca",
+		Serial:    "02",
+		RevType:   "R",
+		ExpDate:   "330925234911Z",
+		RevDate:   "230826234911Z",
+		CRLReason: "keyCompromise",
+		Subject:   "/CN=revoked",
+	})
+	client := &StubScriptedCADBClient{scans: [][]db.IntermidiateEntry{
+		entries,
+		append(entries, testIntermidiateEntries("03")...),
+	}}
+	batch, stop := testRunBatchUntilUpdated(t, client, testCreateDelegatedResponder(t))
+	defer stop()
+
+	handler, err := NewAdminHandler([]byte("secret"), WithAdminCacheBatch(batch))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The subtests are not parallel, because the refresh changes the caches
+	data := []struct {
+		testcase string
+		method   string
+		path     string
+		token    string
+		// want
+		code int
+		body map[string]any
+	}{
+		{"no token", http.MethodGet, "/admin/caches", "", http.StatusUnauthorized, nil},
+		{"wrong token", http.MethodGet, "/admin/caches", "Bearer wrong", http.StatusUnauthorized, nil},
+		{"list caches", http.MethodGet, "/admin/caches", "Bearer secret", http.StatusOK, nil},
+		{
+			"revoked response", http.MethodGet, "/admin/caches/test-ca/responses/02", "Bearer secret",
+			http.StatusOK, map[string]any{"status": "revoked", "revocation_reason": "keyCompromise", "subject": "/CN=revoked"},
+		},
+		{
+			"good response", http.MethodGet, "/admin/caches/test-ca/responses/01", "bearer secret",
+			http.StatusOK, map[string]any{"status": "good", "serial": "1"},
+		},
+		{"no response", http.MethodGet, "/admin/caches/test-ca/responses/03", "Bearer secret", http.StatusNotFound, nil},
+		{"invalid serial", http.MethodGet, "/admin/caches/test-ca/responses/zz", "Bearer secret", http.StatusBadRequest, nil},
+		{"unknown CA", http.MethodGet, "/admin/caches/other/responses/01", "Bearer secret", http.StatusNotFound, nil},
+		{"refresh by GET", http.MethodGet, "/admin/caches/test-ca/refresh", "Bearer secret", http.StatusMethodNotAllowed, nil},
+		{
+			"refresh", http.MethodPost, "/admin/caches/test-ca/refresh", "Bearer secret",
+			http.StatusOK, map[string]any{"ca": "test-ca", "entries": float64(3)},
+		},
+		{
+			"refreshed response", http.MethodGet, "/admin/caches/test-ca/responses/03", "Bearer secret",
+			http.StatusOK, map[string]any{"status": "good"},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			req := httptest.NewRequest(d.method, d.path, nil)
+			if d.token != "" {
+				req.Header.Set("Authorization", d.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != d.code {
+				t.Fatalf("Expected status code is %d but got: %d: %s", d.code, rec.Code, rec.Body.String())
+			}
+			if d.code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("WWW-Authenticate header must be set.")
+			}
+
+			for key, want := range d.body {
+				var body map[string]any
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				if body[key] != want {
+					t.Errorf("Expected %s is %v but got: %v", key, want, body[key])
+				}
+			}
+		})
+	}
+
+	t.Run("generation", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/admin/caches", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var stores []adminCacheStore
+		if err := json.Unmarshal(rec.Body.Bytes(), &stores); err != nil {
+			t.Fatal(err)
+		}
+		if len(stores) != 1 || stores[0].Generation == nil {
+			t.Fatalf("Unexpected cache stores: %s", rec.Body.String())
+		}
+		if stores[0].Generation.Refreshes != 1 {
+			t.Errorf("Expected refreshes is 1 but got: %d", stores[0].Generation.Refreshes)
+		}
+		if stores[0].OldestNextUpdate == nil || !stores[0].OldestNextUpdate.Equal(stores[0].Generation.NextUpdate) {
+			t.Errorf("Expected oldest Next Update is the Next Update of the generation: %s", rec.Body.String())
+		}
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -16,14 +18,18 @@ import (
 
 type expirationLogger struct {
 	Logger zerolog.Logger
+	// Called for each expired entry with the action (dropped or warned)
+	expired func(action string)
 }
 
 func (e *expirationLogger) InvalidMsg(serial string, msg string) {
 	e.Logger.Warn().Msg(fmt.Sprintf("%s: %s", msg, serial))
+	e.expired(expiredDropped)
 }
 
 func (e *expirationLogger) WarnMsg(serial *big.Int, msg string) {
 	e.Logger.Warn().Msg(fmt.Sprintf("%s: %s", msg, serial.Text(db.SerialBase)))
+	e.expired(expiredWarned)
 }
 
 // CADBClient is an interface that represents a client for scanning a database
@@ -32,14 +38,16 @@ type CADBClient interface {
 	Scan(ctx context.Context) ([]db.IntermidiateEntry, error)
 }
 
-func createExpirationLogger(expiration expBehavior, logger zerolog.Logger) *db.ExpirationControl {
-	expLogger := expirationLogger{Logger: logger}
+func createExpirationLogger(
+	expiration expBehavior, logger zerolog.Logger, expired func(action string),
+) *db.ExpirationControl {
+	expLogger := expirationLogger{Logger: logger, expired: expired}
 	var expCtl *db.ExpirationControl
 	switch expiration {
 	case Warn:
 		expCtl = db.NewExpirationControl(db.WithWarnOnExpiration(), db.WithLogger(&expLogger))
 	case Invalid:
-		expCtl = db.NewExpirationControl()
+		expCtl = db.NewExpirationControl(db.WithLogger(&expLogger))
 	}
 
 	return expCtl
@@ -64,14 +72,61 @@ type CacheBatch struct {
 	delay         time.Duration
 	strict        bool
 	expiration    expBehavior
-	quite         chan string
+	scanFailure   scanFailureBehavior
+	dropThreshold int
+	snapshotFile  string
+	workers       int
+	changeFeed    CADBChangeFeed
+	watcher       CADBWatcher
 	updatedNotify chan struct{}
 	logger        *zerolog.Logger
+	metrics       *Metrics
+	// Entries of the last successful generation
+	lastEntries []db.CertificateEntry
+	// Durations of each phase of the current batch
+	timings batchTimings
+	// Next Update of the caches that are being served
+	servedNextUpdate time.Time
+	// Whether the last RunOnce kept the caches of the previous generation
+	keptCaches bool
+	// Wait before watching the change feed or the watcher again after it failed
+	changeFeedRetry time.Duration
+	// Wait before writing the snapshot after the changes from the change feed
+	snapshotDebounce time.Duration
+	// Guards the generation of caches from the batch, the change feed and
+	// the out-of-cycle refreshes
+	genMu sync.Mutex
+	// The generation of the caches that are being served
+	generation atomic.Pointer[Generation]
+}
+
+// Generation is the metadata of the generation of the caches that are being
+// served by dyocsp.CacheBatch.
+type Generation struct {
+	// Serial of the batch that generated the caches
+	BatchSerial int
+	// The number of the out-of-cycle refreshes since the batch
+	Refreshes   int
+	ThisUpdate  time.Time
+	NextUpdate  time.Time
+	GeneratedAt time.Time
+}
+
+// batchTimings holds the durations of each phase of a batch.
+type batchTimings struct {
+	scan   time.Duration
+	parse  time.Duration
+	sign   time.Duration
+	update time.Duration
 }
 
 // Default values.
 const (
-	DefaultInterval = 60
+	DefaultInterval        = 60
+	DefaultWorkers         = 1
+	DefaultChangeFeedRetry = 10 * time.Second
+	// The changes in the debounce are written to the snapshot at once
+	DefaultSnapshotDebounce = 10 * time.Second
 )
 
 type expBehavior int
@@ -82,7 +137,30 @@ const (
 	Invalid
 )
 
-var ErrDelayExceedsInterval = errors.New("delay must be less than interval or equal")
+type scanFailureBehavior int
+
+const (
+	// Keep the previous caches until their Next Update is past.
+	KeepCaches scanFailureBehavior = iota
+	// Re-sign the entries of the previous generation with the new Next Update.
+	ResignCaches
+)
+
+var (
+	ErrDelayExceedsInterval = errors.New("delay must be less than interval or equal")
+	ErrDropThresholdRange   = errors.New("drop threshold must be between 0 and 100")
+)
+
+type entryCountDropError struct {
+	prev    int
+	current int
+}
+
+func (e entryCountDropError) Error() string {
+	return fmt.Sprintf(
+		"entry count dropped from %d to %d, which exceeds the drop threshold", e.prev, e.current,
+	)
+}
 
 // CacheBatchOption is type of an functional option for dyocsp.CacheBatch.
 type CacheBatchOption func(*CacheBatch)
@@ -123,19 +201,70 @@ func WithExpiration(exp expBehavior) func(*CacheBatch) {
 	}
 }
 
-// WithLogger sets logger. If not set, global logger is used.
-func WithLogger(logger *zerolog.Logger) func(*CacheBatch) {
+// WithScanFailure sets the behavior when the database scan fails, or when the
+// scan is rejected by the drop threshold. With KeepCaches, the previous caches
+// are served until their Next Update is past, and the generation is not
+// advanced. With ResignCaches, the entries of the previous generation are
+// signed again with the new Next Update.
+// Default value is KeepCaches.
+func WithScanFailure(behavior scanFailureBehavior) func(*CacheBatch) {
 	return func(c *CacheBatch) {
-		c.logger = logger
+		c.scanFailure = behavior
+	}
+}
+
+// WithDropThreshold sets the drop threshold in percent. If the number of entries
+// of a scan drops by the threshold or more compared to the previous generation,
+// the scan is treated as failed and the cache store is not replaced with it.
+// Default value is 0, which disables the check.
+func WithDropThreshold(percent int) func(*CacheBatch) {
+	return func(c *CacheBatch) {
+		c.dropThreshold = percent
 	}
 }
 
-// WithQuietChan sets a quiet message channel, which
-// stops the loop of dyocsp.CacheBatch.Run(). It also sends a message immediately
-// before quieting the loop.
-func WithQuiteChan(ch chan string) func(*CacheBatch) {
+// WithSnapshotFile sets the path to the snapshot file. If set, each generation
+// of the cache store is written to the file after the store is updated, so that
+// the caches can be loaded with cache.ResponseCacheStore.LoadSnapshot on restart.
+// The changes from the change feed are written once per DefaultSnapshotDebounce.
+func WithSnapshotFile(file string) func(*CacheBatch) {
 	return func(c *CacheBatch) {
-		c.quite = ch
+		c.snapshotFile = file
+	}
+}
+
+// WithWorkers sets the number of workers that create and sign response caches
+// in parallel. The order of the signed caches does not depend on the number of
+// workers. If 0 or less than 0 is set, DefaultWorkers is used.
+func WithWorkers(workers int) func(*CacheBatch) {
+	return func(c *CacheBatch) {
+		c.workers = workers
+	}
+}
+
+// WithChangeFeed sets the change feed option. When the change feed is set,
+// the changed entries in the database are signed and swapped into the cache store
+// as soon as they are received, and the regular scan reconciles the caches
+// at every interval. Default value is nil.
+func WithChangeFeed(feed CADBChangeFeed) func(*CacheBatch) {
+	return func(c *CacheBatch) {
+		c.changeFeed = feed
+	}
+}
+
+// WithWatcher sets the watcher option. When the watcher is set, the caches
+// are refreshed with Refresh as soon as the database is updated, and the regular
+// scan is also done at every interval. Default value is nil.
+func WithWatcher(watcher CADBWatcher) func(*CacheBatch) {
+	return func(c *CacheBatch) {
+		c.watcher = watcher
+	}
+}
+
+// WithLogger sets logger. If not set, global logger is used.
+func WithLogger(logger *zerolog.Logger) func(*CacheBatch) {
+	return func(c *CacheBatch) {
+		c.logger = logger
 	}
 }
 
@@ -148,6 +277,14 @@ func WithUpdatedNotifyChan(ch chan struct{}) func(*CacheBatch) {
 	}
 }
 
+// WithMetrics sets the metrics that record the durations of the batches and
+// the number of the entries. If not set, the metrics are not recorded.
+func WithMetrics(metrics *Metrics) func(*CacheBatch) {
+	return func(c *CacheBatch) {
+		c.metrics = metrics
+	}
+}
+
 // NewCacheBatch creates a new instance of dyocsp.CacheBatch and returns it.
 func NewCacheBatch(
 	ca string,
@@ -158,13 +295,15 @@ func NewCacheBatch(
 	opts ...CacheBatchOption,
 ) (*CacheBatch, error) {
 	batch := &CacheBatch{
-		ca:          ca,
-		cacheStore:  cacheStore,
-		caDBClient:  caDBClient,
-		responder:   responder,
-		now:         date.NowGMT,
-		nextUpdate:  nextUpdate,
-		batchSerial: 0,
+		ca:               ca,
+		cacheStore:       cacheStore,
+		caDBClient:       caDBClient,
+		responder:        responder,
+		now:              date.NowGMT,
+		nextUpdate:       nextUpdate,
+		batchSerial:      0,
+		changeFeedRetry:  DefaultChangeFeedRetry,
+		snapshotDebounce: DefaultSnapshotDebounce,
 	}
 
 	for _, opt := range opts {
@@ -181,10 +320,18 @@ func NewCacheBatch(
 		batch.delay = 0
 	}
 
+	if batch.workers <= 0 {
+		batch.workers = DefaultWorkers
+	}
+
 	if batch.delay > batch.interval {
 		return nil, ErrDelayExceedsInterval
 	}
 
+	if batch.dropThreshold < 0 || batch.dropThreshold > 100 {
+		return nil, ErrDropThresholdRange
+	}
+
 	if batch.logger == nil {
 		batch.logger = &log.Logger
 	}
@@ -199,36 +346,96 @@ func (c *CacheBatch) logEntryErrors(ce db.CertificateEntry, logger *zerolog.Logg
 		if !ok {
 			continue
 		}
-		logger.Error().Err(err).Msg("")
+		event := logger.Error().Err(err)
+		if ce.Subject != "" {
+			event = event.Str("subject", ce.Subject)
+		}
+		event.Msg("")
+		c.metrics.incEntryRejected(c.ca, i)
 		noError = false
 	}
 	return noError
 }
 
+func (c *CacheBatch) entryExpired(action string) {
+	c.metrics.incEntryExpired(c.ca, action)
+}
+
 // RunOnce returns a slice of cache.ResponseCache through the following process.
 //   - Scan the CA database to identify entries related to certificate revocation.
 //   - Verify and parse entries for pre-signed response caches.
 //   - Sign the pre-signed response caches using the dyocsp.Responder.
 //
+// If the scan fails or the number of entries drops by the drop threshold or more,
+// it returns the caches of the previous generation according to the scan failure
+// behavior, instead of the caches of the scan. If the scan fails because the
+// context is canceled (e.g. on shutdown), it is not a failure of the scan, and the
+// caches of the previous generation are returned without panic in strict mode.
 // This function is the main job of dyocsp.CacheBatch.Run().
 func (c *CacheBatch) RunOnce(ctx context.Context) []cache.ResponseCache {
 	logger := zerolog.Ctx(ctx)
+	c.timings = batchTimings{}
+	c.keptCaches = false
+
+	entries, err := c.scanEntries(ctx)
+	if err != nil && ctx.Err() != nil {
+		return c.previousCaches(ctx)
+	}
+	if err != nil && c.strict {
+		panic(err)
+	}
+	if err == nil {
+		err = c.verifyEntryCount(entries)
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Database scan failed, the previous generation is used.")
+		if errors.As(err, &entryCountDropError{}) {
+			c.metrics.incBatchFailure(c.ca, batchFailureCountDrop)
+		} else {
+			c.metrics.incBatchFailure(c.ca, batchFailureScan)
+		}
+		return c.previousCaches(ctx)
+	}
+	c.lastEntries = entries
+
+	return c.signEntries(ctx, entries, c.nextUpdate, c.interval)
+}
+
+// scanEntries scans the CA database and returns the entries that response caches
+// will be created from.
+func (c *CacheBatch) scanEntries(ctx context.Context) ([]db.CertificateEntry, error) {
+	logger := zerolog.Ctx(ctx)
 
 	// DB --> IntermidiateEntry
 	var itmds []db.IntermidiateEntry
 	logger.Info().Msg("Database scan started by client.")
+	scanStart := time.Now()
 	itmds, err := c.caDBClient.Scan(ctx)
+	c.timings.scan = time.Since(scanStart)
 	if err != nil {
-		logger.Error().Err(err).Msg("")
-		if c.strict {
-			panic(err)
+		// The scan canceled on shutdown is not an error
+		if ctx.Err() == nil {
+			logger.Error().Err(err).Msg("")
 		}
+		return nil, err
 	}
 	logger.Info().Msg("Database scan completed.")
 	logger.Debug().Msgf("List of scanned entries from the database: %v", itmds)
 
 	// IntermidiateEntry --> CertificateEntry
-	expCtl := createExpirationLogger(c.expiration, *logger)
+	parseStart := time.Now()
+	entries := c.parseEntries(itmds, logger)
+	c.timings.parse = time.Since(parseStart)
+	logger.Debug().Msgf("List of exchange entries from scanned entries: %v", entries)
+
+	return entries, nil
+}
+
+// parseEntries verifies and parses the scanned entries. The entries that have
+// errors or are expired are dropped.
+func (c *CacheBatch) parseEntries(itmds []db.IntermidiateEntry, logger *zerolog.Logger) []db.CertificateEntry {
+	c.metrics.addEntriesScanned(c.ca, len(itmds))
+
 	exch := db.NewEntryExchange()
 	entries := make([]db.CertificateEntry, 0, len(itmds))
 	for idx := range itmds {
@@ -241,43 +448,133 @@ func (c *CacheBatch) RunOnce(ctx context.Context) []cache.ResponseCache {
 		if noerr := c.logEntryErrors(ce, logger); noerr {
 			entries = append(entries, ce)
 		}
+	}
+
+	// When certificate after date is past, response cache is not created.
+	if expCtl := createExpirationLogger(c.expiration, *logger, c.entryExpired); expCtl != nil {
+		entries = expCtl.Do(c.now(), entries)
+	}
+
+	return entries
+}
+
+// verifyEntryCount returns an error when the number of entries drops by the drop
+// threshold or more compared to the previous generation.
+func (c *CacheBatch) verifyEntryCount(entries []db.CertificateEntry) error {
+	if c.dropThreshold == 0 || len(c.lastEntries) == 0 {
+		return nil
+	}
+
+	prev := len(c.lastEntries)
+	drop := prev - len(entries)
+	if drop > 0 && drop*100 >= prev*c.dropThreshold {
+		return entryCountDropError{prev, len(entries)}
+	}
+
+	return nil
+}
+
+// previousCaches returns the caches of the previous generation according to
+// the scan failure behavior. The kept caches are still of the previous
+// generation, so keptCaches is set for Run not to advance the generation.
+func (c *CacheBatch) previousCaches(ctx context.Context) []cache.ResponseCache {
+	logger := zerolog.Ctx(ctx)
 
-		if expCtl != nil {
-			// When certificate after date is past, response cache is not created.
+	switch {
+	// Without a previous scan (e.g. caches loaded from a snapshot), keep the caches
+	case c.scanFailure == ResignCaches && c.lastEntries != nil:
+		entries := c.lastEntries
+		if expCtl := createExpirationLogger(c.expiration, *logger, c.entryExpired); expCtl != nil {
 			entries = expCtl.Do(c.now(), entries)
 		}
+		logger.Warn().Msgf("Re-signing %d entries of the previous generation.", len(entries))
+		return c.signEntries(ctx, entries, c.nextUpdate, c.interval)
+	default:
+		now := c.now()
+		prevCaches := c.cacheStore.Caches()
+		caches := make([]cache.ResponseCache, 0, len(prevCaches))
+		for idx := range prevCaches {
+			// Expired caches are no longer served.
+			if prevCaches[idx].Template().NextUpdate.After(now) {
+				caches = append(caches, prevCaches[idx])
+			}
+		}
+		logger.Warn().Msgf("Keeping %d unexpired caches of the previous generation.", len(caches))
+		c.keptCaches = true
+		return caches
 	}
-	logger.Debug().Msgf("List of exchange entries from scanned entries: %v", entries)
+}
 
-	// CertificateEntry --> cache.ResponseCache(Pre-Signed)
-	resCaches := make([]cache.ResponseCache, 0, len(entries))
+// signEntries creates and signs the response caches of the entries with the
+// worker pool. The signed caches are in the same order as the entries.
+func (c *CacheBatch) signEntries(
+	ctx context.Context, entries []db.CertificateEntry, thisUpdate time.Time, interval time.Duration,
+) []cache.ResponseCache {
+	logger := zerolog.Ctx(ctx)
+	signStart := time.Now()
+
+	results := make([]cache.ResponseCache, len(entries))
+	signed := make([]bool, len(entries))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(c.workers, len(entries)) {
+		wg.Go(func() {
+			for idx := range jobs {
+				results[idx], signed[idx] = c.signEntry(entries[idx], thisUpdate, interval, logger)
+			}
+		})
+	}
+	// Stop dispatching when the batch is canceled
+dispatch:
 	for idx := range entries {
-		resCache, err := cache.CreatePreSignedResponseCache(entries[idx], c.nextUpdate, c.interval)
-		if err != nil {
-			logger.Error().Err(err).Msg("")
-		}
-		if c.responder.AuthType == Delegation {
-			resCache.SetCertToTemplate(c.responder.rCert)
+		select {
+		case jobs <- idx:
+		case <-ctx.Done():
+			break dispatch
 		}
-		resCaches = append(resCaches, resCache)
 	}
-	logger.Debug().Msgf("Number of pre-signed-caches: %d", len(resCaches))
+	close(jobs)
+	wg.Wait()
 
-	// cache.ResponseCache(Pre-Signed) --> cache.ResponseCache(Signed)
-	signedCaches := make([]cache.ResponseCache, 0, len(resCaches))
-	for idx := range resCaches {
-		signedCache, err := c.responder.SignCacheResponse(resCaches[idx])
-		if err != nil {
-			logger.Error().Msg(fmt.Sprintf("Failed to sign :%v", resCaches[idx]))
-		} else {
-			signedCaches = append(signedCaches, signedCache)
+	signedCaches := make([]cache.ResponseCache, 0, len(entries))
+	for idx := range results {
+		if signed[idx] {
+			signedCaches = append(signedCaches, results[idx])
 		}
 	}
+	c.timings.sign = time.Since(signStart)
+	c.metrics.addEntriesSigned(c.ca, len(signedCaches))
 	logger.Debug().Msgf("Number of signed-caches: %d", len(signedCaches))
 
 	return signedCaches
 }
 
+// signEntry creates a pre-signed response cache from the entry and signs it.
+// It returns false when the cache could not be created or signed.
+func (c *CacheBatch) signEntry(
+	entry db.CertificateEntry, thisUpdate time.Time, interval time.Duration, logger *zerolog.Logger,
+) (cache.ResponseCache, bool) {
+	// CertificateEntry --> cache.ResponseCache(Pre-Signed)
+	resCache, err := cache.CreatePreSignedResponseCache(entry, thisUpdate, interval)
+	if err != nil {
+		logger.Error().Err(err).Msg("")
+		return resCache, false
+	}
+	if c.responder.AuthType == Delegation {
+		resCache.SetCertToTemplate(c.responder.rCert)
+	}
+
+	// cache.ResponseCache(Pre-Signed) --> cache.ResponseCache(Signed)
+	signedCache, err := c.responder.SignCacheResponse(resCache)
+	if err != nil {
+		logger.Error().Msg(fmt.Sprintf("Failed to sign :%v", resCache))
+		return resCache, false
+	}
+
+	return signedCache, true
+}
+
 func (c *CacheBatch) syncWithWaitDuration(now time.Time) time.Duration {
 	waitDur := c.interval
 	switch r := now.Compare(c.nextUpdate); r {
@@ -295,37 +592,46 @@ func (c *CacheBatch) syncWithWaitDuration(now time.Time) time.Duration {
 	return waitDur
 }
 
-func (c *CacheBatch) logBatchSummary(ctx context.Context, start time.Time) {
+func (c *CacheBatch) logBatchSummary(ctx context.Context, start time.Time, timings batchTimings) {
 	logger := zerolog.Ctx(ctx)
 
 	dur := fmt.Sprintf("%v", time.Since(start))
-	logger.Info().
+	event := logger.Info().
 		Str("duration", dur).
-		Msg("Cache generation batch completed.")
+		Str("scan_duration", fmt.Sprintf("%v", timings.scan)).
+		Str("parse_duration", fmt.Sprintf("%v", timings.parse)).
+		Str("sign_duration", fmt.Sprintf("%v", timings.sign)).
+		Str("update_duration", fmt.Sprintf("%v", timings.update))
+
+	// Report the statistics of the scan if the client provides them
+	if reporter, ok := c.caDBClient.(db.ScanStatsReporter); ok {
+		stats := reporter.LastScanStats()
+		event = event.
+			Float64("consumed_capacity", stats.ConsumedCapacity).
+			Int("scanned_count", stats.ScannedCount).
+			Int("scan_requests", stats.Requests)
+	}
+
+	event.Msg("Cache generation batch completed.")
 }
 
-func (c *CacheBatch) waitForNextUpdate(ctx context.Context, waitDur time.Duration) {
+// waitForNextUpdate waits for the duration. It returns false when the context
+// is canceled before the duration passes.
+func (c *CacheBatch) waitForNextUpdate(ctx context.Context, waitDur time.Duration) bool {
 	logger := zerolog.Ctx(ctx)
 
 	logger.Info().Dur("wait", waitDur).
 		Time("next-update", c.nextUpdate).
 		Msg("Waiting for the next update.")
 
-	if c.quite != nil {
-	outer:
-		for {
-			select {
-			case <-time.After(waitDur):
-				break outer
-			case msg := <-c.quite:
-				// Stop when it received quite message
-				logger.Info().Msgf("Quite message received, stop loop: %s", msg)
-				c.quite <- "Loop stopped."
-				return
-			}
-		}
-	} else {
-		time.Sleep(waitDur)
+	timer := time.NewTimer(waitDur)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
 
@@ -341,30 +647,83 @@ func (c *CacheBatch) waitForNextUpdate(ctx context.Context, waitDur time.Duratio
 //     or the duration of batch processing.
 //   - Update Next Update.
 //   - Wait for next update.
+//
+// If the change feed is set, the changes received from it are applied to
+// the cache store in parallel with the loop. If the watcher is set, the caches
+// are refreshed when the database is updated in parallel with the loop.
+//
+// Run returns when the context is canceled. The caches of a batch canceled
+// before the update are discarded, so that the cache store keeps serving the
+// caches of the previous batch.
 func (c *CacheBatch) Run(ctx context.Context) {
-	for {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if c.changeFeed != nil {
+		go c.watchChanges(ctx)
+	}
+
+	if c.watcher != nil {
+		go c.watchUpdates(ctx)
+	}
+
+	for ctx.Err() == nil {
 		startTime := c.now()
 
 		logger := c.logger.With().Int("batch_serial", c.batchSerial).Logger()
 		logger.Info().Msg("Starting cache generation batch.")
 		ctx := logger.WithContext(ctx)
 
+		// The changes received during the batch are applied after the update
+		c.genMu.Lock()
+
 		// Create response caches
 		caches := c.RunOnce(ctx)
+		if ctx.Err() != nil {
+			c.genMu.Unlock()
+			logger.Info().Msg("Cache generation batch canceled.")
+			return
+		}
 
 		// Update cache store
+		updateStart := time.Now()
 		invs := c.cacheStore.Update(caches)
 		for i := range invs {
 			logger.Error().Msgf("Invalid response cache: %s", invs[i].Entry().Serial)
 		}
 		logger.Info().Msg("Response cache updated.")
 
+		// The kept caches are of the served generation, whose Next Update is
+		// not extended, so only the failure is recorded by RunOnce
+		if !c.keptCaches {
+			if c.snapshotFile != "" {
+				if err := c.cacheStore.SaveSnapshot(c.snapshotFile); err != nil {
+					logger.Error().Err(err).Msg("Failed to save the snapshot.")
+				}
+			}
+			c.servedNextUpdate = c.nextUpdate.Add(c.interval)
+			c.generation.Store(&Generation{
+				BatchSerial: c.batchSerial,
+				ThisUpdate:  c.nextUpdate,
+				NextUpdate:  c.servedNextUpdate,
+				GeneratedAt: c.now(),
+			})
+		}
+		c.timings.update = time.Since(updateStart)
+		// The timings are changed by the out-of-cycle refreshes after the unlock
+		timings := c.timings
+		c.genMu.Unlock()
+
 		if c.updatedNotify != nil {
-			c.updatedNotify <- struct{}{}
+			select {
+			case c.updatedNotify <- struct{}{}:
+			case <-ctx.Done():
+			}
 		}
 
 		// Summury of this loop batch
-		c.logBatchSummary(ctx, startTime)
+		c.logBatchSummary(ctx, startTime, timings)
+		c.metrics.observeBatch(c.ca, timings)
 
 		waitDur := c.syncWithWaitDuration(c.now())
 
@@ -372,8 +731,100 @@ func (c *CacheBatch) Run(ctx context.Context) {
 		c.nextUpdate = c.nextUpdate.Add(c.interval)
 
 		// Wait for next update
-		c.waitForNextUpdate(ctx, waitDur)
+		if !c.waitForNextUpdate(ctx, waitDur) {
+			logger.Info().Msg("Cache generation loop stopped.")
+			return
+		}
 
 		c.batchSerial++
 	}
 }
+
+// CA returns the name of the CA whose caches the batch generates.
+func (c *CacheBatch) CA() string {
+	return c.ca
+}
+
+// Generation returns the metadata of the generation of the caches that are
+// being served. It returns false until the first batch updates the cache store.
+func (c *CacheBatch) Generation() (Generation, bool) {
+	gen := c.generation.Load()
+	if gen == nil {
+		return Generation{}, false
+	}
+	return *gen, true
+}
+
+// outOfCycleInterval returns the interval of the caches signed between the
+// batches, whose Next Update is the same as the served caches, so that they
+// are replaced by the next batch. If the served caches are already expired,
+// because the next batch is late, the interval of the batch is returned.
+func (c *CacheBatch) outOfCycleInterval(thisUpdate time.Time) time.Duration {
+	interval := c.servedNextUpdate.Sub(thisUpdate)
+	if interval <= 0 {
+		return c.interval
+	}
+	return interval
+}
+
+// Refresh scans the CA database and replaces the caches in the cache store
+// immediately, out of the cycle of Run. The refreshed caches have the same
+// Next Update as the served caches, so that the schedule of Run is not changed.
+// If the scan fails or the number of entries drops by the drop threshold or more,
+// or the context is canceled, it returns the error and the served caches are kept.
+// Unlike Run, it does not panic in strict mode.
+func (c *CacheBatch) Refresh(ctx context.Context) error {
+	logger := c.logger.With().Bool("out_of_cycle", true).Logger()
+	ctx = logger.WithContext(ctx)
+
+	c.genMu.Lock()
+	defer c.genMu.Unlock()
+
+	start := time.Now()
+	c.timings = batchTimings{}
+	logger.Info().Msg("Starting out-of-cycle cache refresh.")
+
+	entries, err := c.scanEntries(ctx)
+	if err == nil {
+		err = c.verifyEntryCount(entries)
+	}
+	if err != nil {
+		return err
+	}
+
+	thisUpdate := c.now()
+	interval := c.outOfCycleInterval(thisUpdate)
+	caches := c.signEntries(ctx, entries, thisUpdate, interval)
+	if err := ctx.Err(); err != nil {
+		logger.Info().Msg("Out-of-cycle cache refresh canceled.")
+		return err
+	}
+	c.lastEntries = entries
+
+	updateStart := time.Now()
+	invs := c.cacheStore.Update(caches)
+	for i := range invs {
+		logger.Error().Msgf("Invalid response cache: %s", invs[i].Entry().Serial)
+	}
+
+	if c.snapshotFile != "" {
+		if err := c.cacheStore.SaveSnapshot(c.snapshotFile); err != nil {
+			logger.Error().Err(err).Msg("Failed to save the snapshot.")
+		}
+	}
+	c.timings.update = time.Since(updateStart)
+
+	gen := Generation{}
+	if prev := c.generation.Load(); prev != nil {
+		gen.BatchSerial = prev.BatchSerial
+		gen.Refreshes = prev.Refreshes + 1
+	}
+	gen.ThisUpdate = thisUpdate
+	gen.NextUpdate = thisUpdate.Add(interval)
+	gen.GeneratedAt = c.now()
+	c.generation.Store(&gen)
+
+	c.logBatchSummary(ctx, start, c.timings)
+
+	return nil
+}
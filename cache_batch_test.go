@@ -3,8 +3,10 @@ package dyocsp
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/big"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/yuxki/dyocsp/pkg/cache"
 	"github.com/yuxki/dyocsp/pkg/date"
 	"github.com/yuxki/dyocsp/pkg/db"
+	"golang.org/x/crypto/ocsp"
 )
 
 type StubCADBClient struct {
@@ -65,12 +68,12 @@ func TestNewCacheBatch_OptoinsDefaults(t *testing.T) {
 	if batch.expiration != Ignore {
 		t.Error("value of expiration is not default.")
 	}
+	if batch.workers != DefaultWorkers {
+		t.Error("value of workers is not default.")
+	}
 	if batch.logger != &log.Logger {
 		t.Error("value of logger is not default.")
 	}
-	if batch.quite != nil {
-		t.Error("value of quite is not default.")
-	}
 	if batch.updatedNotify != nil {
 		t.Error("value of updatedNotify is not default.")
 	}
@@ -84,15 +87,14 @@ func TestNewCacheBatch_OptoinsSet(t *testing.T) {
 	responder := testCreateDelegatedResponder(t)
 	store := cache.NewResponseCacheStore()
 	logger := zerolog.New(os.Stdout).With().Logger()
-	quiteCh := make(chan string)
 	updatedNotifyCh := make(chan struct{})
 	batch, err := NewCacheBatch("test-ca", store, client, responder, date.NowGMT(),
 		WithIntervalSec(10),
 		WithDelay(time.Second*5),
 		WithStrict(true),
 		WithExpiration(Warn),
+		WithWorkers(4),
 		WithLogger(&logger),
-		WithQuiteChan(quiteCh),
 		WithUpdatedNotifyChan(updatedNotifyCh),
 	)
 	if err != nil {
@@ -111,12 +113,12 @@ func TestNewCacheBatch_OptoinsSet(t *testing.T) {
 	if batch.expiration != Warn {
 		t.Error("value of expiration is not specified.")
 	}
+	if batch.workers != 4 {
+		t.Error("value of workers is not specified.")
+	}
 	if batch.logger != &logger {
 		t.Error("value of logger is not specified.")
 	}
-	if batch.quite == nil {
-		t.Error("value of quite is not specified.")
-	}
 	if batch.updatedNotify == nil {
 		t.Error("value of updatedNotify is not specified.")
 	}
@@ -383,3 +385,483 @@ func TestCacheBatch_Run_DirectResponder(t *testing.T) {
 		t.Fatalf("Direct signing responder may not contain itself certificate.")
 	}
 }
+
+var errStubScan = errors.New("stub scan error")
+
+// StubScriptedCADBClient returns the results of scans in order. A nil result
+// is returned as a scan error.
+type StubScriptedCADBClient struct {
+	scans [][]db.IntermidiateEntry
+	count int
+}
+
+func (s *StubScriptedCADBClient) Scan(ctx context.Context) ([]db.IntermidiateEntry, error) {
+	scan := s.scans[s.count]
+	s.count++
+	if scan == nil {
+		return nil, errStubScan
+	}
+	return scan, nil
+}
+
+func testIntermidiateEntries(serials ...string) []db.IntermidiateEntry {
+	entries := make([]db.IntermidiateEntry, 0, len(serials))
+	for _, serial := range serials {
+		entries = append(entries, db.IntermidiateEntry{
+			Ca:      "test-ca",
+			Serial:  serial,
+			RevType: "V",
+			ExpDate: "330925234911Z",
+		})
+	}
+	return entries
+}
+
+func TestCacheBatch_RunOnce_ScanFailure(t *testing.T) {
+	t.Parallel()
+
+	nextUpdate := time.Date(2023, 8, 9, 12, 30, 0, 0, time.UTC)
+	first := testIntermidiateEntries("01", "02", "03", "04")
+
+	data := []struct {
+		testCase string
+		// test data
+		scans     [][]db.IntermidiateEntry
+		behavior  scanFailureBehavior
+		threshold int
+		now       time.Time
+		// want
+		count    int
+		resigned bool
+	}{
+		{
+			"keep: scan error",
+			[][]db.IntermidiateEntry{first, nil},
+			KeepCaches, 0, nextUpdate, 4, false,
+		},
+		{
+			"keep: scan error after the caches expired",
+			[][]db.IntermidiateEntry{first, nil},
+			KeepCaches, 0, nextUpdate.Add(time.Hour), 0, false,
+		},
+		{
+			"resign: scan error",
+			[][]db.IntermidiateEntry{first, nil},
+			ResignCaches, 0, nextUpdate.Add(time.Hour), 4, true,
+		},
+		{
+			"keep: drop exceeds threshold",
+			[][]db.IntermidiateEntry{first, testIntermidiateEntries("01")},
+			KeepCaches, 50, nextUpdate, 4, false,
+		},
+		{
+			"resign: drop exceeds threshold",
+			[][]db.IntermidiateEntry{first, testIntermidiateEntries("01", "02")},
+			ResignCaches, 50, nextUpdate, 4, true,
+		},
+		{
+			"drop under threshold",
+			[][]db.IntermidiateEntry{first, testIntermidiateEntries("01", "02", "03")},
+			KeepCaches, 50, nextUpdate, 3, true,
+		},
+		{
+			"drop without threshold",
+			[][]db.IntermidiateEntry{first, {}},
+			KeepCaches, 0, nextUpdate, 0, true,
+		},
+	}
+
+	for _, d := range data {
+		d := d
+		t.Run(d.testCase, func(t *testing.T) {
+			t.Parallel()
+
+			client := &StubScriptedCADBClient{scans: d.scans}
+			responder := testCreateDelegatedResponder(t)
+			store := cache.NewResponseCacheStore()
+			batch, err := NewCacheBatch(
+				"test-ca",
+				store,
+				client,
+				responder,
+				nextUpdate,
+				WithIntervalSec(60),
+				WithScanFailure(d.behavior),
+				WithDropThreshold(d.threshold),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			batch.now = func() time.Time { return d.now }
+
+			ctx := context.TODO()
+			store.Update(batch.RunOnce(ctx))
+
+			batch.nextUpdate = batch.nextUpdate.Add(batch.interval)
+			caches := batch.RunOnce(ctx)
+
+			if len(caches) != d.count {
+				t.Fatalf("Expected %d caches but got: %d", d.count, len(caches))
+			}
+
+			for _, c := range caches {
+				resigned := c.Template().ThisUpdate.Equal(batch.nextUpdate)
+				if resigned != d.resigned {
+					t.Errorf("Expected resigned is %t but got: %t", d.resigned, resigned)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheBatch_Run_KeepCachesGeneration(t *testing.T) {
+	t.Parallel()
+
+	client := &StubScriptedCADBClient{scans: [][]db.IntermidiateEntry{
+		testIntermidiateEntries("01"), nil,
+	}}
+	notifyCh := make(chan struct{})
+	batch, err := NewCacheBatch(
+		"test-ca", cache.NewResponseCacheStore(), client, testCreateDelegatedResponder(t), date.NowGMT(),
+		WithIntervalSec(1),
+		WithUpdatedNotifyChan(notifyCh),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan struct{})
+	go func() {
+		batch.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		testWaitRunReturned(t, done)
+	}()
+
+	<-notifyCh
+	served, _ := batch.Generation()
+	servedNextUpdate := batch.servedNextUpdate
+
+	// The second scan fails, and the previous generation is kept
+	<-notifyCh
+	kept, _ := batch.Generation()
+	if kept != served {
+		t.Errorf("Expected generation is %#v but got: %#v", served, kept)
+	}
+	if !batch.servedNextUpdate.Equal(servedNextUpdate) {
+		t.Errorf("Expected served Next Update is %v but got: %v", servedNextUpdate, batch.servedNextUpdate)
+	}
+}
+
+func TestNewCacheBatch_ErrDropThresholdRange(t *testing.T) {
+	t.Parallel()
+
+	client := StubCADBClient{"test-ca", []db.IntermidiateEntry{}}
+	responder := testCreateDelegatedResponder(t)
+	store := cache.NewResponseCacheStore()
+	for _, threshold := range []int{-1, 101} {
+		_, err := NewCacheBatch("test-ca", store, client, responder, date.NowGMT(),
+			WithDropThreshold(threshold))
+		if !errors.Is(err, ErrDropThresholdRange) {
+			t.Errorf("Expected ErrDropThresholdRange for %d but got: %v", threshold, err)
+		}
+	}
+}
+
+func TestCacheBatch_Run_SnapshotFile(t *testing.T) {
+	t.Parallel()
+
+	targetSerialStr := "8CA7B3FE5D7F007673C18CCC6A1F818085CDC5F5"
+
+	client := StubCADBClient{"test-ca", testIntermidiateEntries(targetSerialStr)}
+	responder := testCreateDelegatedResponder(t)
+	store := cache.NewResponseCacheStore()
+	notifyCh := make(chan struct{})
+	snapshotFile := filepath.Join(t.TempDir(), "test-ca.snapshot.json")
+	batch, err := NewCacheBatch(
+		"test-ca",
+		store,
+		client,
+		responder,
+		date.NowGMT(),
+		WithIntervalSec(60),
+		WithUpdatedNotifyChan(notifyCh),
+		WithSnapshotFile(snapshotFile),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go batch.Run(context.TODO())
+	<-notifyCh
+
+	restored := cache.NewResponseCacheStore()
+	count, _, err := restored.LoadSnapshot(snapshotFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected 1 cache is loaded but got: %d", count)
+	}
+
+	want := testGetCache(t, targetSerialStr, store)
+	got := testGetCache(t, targetSerialStr, restored)
+	if string(got.Response()) != string(want.Response()) {
+		t.Error("Restored response does not match the stored response.")
+	}
+}
+
+func TestCacheBatch_RunOnce_Workers(t *testing.T) {
+	t.Parallel()
+
+	serials := make([]string, 0, 50)
+	for i := 1; i <= 50; i++ {
+		serials = append(serials, fmt.Sprintf("%02X", i))
+	}
+	// Invalid entry is skipped without changing the order
+	serials = append(serials[:10], append([]string{"ZZ"}, serials[10:]...)...)
+
+	client := StubCADBClient{"test-ca", testIntermidiateEntries(serials...)}
+	responder := testCreateDelegatedResponder(t)
+
+	for _, workers := range []int{1, 4, 100} {
+		batch, err := NewCacheBatch(
+			"test-ca", cache.NewResponseCacheStore(), client, responder, date.NowGMT(),
+			WithWorkers(workers),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		caches := batch.RunOnce(context.TODO())
+		if len(caches) != 50 {
+			t.Fatalf("workers %d: Expected 50 caches but got: %d", workers, len(caches))
+		}
+
+		for idx, c := range caches {
+			if c.Entry().Serial.Int64() != int64(idx+1) {
+				t.Fatalf("workers %d: Expected serial %d at %d but got: %d",
+					workers, idx+1, idx, c.Entry().Serial.Int64())
+			}
+			if c.Response() == nil {
+				t.Fatalf("workers %d: cache at %d is not signed", workers, idx)
+			}
+		}
+
+		if batch.timings.sign == 0 {
+			t.Errorf("workers %d: sign duration is not measured", workers)
+		}
+	}
+}
+
+// StubBlockingCADBClient returns the entries after the context is canceled.
+type StubBlockingCADBClient struct {
+	db      []db.IntermidiateEntry
+	started chan struct{}
+}
+
+func (s StubBlockingCADBClient) Scan(ctx context.Context) ([]db.IntermidiateEntry, error) {
+	close(s.started)
+	<-ctx.Done()
+	return s.db, nil
+}
+
+// StubCanceledCADBClient fails the scan after the context is canceled.
+type StubCanceledCADBClient struct {
+	started chan struct{}
+}
+
+func (s StubCanceledCADBClient) Scan(ctx context.Context) ([]db.IntermidiateEntry, error) {
+	close(s.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCacheBatch_RunOnce_CanceledStrict(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDelegatedResponder(t)
+	store := cache.NewResponseCacheStore()
+	store.Update([]cache.ResponseCache{testCreateCacheForCert(t, responder, big.NewInt(1), 500)})
+
+	client := StubCanceledCADBClient{make(chan struct{})}
+	batch, err := NewCacheBatch("test-ca", store, client, responder, date.NowGMT(), WithStrict(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-client.started
+		cancel()
+	}()
+
+	// The scan canceled on shutdown does not panic in strict mode
+	caches := batch.RunOnce(ctx)
+	if len(caches) != 1 {
+		t.Errorf("Expected the 1 cache of the previous generation but got: %d", len(caches))
+	}
+}
+
+func testWaitRunReturned(t *testing.T, done chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Run did not return after the context was canceled.")
+	}
+}
+
+func TestCacheBatch_Run_Canceled(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDelegatedResponder(t)
+
+	t.Run("while waiting for the next update", func(t *testing.T) {
+		t.Parallel()
+
+		client := StubCADBClient{"test-ca", testIntermidiateEntries("01")}
+		notifyCh := make(chan struct{})
+		batch, err := NewCacheBatch(
+			"test-ca", cache.NewResponseCacheStore(), client, responder, date.NowGMT(),
+			WithIntervalSec(60),
+			WithUpdatedNotifyChan(notifyCh),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			batch.Run(ctx)
+			close(done)
+		}()
+
+		<-notifyCh
+		cancel()
+		testWaitRunReturned(t, done)
+	})
+
+	t.Run("while scanning", func(t *testing.T) {
+		t.Parallel()
+
+		store := cache.NewResponseCacheStore()
+		store.Update([]cache.ResponseCache{testCreateCacheForCert(t, responder, big.NewInt(1), 500)})
+
+		client := StubBlockingCADBClient{testIntermidiateEntries("02"), make(chan struct{})}
+		batch, err := NewCacheBatch("test-ca", store, client, responder, date.NowGMT())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			batch.Run(ctx)
+			close(done)
+		}()
+
+		<-client.started
+		cancel()
+		testWaitRunReturned(t, done)
+
+		// The caches of the canceled batch are discarded
+		if _, ok := store.Get(big.NewInt(1)); !ok {
+			t.Error("Expected the previous cache is kept.")
+		}
+		if _, ok := store.Get(big.NewInt(2)); ok {
+			t.Error("Expected the cache of the canceled batch is discarded.")
+		}
+	})
+}
+
+// testRunBatchUntilUpdated runs the batch until the cache store is updated
+// once, and returns the function that stops the batch.
+func testRunBatchUntilUpdated(t *testing.T, client CADBClient, responder *Responder) (*CacheBatch, func()) {
+	t.Helper()
+
+	notifyCh := make(chan struct{})
+	batch, err := NewCacheBatch(
+		"test-ca", cache.NewResponseCacheStore(), client, responder, date.NowGMT(),
+		WithIntervalSec(60),
+		WithUpdatedNotifyChan(notifyCh),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan struct{})
+	go func() {
+		batch.Run(ctx)
+		close(done)
+	}()
+	<-notifyCh
+
+	return batch, func() {
+		cancel()
+		testWaitRunReturned(t, done)
+	}
+}
+
+func TestCacheBatch_Refresh(t *testing.T) {
+	t.Parallel()
+
+	revoked := db.IntermidiateEntry{
+		Ca:        "test-ca",
+		Serial:    "02",
+		RevType:   "R",
+		ExpDate:   "330925234911Z",
+		RevDate:   "230826234911Z",
+		CRLReason: "keyCompromise",
+	}
+	client := &StubScriptedCADBClient{scans: [][]db.IntermidiateEntry{
+		testIntermidiateEntries("01"),
+		append(testIntermidiateEntries("01"), revoked),
+		nil,
+	}}
+	batch, stop := testRunBatchUntilUpdated(t, client, testCreateDelegatedResponder(t))
+	defer stop()
+
+	served, ok := batch.Generation()
+	if !ok {
+		t.Fatal("Generation must be set after the batch.")
+	}
+
+	if err := batch.Refresh(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	resCache := testGetCache(t, "02", batch.cacheStore)
+	if resCache.Template().Status != ocsp.Revoked {
+		t.Errorf("Expected status is revoked but got: %d", resCache.Template().Status)
+	}
+	// The schedule of the batch is not changed
+	if !resCache.Template().NextUpdate.Equal(served.NextUpdate) {
+		t.Errorf("Expected Next Update is %v but got: %v", served.NextUpdate, resCache.Template().NextUpdate)
+	}
+	if !resCache.Template().ThisUpdate.After(served.ThisUpdate) {
+		t.Errorf("This Update must be after the served one: %v", resCache.Template().ThisUpdate)
+	}
+
+	refreshed, _ := batch.Generation()
+	if refreshed.BatchSerial != served.BatchSerial || refreshed.Refreshes != 1 {
+		t.Errorf("Unexpected generation: %#v", refreshed)
+	}
+	if !refreshed.NextUpdate.Equal(served.NextUpdate) {
+		t.Errorf("Expected Next Update is %v but got: %v", served.NextUpdate, refreshed.NextUpdate)
+	}
+
+	// The scan fails, and the caches are kept
+	if err := batch.Refresh(context.TODO()); !errors.Is(err, errStubScan) {
+		t.Errorf("Expected error is %v but got: %v", errStubScan, err)
+	}
+	if stats := batch.cacheStore.Stats(); stats.Entries != 2 {
+		t.Errorf("Expected entries are 2 but got: %d", stats.Entries)
+	}
+}
@@ -2,12 +2,14 @@ package dyocsp
 
 import (
 	"crypto"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,6 +29,7 @@ func handleHTTPMethod(h http.Handler) http.Handler {
 		switch r.Method {
 		case http.MethodPost:
 		case http.MethodGet:
+		case http.MethodHead:
 		default:
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
@@ -41,7 +44,7 @@ func handleOverMaxRequestBytes(limit int) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if limit > 0 {
 				switch r.Method {
-				case http.MethodGet:
+				case http.MethodGet, http.MethodHead:
 					reqPath := strings.TrimPrefix(r.URL.Path, "/")
 					if len(reqPath) > base64.StdEncoding.EncodedLen(limit) {
 						w.WriteHeader(http.StatusRequestEntityTooLarge)
@@ -56,16 +59,54 @@ func handleOverMaxRequestBytes(limit int) func(http.Handler) http.Handler {
 	}
 }
 
+// authority is a pair of the responder and its cache store.
+type authority struct {
+	cacheStore *cache.ResponseCacheStoreRO
+	responder  *Responder
+	// The signed responses for the serial numbers not found in the cacheStore
+	negatives *cache.NegativeCacheStore
+	// The signed responses of the cacheStore for the CertIDs not hashed with SHA-1
+	hashed *cache.HashCacheStore
+}
+
+// Default values of the hash cache.
+const (
+	DefaultHashCacheSize    = 10000
+	DefaultHashCacheSigners = 8
+)
+
+// errHashSignersBusy is returned when the response for the hash algorithm is
+// not cached, and the maximum number of the responses are being signed.
+var errHashSignersBusy = errors.New("hash response signers are busy")
+
 // CacheHandler is an implementation of the http.Handler interface.
 // It is used to handle OCSP requests.
 type CacheHandler struct {
-	cacheStore *cache.ResponseCacheStoreRO
-	responder  *Responder
+	authorities []authority
 	// spec       CacheHandlerSpec
 	now             date.Now
 	maxRequestBytes int
 	maxAge          int
 	logger          *zerolog.Logger
+	// Options of on-demand signing for requests carrying a nonce
+	nonceSigners   int
+	nonceMaxLength int
+	nonceSem       chan struct{}
+	// Options of responses for serial numbers not found in the cache store
+	cacheMiss            cacheMissBehavior
+	negativeCacheTTL     time.Duration
+	negativeCacheSize    int
+	negativeCacheSigners int
+	negativeSem          chan struct{}
+	// Options of responses for CertIDs not hashed with SHA-1
+	hashCacheSize    int
+	hashCacheSigners int
+	hashSem          chan struct{}
+	// Options of requests with multiple CertIDs
+	maxCertIDs       int
+	multiCertSigners int
+	multiCertSem     chan struct{}
+	metrics          *Metrics
 }
 
 // CacheHandlerOption is type of an functional option for dyocsp.CacheHandler.
@@ -92,6 +133,132 @@ func WithMaxAge(maxAge int) func(*CacheHandler) {
 	}
 }
 
+// WithResponder adds a responder and its cache store to the handler, so that
+// the handler serves multiple CAs. Requests are routed to the responder whose
+// issuer matches the IssuerNameHash and IssuerKeyHash of the request.
+func WithResponder(cacheStore *cache.ResponseCacheStoreRO, responder *Responder) func(*CacheHandler) {
+	return func(c *CacheHandler) {
+		c.authorities = append(c.authorities, authority{cacheStore: cacheStore, responder: responder})
+	}
+}
+
+// WithNonceSigning enables on-demand signing for requests carrying a nonce
+// extension (RFC 8954). The response of such a request is signed with the nonce
+// echoed, instead of the cached response. signers is the maximum number of
+// responses signed concurrently. When the limit is reached, the cached response is
+// sent without the nonce. Default value is 0, and if 0 or less than 0 is set,
+// the nonce is ignored.
+func WithNonceSigning(signers int) func(*CacheHandler) {
+	return func(c *CacheHandler) {
+		c.nonceSigners = signers
+	}
+}
+
+// WithNonceMaxLength sets the maximum length of the nonce in octets. The request
+// carrying a longer nonce is responded with ocsp.MalformedRequestErrorResponse.
+// Default value is NonceMaxLength (32). If the value is out of range 1 to 32,
+// the default value is used.
+func WithNonceMaxLength(length int) func(*CacheHandler) {
+	return func(c *CacheHandler) {
+		c.nonceMaxLength = length
+	}
+}
+
+// WithCacheMiss sets the response to a request whose serial number is not found
+// in the cache store of the known issuer. With CacheMissUnknown or CacheMissRevoked,
+// the response is signed on demand and cached in the negative cache.
+// Default value is CacheMissUnauthorized.
+func WithCacheMiss(behavior cacheMissBehavior) func(*CacheHandler) {
+	return func(c *CacheHandler) {
+		c.cacheMiss = behavior
+	}
+}
+
+// WithNegativeCacheTTL sets the duration from This Update to Next Update of the
+// negative responses, and they are cached for the duration.
+// Default value is DefaultNegativeCacheTTL (1 minute). If 0 or less than 0 is set,
+// the default value is used.
+func WithNegativeCacheTTL(ttl time.Duration) func(*CacheHandler) {
+	return func(c *CacheHandler) {
+		c.negativeCacheTTL = ttl
+	}
+}
+
+// WithNegativeCacheSize sets the maximum number of the negative responses cached
+// for each issuer. When the negative cache is full, the least recently used
+// response is evicted.
+// Default value is DefaultNegativeCacheSize (10000). If 0 or less than 0 is set,
+// the default value is used.
+func WithNegativeCacheSize(size int) func(*CacheHandler) {
+	return func(c *CacheHandler) {
+		c.negativeCacheSize = size
+	}
+}
+
+// WithNegativeCacheSigners sets the maximum number of the negative responses
+// signed concurrently. When the limit is reached, the request whose negative
+// response is not cached is responded with ocsp.TryLaterErrorResponse.
+// Default value is DefaultNegativeCacheSigners (8). If 0 or less than 0 is set,
+// the default value is used.
+func WithNegativeCacheSigners(signers int) func(*CacheHandler) {
+	return func(c *CacheHandler) {
+		c.negativeCacheSigners = signers
+	}
+}
+
+// WithHashCacheSize sets the maximum number of the responses cached for each
+// issuer, which are signed for the CertIDs hashed with the algorithms other than
+// SHA-1. When the hash cache is full, the least recently used response is evicted.
+// Default value is DefaultHashCacheSize (10000). If 0 or less than 0 is set,
+// the default value is used.
+func WithHashCacheSize(size int) func(*CacheHandler) {
+	return func(c *CacheHandler) {
+		c.hashCacheSize = size
+	}
+}
+
+// WithHashCacheSigners sets the maximum number of the responses for the CertIDs
+// hashed with the algorithms other than SHA-1 signed concurrently. When the
+// limit is reached, the request whose response is not cached is responded with
+// ocsp.TryLaterErrorResponse.
+// Default value is DefaultHashCacheSigners (8). If 0 or less than 0 is set,
+// the default value is used.
+func WithHashCacheSigners(signers int) func(*CacheHandler) {
+	return func(c *CacheHandler) {
+		c.hashCacheSigners = signers
+	}
+}
+
+// WithMaxCertIDs sets the maximum number of CertIDs in a request. If more than 1
+// is set, the request with multiple CertIDs is responded with a response signed
+// on demand, which has a SingleResponse for each CertID. The request with more
+// CertIDs is responded with ocsp.MalformedRequestErrorResponse.
+// Default value is DefaultMaxCertIDs (1), and only the first CertID is responded.
+func WithMaxCertIDs(maxCertIDs int) func(*CacheHandler) {
+	return func(c *CacheHandler) {
+		c.maxCertIDs = maxCertIDs
+	}
+}
+
+// WithMultiCertSigners sets the maximum number of the responses to the requests
+// with multiple CertIDs signed concurrently. When the limit is reached, the request
+// is responded with ocsp.TryLaterErrorResponse.
+// Default value is DefaultMultiCertSigners (8). If 0 or less than 0 is set,
+// the default value is used.
+func WithMultiCertSigners(signers int) func(*CacheHandler) {
+	return func(c *CacheHandler) {
+		c.multiCertSigners = signers
+	}
+}
+
+// WithHandlerMetrics sets the metrics that record the number and the latency
+// of the requests. If not set, the metrics are not recorded.
+func WithHandlerMetrics(metrics *Metrics) func(*CacheHandler) {
+	return func(c *CacheHandler) {
+		c.metrics = metrics
+	}
+}
+
 // WithHandlerLogger sets logger. If not set, global logger is used.
 func WithHandlerLogger(logger *zerolog.Logger) func(*CacheHandler) {
 	return func(c *CacheHandler) {
@@ -106,9 +273,11 @@ const (
 // NewCacheHandler creates a new instance of dyocsp.CacheHandler.
 // It chains the following handlers before the handler that sends the OCSP response.
 // (It uses 'https://github.com/justinas/alice' to chain the handlers.)
-//   - Send http.StatusMethodNotAllowed unless the request method is POST or Get.
+//   - Send http.StatusMethodNotAllowed unless the request method is POST, GET or HEAD.
 //   - Send http.StatusRequestEntityTooLarge if the size of the request
 //     exceeds the value of the variable spec.MaxRequestBytes..
+//
+// To serve multiple CAs, add responders with the WithResponder option.
 func NewCacheHandler(
 	cacheStore *cache.ResponseCacheStoreRO,
 	responder *Responder,
@@ -116,9 +285,8 @@ func NewCacheHandler(
 	opts ...CacheHandlerOption,
 ) http.Handler {
 	handler := CacheHandler{
-		cacheStore: cacheStore,
-		responder:  responder,
-		now:        date.NowGMT,
+		authorities: []authority{{cacheStore: cacheStore, responder: responder}},
+		now:         date.NowGMT,
 	}
 
 	for _, opt := range opts {
@@ -133,6 +301,62 @@ func NewCacheHandler(
 		handler.logger = &log.Logger
 	}
 
+	if handler.nonceMaxLength < NonceMinLength || handler.nonceMaxLength > NonceMaxLength {
+		handler.nonceMaxLength = NonceMaxLength
+	}
+
+	if handler.nonceSigners > 0 {
+		handler.nonceSem = make(chan struct{}, handler.nonceSigners)
+	}
+
+	if handler.negativeCacheTTL <= 0 {
+		handler.negativeCacheTTL = DefaultNegativeCacheTTL
+	}
+
+	if handler.negativeCacheSize <= 0 {
+		handler.negativeCacheSize = DefaultNegativeCacheSize
+	}
+
+	if handler.negativeCacheSigners <= 0 {
+		handler.negativeCacheSigners = DefaultNegativeCacheSigners
+	}
+
+	if handler.cacheMiss != CacheMissUnauthorized {
+		handler.negativeSem = make(chan struct{}, handler.negativeCacheSigners)
+	}
+
+	if handler.hashCacheSize <= 0 {
+		handler.hashCacheSize = DefaultHashCacheSize
+	}
+
+	if handler.hashCacheSigners <= 0 {
+		handler.hashCacheSigners = DefaultHashCacheSigners
+	}
+	handler.hashSem = make(chan struct{}, handler.hashCacheSigners)
+
+	if handler.maxCertIDs < DefaultMaxCertIDs {
+		handler.maxCertIDs = DefaultMaxCertIDs
+	}
+
+	if handler.multiCertSigners <= 0 {
+		handler.multiCertSigners = DefaultMultiCertSigners
+	}
+
+	if handler.maxCertIDs > 1 {
+		handler.multiCertSem = make(chan struct{}, handler.multiCertSigners)
+	}
+
+	for idx := range handler.authorities {
+		handler.authorities[idx].hashed = cache.NewHashCacheStore(handler.hashCacheSize)
+		if handler.cacheMiss != CacheMissUnauthorized {
+			handler.authorities[idx].negatives = cache.NewNegativeCacheStore(handler.negativeCacheSize)
+		}
+	}
+
+	// The rejected requests are also recorded
+	if handler.metrics != nil {
+		chain = chain.Append(handler.metrics.handleRequestMetrics)
+	}
 	chain = chain.Append(handleHTTPMethod)
 	chain = chain.Append(handleOverMaxRequestBytes(handler.maxRequestBytes))
 
@@ -149,21 +373,39 @@ func (e invalidIssuerError) Error() string {
 
 func verifyIssuer(req *ocsp.Request, responder *Responder) error {
 	// Check issuer is collect
-	switch req.HashAlgorithm {
-	case crypto.SHA1:
-		if !reflect.DeepEqual(req.IssuerNameHash, responder.IssuerNameHash.SHA1) {
-			return invalidIssuerError{fmt.Sprintf("IssuerNameHash not matched:%x", req.IssuerNameHash)}
-		}
-		if !reflect.DeepEqual(req.IssuerKeyHash, responder.IssuerKeyHash.SHA1) {
-			return invalidIssuerError{fmt.Sprintf("SubjectPublicKeyHash not matched:%x", req.IssuerKeyHash)}
-		}
-	default:
+	nameHash, ok := responder.IssuerNameHash.Hash(req.HashAlgorithm)
+	if !ok {
 		return invalidIssuerError{fmt.Sprintf("Unsupported hash algorithm:%d", req.HashAlgorithm)}
 	}
+	keyHash, _ := responder.IssuerKeyHash.Hash(req.HashAlgorithm)
+
+	if !reflect.DeepEqual(req.IssuerNameHash, nameHash) {
+		return invalidIssuerError{fmt.Sprintf("IssuerNameHash not matched:%x", req.IssuerNameHash)}
+	}
+	if !reflect.DeepEqual(req.IssuerKeyHash, keyHash) {
+		return invalidIssuerError{fmt.Sprintf("SubjectPublicKeyHash not matched:%x", req.IssuerKeyHash)}
+	}
 
 	return nil
 }
 
+// matchAuthority returns the authority whose issuer matches the request.
+func (c CacheHandler) matchAuthority(req *ocsp.Request) (authority, error) {
+	if len(c.authorities) == 1 {
+		return c.authorities[0], verifyIssuer(req, c.authorities[0].responder)
+	}
+
+	for _, auth := range c.authorities {
+		if err := verifyIssuer(req, auth.responder); err == nil {
+			return auth, nil
+		}
+	}
+
+	return authority{}, invalidIssuerError{fmt.Sprintf(
+		"no configured issuer matched IssuerNameHash:%x IssuerKeyHash:%x", req.IssuerNameHash, req.IssuerKeyHash,
+	)}
+}
+
 func addSuccessOCSPResHeader(w http.ResponseWriter, cache *cache.ResponseCache, nowT time.Time, cacheCtlMaxAge int) {
 	// Configured max-age cannot be over nextUpdate
 	maxAge := cacheCtlMaxAge
@@ -176,7 +418,44 @@ func addSuccessOCSPResHeader(w http.ResponseWriter, cache *cache.ResponseCache,
 	w.Header().Add("Last-Modified", cache.Template().ProducedAt.Format(http.TimeFormat))
 	w.Header().Add("Expires", cache.Template().NextUpdate.Format(http.TimeFormat))
 	w.Header().Add("Date", nowT.Format(http.TimeFormat))
-	w.Header().Add("ETag", cache.SHA1HashHexString())
+	w.Header().Add("ETag", entityTag(cache))
+}
+
+// entityTag returns the strong entity tag of the cached response, which is
+// the quoted SHA-1 hash of the response (RFC 7232: 2.3. ETag).
+func entityTag(cache *cache.ResponseCache) string {
+	return `"` + cache.SHA1HashHexString() + `"`
+}
+
+// notModified evaluates the preconditions of the conditional GET or HEAD request
+// (RFC 7232: 6. Precedence). If-Modified-Since is evaluated only when
+// If-None-Match is not present.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		// The weak comparison is used for If-None-Match
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.Truncate(time.Second).After(since)
 }
 
 var ErrUnexpectedHTTPMethod = errors.New("unexpected HTTP method")
@@ -187,7 +466,7 @@ func readOCSPRequestBody(r *http.Request, maxRequestBytes int) ([]byte, error) {
 	var err error
 
 	switch r.Method {
-	case http.MethodGet:
+	case http.MethodGet, http.MethodHead:
 		reqPath := strings.TrimPrefix(r.URL.Path, "/")
 		if maxRequestBytes > 0 && len(reqPath) > base64.StdEncoding.EncodedLen(maxRequestBytes) {
 			return nil, errRequestTooLarge
@@ -204,7 +483,7 @@ func readOCSPRequestBody(r *http.Request, maxRequestBytes int) ([]byte, error) {
 	if maxRequestBytes > 0 && len(body) > maxRequestBytes {
 		return nil, errRequestTooLarge
 	}
-	if r.Method == http.MethodGet && len(body) > GETMethodMaxRequestSize {
+	if r.Method != http.MethodPost && len(body) > GETMethodMaxRequestSize {
 		return nil, errRequestTooLarge
 	}
 
@@ -214,10 +493,25 @@ func readOCSPRequestBody(r *http.Request, maxRequestBytes int) ([]byte, error) {
 // ServeHTTP handles an OCSP request with following  steps.
 //   - Verify that the request is in the correct form of an OCSP request.
 //     If the request is Malformed, it sends ocsp.MalformedRequestErrorResponse.
-//   - Check if the issuer is correct.
-//     If the issuer is not valid, it sends ocsp.UnauthorizedErrorRespons.
+//   - If the request has multiple CertIDs (WithMaxCertIDs), it signs a response
+//     for all of them on demand.
+//   - Check if the issuer is correct, and select the responder of the issuer.
+//     If no configured issuer matches, it sends ocsp.UnauthorizedErrorRespons.
 //   - Searche for a response cache using the serial number from the request.
-//     If the cache is not found, it sends ocsp.UnauthorizedErrorRespons.
+//     If the cache is not found, it sends ocsp.UnauthorizedErrorRespons, or
+//     the negative response of the unknown or revoked status (WithCacheMiss).
+//     If the negative response must be signed but the signers are busy, it
+//     sends ocsp.TryLaterErrorResponse.
+//   - If nonce signing is enabled and the request carries a nonce, it signs
+//     the response of the cache with the nonce echoed on demand.
+//   - If the CertID is not hashed with SHA-1, it sends the response of the
+//     cache signed for the hash algorithm. If the response must be signed but
+//     the signers are busy, it sends ocsp.TryLaterErrorResponse.
+//   - If the GET or HEAD request is conditional and the cached response is not
+//     modified, it sends http.StatusNotModified without the body.
+//
+// The HEAD request is handled same as the GET request, but only the headers
+// are sent.
 //
 // This Handler add headers Headers introduced in RFC5019.
 // (https://www.rfc-editor.org/rfc/rfc5019#section-5)
@@ -249,11 +543,29 @@ func (c CacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if c.maxCertIDs > 1 {
+		reqs, err := parseRequestCertIDs(body)
+		if err != nil || len(reqs) > c.maxCertIDs {
+			logger.Debug().Err(err).Int("cert_ids", len(reqs)).Bytes("ocsp-request-bytes", body).Msg("")
+			_, err = w.Write(ocsp.MalformedRequestErrorResponse)
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+			}
+			return
+		}
+		if len(reqs) > 1 {
+			logger = logger.With().Int("cert_ids", len(reqs)).Logger()
+			logger.Debug().Msg("Received OCSP Request with multiple CertIDs.")
+			c.writeMultiResponse(w, r, body, reqs, &logger)
+			return
+		}
+	}
+
 	logger = logger.With().Str("serial", ocspReq.SerialNumber.Text(db.SerialBase)).Logger()
 	logger.Debug().Msg("Received OCSP Request.")
 
 	// Check issuer is collect
-	err = verifyIssuer(ocspReq, c.responder)
+	auth, err := c.matchAuthority(ocspReq)
 	if err != nil {
 		logger.Error().Err(err).Msg("")
 		_, err = w.Write(ocsp.UnauthorizedErrorResponse)
@@ -263,7 +575,27 @@ func (c CacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cache, ok := c.cacheStore.Get(ocspReq.SerialNumber)
+	logger = logger.With().Str("issuer", auth.responder.issuerCert.Subject.String()).Logger()
+
+	nowT := c.now()
+
+	var exts []pkix.Extension
+	cache, ok := auth.cacheStore.Get(ocspReq.SerialNumber)
+	hashed := ok
+	if !ok && c.cacheMiss != CacheMissUnauthorized {
+		logger.Debug().Msgf("Request serial not matched, the negative response is sent.")
+		cache, err = c.negativeCache(auth, ocspReq, nowT, &logger)
+		if errors.Is(err, errNegativeSignersBusy) {
+			logger.Warn().Msg("Negative signing limit reached, tryLater is sent.")
+			_, err = w.Write(ocsp.TryLaterErrorResponse)
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+			}
+			return
+		}
+		ok = err == nil
+		exts = c.cacheMiss.responseExtensions()
+	}
 	if !ok {
 		logger.Error().Msgf("Request serial not matched.")
 		_, err = w.Write(ocsp.UnauthorizedErrorResponse)
@@ -273,7 +605,6 @@ func (c CacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	nowT := c.now()
 	if cmp := nowT.Compare(cache.Template().NextUpdate); cmp > 0 {
 		logger.Error().Msgf("nextUpdate of found cache is set in the past.")
 		_, err = w.Write(ocsp.UnauthorizedErrorResponse)
@@ -283,9 +614,134 @@ func (c CacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The response to the HEAD request has no body to be signed
+	if c.nonceSem != nil && r.Method != http.MethodHead {
+		nonce, ok, err := parseRequestNonce(body, c.nonceMaxLength)
+		if err != nil {
+			logger.Debug().Err(err).Bytes("ocsp-request-bytes", body).Msg("")
+			_, err = w.Write(ocsp.MalformedRequestErrorResponse)
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+			}
+			return
+		}
+		if ok && c.writeNonceResponse(w, r, auth.responder, cache, ocspReq, nonce, exts, nowT, &logger) {
+			return
+		}
+	}
+
+	// The negative responses are already signed for the hash algorithm
+	if hashed && ocspReq.HashAlgorithm != crypto.SHA1 {
+		cache, err = c.hashCache(auth, cache, ocspReq.HashAlgorithm, nowT, &logger)
+		if errors.Is(err, errHashSignersBusy) {
+			logger.Warn().Msg("Hash signing limit reached, tryLater is sent.")
+			_, err = w.Write(ocsp.TryLaterErrorResponse)
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+			}
+			return
+		}
+		if err != nil {
+			_, err = w.Write(ocsp.InternalErrorErrorResponse)
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+			}
+			return
+		}
+	}
+
+	observeSuccess(r, certStatusLabels[cache.Template().Status])
 	addSuccessOCSPResHeader(w, cache, nowT, c.maxAge)
+	if notModified(r, entityTag(cache), cache.Template().ProducedAt) {
+		// 304 has no representation of the response
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.Itoa(len(cache.Response())))
+		return
+	}
+
 	_, err = cache.Write(w)
 	if err != nil {
 		logger.Error().Err(err).Msg("")
 	}
 }
+
+// hashCache returns the response of the cache signed for the hash algorithm of
+// the CertID of the request, because the cache is signed for the SHA-1 CertID.
+// If it is not stored, the response is signed and stored until the cache is
+// updated. The requests of the hash algorithms are not limited, so the
+// signings are limited by the hash signers, and errHashSignersBusy is returned
+// when the limit is reached.
+func (c CacheHandler) hashCache(
+	auth authority, source *cache.ResponseCache, hash crypto.Hash, nowT time.Time, logger *zerolog.Logger,
+) (*cache.ResponseCache, error) {
+	if resCache, ok := auth.hashed.Get(source, hash, nowT); ok {
+		return resCache, nil
+	}
+
+	select {
+	case c.hashSem <- struct{}{}:
+		defer func() { <-c.hashSem }()
+	default:
+		return nil, errHashSignersBusy
+	}
+
+	resCache := *source
+	resCache.SetIssuerHashToTemplate(hash)
+	resCache, err := auth.responder.SignCacheResponse(resCache)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to sign the response for the %s CertID.", hash)
+		return nil, err
+	}
+
+	auth.hashed.Add(source, resCache, hash)
+	return &resCache, nil
+}
+
+// writeNonceResponse signs the response of the cache with the nonce echoed and
+// the extensions, and writes it. It returns false when the response is not written, and then the
+// cached response should be written instead.
+func (c CacheHandler) writeNonceResponse(
+	w http.ResponseWriter,
+	r *http.Request,
+	responder *Responder,
+	cache *cache.ResponseCache,
+	ocspReq *ocsp.Request,
+	nonce pkix.Extension,
+	exts []pkix.Extension,
+	nowT time.Time,
+	logger *zerolog.Logger,
+) bool {
+	select {
+	case c.nonceSem <- struct{}{}:
+		defer func() { <-c.nonceSem }()
+	default:
+		logger.Warn().Msg("Nonce signing limit reached, the cached response is sent.")
+		return false
+	}
+
+	tmpl := cache.Template()
+	// The CertID of the response matches the request
+	tmpl.IssuerHash = ocspReq.HashAlgorithm
+
+	res, err := responder.SignNonceResponse(tmpl, nonce, exts...)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to sign the nonce response, the cached response is sent.")
+		return false
+	}
+
+	observeSuccess(r, certStatusLabels[tmpl.Status])
+	// The response is unique to the request, so it must not be cached
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Date", nowT.Format(http.TimeFormat))
+	_, err = w.Write(res)
+	if err != nil {
+		logger.Error().Err(err).Msg("")
+	}
+
+	return true
+}
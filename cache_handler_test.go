@@ -2,6 +2,9 @@ package dyocsp
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/base64"
 	"io"
 	"math/big"
@@ -113,6 +116,9 @@ func TestCacheHandler_ServeHTTP_Methods(t *testing.T) {
 		if method == http.MethodGet {
 			code = http.StatusOK
 		}
+		if method == http.MethodHead {
+			code = http.StatusOK
+		}
 		if res.StatusCode != code {
 			t.Errorf("Method %s Expected status code is %d but got: %d", method, code, res.StatusCode)
 		}
@@ -322,7 +328,7 @@ func testHTTPResHeader(t *testing.T, header http.Header, ocspRes *ocsp.Response)
 	}
 
 	// RFC5019: ETag: "<strong validator (SHA1 hash of the OCSPResponse structure)>"
-	if len(header.Get("ETag")) != 40 {
+	if matched, _ := regexp.MatchString(`\A"[0-9a-f]{40}"\z`, header.Get("ETag")); !matched {
 		t.Errorf("Expoected ETag is SHA1 Hash but got: %s", header.Get("ETag"))
 	}
 }
@@ -555,3 +561,652 @@ func TestCacheHandler_ServeHTTP_MaxAgeOverNextUpdate(t *testing.T) {
 		t.Errorf("max-age must not be over duration to nextUpdate (%d).: %d", interval, maxAge)
 	}
 }
+
+func testCreateCacheForCert(
+	t *testing.T, responder *Responder, serial *big.Int, interval int,
+) cache.ResponseCache {
+	t.Helper()
+
+	entry := db.CertificateEntry{
+		Ca:        "ca",
+		Serial:    serial,
+		RevType:   "V",
+		ExpDate:   time.Date(2033, 8, 9, 12, 33, 17, 0, time.UTC),
+		RevDate:   time.Time{},
+		CRLReason: db.NotRevoked,
+	}
+
+	resCache, err := cache.CreatePreSignedResponseCache(entry, date.NowGMT(), time.Second*time.Duration(interval))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resCache, err = responder.SignCacheResponse(resCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resCache
+}
+
+func TestCacheHandler_ServeHTTP_MultipleResponders(t *testing.T) {
+	t.Parallel()
+
+	delegated := testCreateDelegatedResponder(t)
+	direct := testCreateDirectResponder(t)
+
+	delegatedStore := cache.NewResponseCacheStore()
+	delegatedStore.Update([]cache.ResponseCache{
+		testCreateCacheForCert(t, delegated, delegated.rCert.SerialNumber, 500),
+	})
+	directStore := cache.NewResponseCacheStore()
+	directStore.Update([]cache.ResponseCache{
+		testCreateCacheForCert(t, direct, direct.rCert.SerialNumber, 500),
+	})
+
+	handler := NewCacheHandler(
+		delegatedStore.NewReadOnlyCacheStore(), delegated, alice.New(),
+		WithResponder(directStore.NewReadOnlyCacheStore(), direct),
+		WithMaxRequestBytes(512), WithMaxAge(256),
+	)
+
+	data := []struct {
+		testCase  string
+		responder *Responder
+	}{
+		{"route to the delegated responder", delegated},
+		{"route to the direct responder", direct},
+	}
+
+	for _, d := range data {
+		rawReq, err := ocsp.CreateRequest(d.responder.rCert, d.responder.issuerCert, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(rawReq))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		ocspRes, err := ocsp.ParseResponse(rec.Body.Bytes(), d.responder.rCert)
+		if err != nil {
+			t.Fatalf("%s: %s", d.testCase, err)
+		}
+		if ocspRes.SerialNumber.Cmp(d.responder.rCert.SerialNumber) != 0 {
+			t.Errorf("%s: Expected serial %x but got: %x",
+				d.testCase, d.responder.rCert.SerialNumber, ocspRes.SerialNumber)
+		}
+	}
+}
+
+func TestCacheHandler_ServeHTTP_MultipleResponders_NoIssuerMatched(t *testing.T) {
+	t.Parallel()
+
+	delegated := testCreateDelegatedResponder(t)
+	direct := testCreateDirectResponder(t)
+	self := testCreateSelfSignedResponder(t)
+
+	handler := NewCacheHandler(
+		cache.NewResponseCacheStore().NewReadOnlyCacheStore(), direct, alice.New(),
+		WithResponder(cache.NewResponseCacheStore().NewReadOnlyCacheStore(), self),
+		WithMaxRequestBytes(512), WithMaxAge(256),
+	)
+
+	rawReq, err := ocsp.CreateRequest(delegated.rCert, delegated.issuerCert, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(rawReq))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !reflect.DeepEqual(rec.Body.Bytes(), ocsp.UnauthorizedErrorResponse) {
+		t.Fatal("Expected Unauthorized Error Response but got different bytes")
+	}
+}
+
+func TestCacheHandler_ServeHTTP_HashAlgorithms(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDelegatedResponder(t)
+	cacheStore := cache.NewResponseCacheStore()
+	cacheStore.Update([]cache.ResponseCache{
+		testCreateCacheForCert(t, responder, responder.rCert.SerialNumber, 500),
+	})
+
+	handler := NewCacheHandler(
+		cacheStore.NewReadOnlyCacheStore(), responder, alice.New(),
+		WithMaxRequestBytes(512), WithMaxAge(256),
+	)
+
+	serve := func(alg crypto.Hash) ([]byte, *ocsp.Response) {
+		rawReq, err := ocsp.CreateRequest(responder.rCert, responder.issuerCert, &ocsp.RequestOptions{Hash: alg})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(rawReq))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		ocspRes, err := ocsp.ParseResponse(rec.Body.Bytes(), responder.rCert)
+		if err != nil {
+			t.Fatalf("%s: %s", alg, err)
+		}
+		return rec.Body.Bytes(), ocspRes
+	}
+
+	for _, alg := range []crypto.Hash{crypto.SHA1, crypto.SHA256, crypto.SHA384, crypto.SHA512} {
+		res, ocspRes := serve(alg)
+		if ocspRes.SerialNumber.Cmp(responder.rCert.SerialNumber) != 0 {
+			t.Errorf("%s: Expected serial %x but got: %x", alg, responder.rCert.SerialNumber, ocspRes.SerialNumber)
+		}
+		// The CertID of the response must match the request
+		if ocspRes.IssuerHash != alg {
+			t.Errorf("%s: Expected CertID hash algorithm %s but got: %s", alg, alg, ocspRes.IssuerHash)
+		}
+
+		// The signed response for the hash algorithm is cached
+		if again, _ := serve(alg); !bytes.Equal(res, again) {
+			t.Errorf("%s: Expected the cached response but got another response", alg)
+		}
+	}
+
+	// The response for the hash algorithm is signed again when the cache is updated
+	_, before := serve(crypto.SHA256)
+	time.Sleep(time.Second)
+	cacheStore.Update([]cache.ResponseCache{
+		testCreateCacheForCert(t, responder, responder.rCert.SerialNumber, 500),
+	})
+	_, after := serve(crypto.SHA256)
+	if after.IssuerHash != crypto.SHA256 || !after.ThisUpdate.After(before.ThisUpdate) {
+		t.Errorf("Expected the response signed for the updated cache but got this update: %s", after.ThisUpdate)
+	}
+}
+
+func testCreateNonceRequest(t *testing.T, responder *Responder, alg crypto.Hash, nonce []byte) []byte {
+	t.Helper()
+
+	rawReq, err := ocsp.CreateRequest(responder.rCert, responder.issuerCert, &ocsp.RequestOptions{Hash: alg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req ocspRequestASN1
+	if _, err := asn1.Unmarshal(rawReq, &req); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := asn1.Marshal(nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.TBSRequest.RequestExtensions = []pkix.Extension{{Id: oidOCSPNonce, Value: value}}
+
+	nonceReq, err := asn1.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return nonceReq
+}
+
+func testResponseExtensions(t *testing.T, der []byte) []pkix.Extension {
+	t.Helper()
+
+	var res responseASN1
+	if _, err := asn1.Unmarshal(der, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	var basic basicResponse
+	if _, err := asn1.Unmarshal(res.Response.Response, &basic); err != nil {
+		t.Fatal(err)
+	}
+
+	return basic.TBSResponseData.ResponseExtensions
+}
+
+func TestCacheHandler_ServeHTTP_Nonce(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDelegatedResponder(t)
+	resCache := testCreateCacheForCert(t, responder, responder.rCert.SerialNumber, 500)
+	cacheStore := cache.NewResponseCacheStore()
+	cacheStore.Update([]cache.ResponseCache{resCache})
+
+	nonce := bytes.Repeat([]byte{0x01}, 16)
+
+	data := []struct {
+		testcase string
+		opts     []CacheHandlerOption
+		nonce    []byte
+		// want
+		signed    bool
+		malformed bool
+	}{
+		{"nonce is echoed", []CacheHandlerOption{WithNonceSigning(1)}, nonce, true, false},
+		{"no nonce uses the cache", []CacheHandlerOption{WithNonceSigning(1)}, nil, false, false},
+		{"nonce is ignored when disabled", nil, nonce, false, false},
+		{
+			"max length nonce is echoed",
+			[]CacheHandlerOption{WithNonceSigning(1), WithNonceMaxLength(16)},
+			nonce, true, false,
+		},
+		{
+			"too long nonce is malformed",
+			[]CacheHandlerOption{WithNonceSigning(1), WithNonceMaxLength(15)},
+			nonce, false, true,
+		},
+		{
+			"too long nonce by RFC 8954 is malformed",
+			[]CacheHandlerOption{WithNonceSigning(1), WithNonceMaxLength(64)},
+			bytes.Repeat([]byte{0x01}, 33), false, true,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			handler := NewCacheHandler(cacheStore.NewReadOnlyCacheStore(), responder, alice.New(), d.opts...)
+
+			var rawReq []byte
+			if d.nonce == nil {
+				var err error
+				rawReq, err = ocsp.CreateRequest(
+					responder.rCert, responder.issuerCert, &ocsp.RequestOptions{Hash: crypto.SHA256},
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				rawReq = testCreateNonceRequest(t, responder, crypto.SHA256, d.nonce)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(rawReq))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if d.malformed {
+				if !bytes.Equal(rec.Body.Bytes(), ocsp.MalformedRequestErrorResponse) {
+					t.Fatal("Expected Malformed Request Error Response but got different bytes")
+				}
+				return
+			}
+
+			// The cached response does not embed the responder certificate
+			verifier := responder.issuerCert
+			if !d.signed {
+				verifier = responder.rCert
+			}
+			ocspRes, err := ocsp.ParseResponse(rec.Body.Bytes(), verifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ocspRes.IssuerHash != crypto.SHA256 {
+				t.Errorf("Expected CertID hash is SHA256 but got: %s", ocspRes.IssuerHash)
+			}
+			if ocspRes.SerialNumber.Cmp(responder.rCert.SerialNumber) != 0 {
+				t.Errorf("Expected serial %x but got: %x", responder.rCert.SerialNumber, ocspRes.SerialNumber)
+			}
+
+			exts := testResponseExtensions(t, rec.Body.Bytes())
+			if !d.signed {
+				// The cached response for the SHA-256 CertID has no nonce
+				if len(exts) != 0 {
+					t.Errorf("Expected no extension in the cached response but got: %v", exts)
+				}
+				return
+			}
+
+			if len(exts) != 1 || !exts[0].Id.Equal(oidOCSPNonce) {
+				t.Fatalf("Nonce extension is not found: %v", exts)
+			}
+			var echoed []byte
+			if _, err := asn1.Unmarshal(exts[0].Value, &echoed); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(echoed, d.nonce) {
+				t.Errorf("Expected nonce %x but got: %x", d.nonce, echoed)
+			}
+
+			testTextHeader(t, "Cache-Control", rec.Header(), "no-store")
+		})
+	}
+}
+
+func TestCacheHandler_ServeHTTP_Nonce_SigningLimit(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDelegatedResponder(t)
+	resCache := testCreateCacheForCert(t, responder, responder.rCert.SerialNumber, 500)
+	cacheStore := cache.NewResponseCacheStore()
+	cacheStore.Update([]cache.ResponseCache{resCache})
+
+	handler := CacheHandler{
+		authorities:    []authority{{cacheStore: cacheStore.NewReadOnlyCacheStore(), responder: responder}},
+		now:            date.NowGMT,
+		logger:         &log.Logger,
+		nonceMaxLength: NonceMaxLength,
+		nonceSem:       make(chan struct{}, 1),
+	}
+	// All signers are busy
+	handler.nonceSem <- struct{}{}
+
+	rawReq := testCreateNonceRequest(t, responder, crypto.SHA1, []byte{0x01})
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(rawReq))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !bytes.Equal(rec.Body.Bytes(), resCache.Response()) {
+		t.Fatal("Expected the cached response but got different bytes")
+	}
+}
+
+func TestCacheHandler_ServeHTTP_CacheMiss(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDelegatedResponder(t)
+	// The serial of the request is not found in the empty cache store
+	cacheStore := cache.NewResponseCacheStore()
+	nonce := bytes.Repeat([]byte{0x01}, 16)
+
+	data := []struct {
+		testcase string
+		opts     []CacheHandlerOption
+		nonce    []byte
+		status   int
+		exts     []asn1.ObjectIdentifier
+	}{
+		{"unauthorized by default", nil, nil, -1, nil},
+		{"unknown", []CacheHandlerOption{WithCacheMiss(CacheMissUnknown)}, nil, ocsp.Unknown, nil},
+		{
+			"revoked",
+			[]CacheHandlerOption{WithCacheMiss(CacheMissRevoked)},
+			nil, ocsp.Revoked, []asn1.ObjectIdentifier{oidExtendedRevoke},
+		},
+		{
+			"revoked with nonce",
+			[]CacheHandlerOption{WithCacheMiss(CacheMissRevoked), WithNonceSigning(1)},
+			nonce, ocsp.Revoked, []asn1.ObjectIdentifier{oidOCSPNonce, oidExtendedRevoke},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			opts := append([]CacheHandlerOption{WithNegativeCacheTTL(30 * time.Second)}, d.opts...)
+			handler := NewCacheHandler(cacheStore.NewReadOnlyCacheStore(), responder, alice.New(), opts...)
+
+			var rawReq []byte
+			if d.nonce == nil {
+				var err error
+				rawReq, err = ocsp.CreateRequest(
+					responder.rCert, responder.issuerCert, &ocsp.RequestOptions{Hash: crypto.SHA256},
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				rawReq = testCreateNonceRequest(t, responder, crypto.SHA256, d.nonce)
+			}
+
+			serve := func() []byte {
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(rawReq))
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				return rec.Body.Bytes()
+			}
+
+			body := serve()
+			if d.status < 0 {
+				if !bytes.Equal(body, ocsp.UnauthorizedErrorResponse) {
+					t.Fatal("Expected Unauthorized Error Response but got different bytes")
+				}
+				return
+			}
+
+			ocspRes, err := ocsp.ParseResponse(body, responder.issuerCert)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ocspRes.Status != d.status {
+				t.Errorf("Expected status %d but got: %d", d.status, ocspRes.Status)
+			}
+			if ocspRes.IssuerHash != crypto.SHA256 {
+				t.Errorf("Expected CertID hash is SHA256 but got: %s", ocspRes.IssuerHash)
+			}
+			if ocspRes.NextUpdate.Sub(ocspRes.ThisUpdate) != 30*time.Second {
+				t.Errorf("Expected Next Update is 30s after This Update but got: %v - %v",
+					ocspRes.ThisUpdate, ocspRes.NextUpdate)
+			}
+			if d.status == ocsp.Revoked {
+				if !ocspRes.RevokedAt.Equal(time.Unix(0, 0)) {
+					t.Errorf("Expected revocation time is 1970-01-01 but got: %v", ocspRes.RevokedAt)
+				}
+				if ocspRes.RevocationReason != ocsp.CertificateHold {
+					t.Errorf("Expected reason is certificateHold but got: %d", ocspRes.RevocationReason)
+				}
+			}
+
+			exts := testResponseExtensions(t, body)
+			if len(exts) != len(d.exts) {
+				t.Fatalf("Expected extensions %v but got: %v", d.exts, exts)
+			}
+			for idx := range exts {
+				if !exts[idx].Id.Equal(d.exts[idx]) {
+					t.Errorf("Expected extension %v but got: %v", d.exts[idx], exts[idx].Id)
+				}
+			}
+
+			// The negative response is cached
+			if d.nonce == nil && !bytes.Equal(body, serve()) {
+				t.Error("Expected the cached negative response but got different bytes")
+			}
+		})
+	}
+}
+
+func TestCacheHandler_ServeHTTP_Conditional(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDelegatedResponder(t)
+	resCache := testCreateDummyCache(t, responder, 500)
+	cacheStore := cache.NewResponseCacheStore()
+	cacheStore.Update([]cache.ResponseCache{resCache})
+
+	handler := NewCacheHandler(cacheStore.NewReadOnlyCacheStore(), responder, alice.New(), WithMaxAge(256))
+
+	rawReq, err := ocsp.CreateRequest(responder.rCert, responder.issuerCert, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqPath := "/" + base64.StdEncoding.EncodeToString(rawReq)
+
+	etag := `"` + resCache.SHA1HashHexString() + `"`
+	producedAt := resCache.Template().ProducedAt
+	if producedAt.IsZero() {
+		t.Fatal("ProducedAt of the cache is not set")
+	}
+
+	data := []struct {
+		testcase string
+		method   string
+		header   map[string]string
+		code     int
+	}{
+		{"GET without conditions", http.MethodGet, nil, http.StatusOK},
+		{"GET with matched If-None-Match", http.MethodGet, map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"GET with weak If-None-Match", http.MethodGet, map[string]string{"If-None-Match": "W/" + etag}, http.StatusNotModified},
+		{
+			"GET with If-None-Match list",
+			http.MethodGet, map[string]string{"If-None-Match": `"other", ` + etag}, http.StatusNotModified,
+		},
+		{"GET with If-None-Match any", http.MethodGet, map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"GET with unmatched If-None-Match", http.MethodGet, map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{
+			"GET with unquoted If-None-Match",
+			http.MethodGet, map[string]string{"If-None-Match": resCache.SHA1HashHexString()}, http.StatusOK,
+		},
+		{
+			"If-None-Match takes precedence over If-Modified-Since",
+			http.MethodGet,
+			map[string]string{
+				"If-None-Match":     `"other"`,
+				"If-Modified-Since": producedAt.Add(time.Hour).Format(http.TimeFormat),
+			},
+			http.StatusOK,
+		},
+		{
+			"GET with If-Modified-Since at Last-Modified",
+			http.MethodGet,
+			map[string]string{"If-Modified-Since": producedAt.Format(http.TimeFormat)},
+			http.StatusNotModified,
+		},
+		{
+			"GET with If-Modified-Since before Last-Modified",
+			http.MethodGet,
+			map[string]string{"If-Modified-Since": producedAt.Add(-time.Second).Format(http.TimeFormat)},
+			http.StatusOK,
+		},
+		{"GET with invalid If-Modified-Since", http.MethodGet, map[string]string{"If-Modified-Since": "ng"}, http.StatusOK},
+		{"HEAD without conditions", http.MethodHead, nil, http.StatusOK},
+		{"HEAD with matched If-None-Match", http.MethodHead, map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(d.method, reqPath, nil)
+			for key, value := range d.header {
+				req.Header.Set(key, value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != d.code {
+				t.Fatalf("Expected status code is %d but got: %d", d.code, rec.Code)
+			}
+			testTextHeader(t, "ETag", rec.Header(), etag)
+			testTimeHeader(t, "Last-Modified", rec.Header(), producedAt)
+
+			switch {
+			case d.code == http.StatusNotModified || d.method == http.MethodHead:
+				if rec.Body.Len() != 0 {
+					t.Errorf("Expected empty body but got %d bytes", rec.Body.Len())
+				}
+			case !bytes.Equal(rec.Body.Bytes(), resCache.Response()):
+				t.Error("Expected the cached response but got different bytes")
+			}
+
+			if d.method == http.MethodHead && d.code == http.StatusOK {
+				testTextHeader(t, "Content-Length", rec.Header(), strconv.Itoa(len(resCache.Response())))
+			}
+		})
+	}
+
+	// Conditions are not evaluated for POST
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(rawReq))
+	req.Header.Set("If-None-Match", etag)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), resCache.Response()) {
+		t.Errorf("Expected the cached response for POST but got status code: %d", rec.Code)
+	}
+}
+
+func TestCacheHandler_ServeHTTP_CacheMissSignersBusy(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDelegatedResponder(t)
+	cacheStore := cache.NewResponseCacheStore()
+
+	handler := CacheHandler{
+		authorities: []authority{{
+			cacheStore: cacheStore.NewReadOnlyCacheStore(),
+			responder:  responder,
+			negatives:  cache.NewNegativeCacheStore(DefaultNegativeCacheSize),
+		}},
+		now:              date.NowGMT,
+		logger:           &log.Logger,
+		cacheMiss:        CacheMissUnknown,
+		negativeCacheTTL: DefaultNegativeCacheTTL,
+		negativeSem:      make(chan struct{}, 1),
+	}
+
+	serve := func(serial int64) []byte {
+		rawReq := testCreateMultiRequest(t, []testCertID{{responder, big.NewInt(serial), crypto.SHA1}})
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(rawReq))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Body.Bytes()
+	}
+
+	cached := serve(1)
+	if _, err := ocsp.ParseResponse(cached, responder.issuerCert); err != nil {
+		t.Fatal(err)
+	}
+
+	// All signers are busy
+	handler.negativeSem <- struct{}{}
+
+	if !bytes.Equal(serve(2), ocsp.TryLaterErrorResponse) {
+		t.Error("Expected Try Later Error Response but got different bytes")
+	}
+	// The cached negative response is sent without signing
+	if !bytes.Equal(serve(1), cached) {
+		t.Error("Expected the cached negative response but got different bytes")
+	}
+}
+
+func TestCacheHandler_ServeHTTP_HashSignersBusy(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDelegatedResponder(t)
+	cacheStore := cache.NewResponseCacheStore()
+	cacheStore.Update([]cache.ResponseCache{
+		testCreateCacheForCert(t, responder, responder.rCert.SerialNumber, 500),
+	})
+
+	handler := CacheHandler{
+		authorities: []authority{{
+			cacheStore: cacheStore.NewReadOnlyCacheStore(),
+			responder:  responder,
+			hashed:     cache.NewHashCacheStore(DefaultHashCacheSize),
+		}},
+		now:     date.NowGMT,
+		logger:  &log.Logger,
+		hashSem: make(chan struct{}, 1),
+	}
+
+	serve := func(alg crypto.Hash) []byte {
+		rawReq := testCreateMultiRequest(t, []testCertID{{responder, responder.rCert.SerialNumber, alg}})
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(rawReq))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Body.Bytes()
+	}
+
+	cached := serve(crypto.SHA256)
+	if _, err := ocsp.ParseResponse(cached, responder.rCert); err != nil {
+		t.Fatal(err)
+	}
+
+	// All signers are busy
+	handler.hashSem <- struct{}{}
+
+	if !bytes.Equal(serve(crypto.SHA384), ocsp.TryLaterErrorResponse) {
+		t.Error("Expected Try Later Error Response but got different bytes")
+	}
+	// The cached response for the hash algorithm is sent without signing
+	if !bytes.Equal(serve(crypto.SHA256), cached) {
+		t.Error("Expected the cached response but got different bytes")
+	}
+	// The response for the SHA-1 CertID is not signed
+	if _, err := ocsp.ParseResponse(serve(crypto.SHA1), responder.rCert); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,97 @@
+package dyocsp
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yuxki/dyocsp/pkg/cache"
+	"golang.org/x/crypto/ocsp"
+)
+
+// RFC 6960: 4.4.8. Extended Revoked Definition.
+// (https://www.rfc-editor.org/rfc/rfc6960#section-4.4.8)
+var oidExtendedRevoke = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 9}
+
+// cacheMissBehavior is the response to a request whose serial number is not
+// found in the cache store of the known issuer.
+type cacheMissBehavior int
+
+const (
+	// Respond with ocsp.UnauthorizedErrorResponse.
+	CacheMissUnauthorized cacheMissBehavior = iota
+	// Respond with a signed response of the unknown status.
+	CacheMissUnknown
+	// Respond with a signed response of the revoked status, following the
+	// extended revoked definition of RFC 6960. The revocation reason is
+	// certificateHold, and the revocation time is January 1, 1970.
+	CacheMissRevoked
+)
+
+// Default values of the negative cache.
+const (
+	DefaultNegativeCacheTTL     = time.Minute
+	DefaultNegativeCacheSize    = 10000
+	DefaultNegativeCacheSigners = 8
+)
+
+// errNegativeSignersBusy is returned when the negative response is not cached,
+// and the maximum number of the negative responses are being signed.
+var errNegativeSignersBusy = errors.New("negative response signers are busy")
+
+func (b cacheMissBehavior) status() int {
+	if b == CacheMissRevoked {
+		return ocsp.Revoked
+	}
+	return ocsp.Unknown
+}
+
+// responseExtensions returns the response extensions of the negative response.
+// The extended revoke extension must be included in the revoked response for
+// the non-issued certificate.
+func (b cacheMissBehavior) responseExtensions() []pkix.Extension {
+	if b != CacheMissRevoked {
+		return nil
+	}
+	return []pkix.Extension{{Id: oidExtendedRevoke, Value: asn1.NullBytes}}
+}
+
+// negativeCache returns the signed negative response of the request from the
+// negative cache store. If it is not stored, the response is signed and stored
+// for the negative cache TTL. The serial numbers of the requests are not limited,
+// so the signings are limited by the negative signers, and errNegativeSignersBusy
+// is returned when the limit is reached.
+func (c CacheHandler) negativeCache(
+	auth authority, ocspReq *ocsp.Request, nowT time.Time, logger *zerolog.Logger,
+) (*cache.ResponseCache, error) {
+	if resCache, ok := auth.negatives.Get(ocspReq.SerialNumber, ocspReq.HashAlgorithm, nowT); ok {
+		return resCache, nil
+	}
+
+	select {
+	case c.negativeSem <- struct{}{}:
+		defer func() { <-c.negativeSem }()
+	default:
+		return nil, errNegativeSignersBusy
+	}
+
+	resCache, err := cache.CreateNegativeResponseCache(
+		ocspReq.SerialNumber, ocspReq.HashAlgorithm, c.cacheMiss.status(), nowT, c.negativeCacheTTL,
+	)
+	if err != nil {
+		logger.Error().Err(err).Msg("")
+		return nil, err
+	}
+
+	resCache, err = auth.responder.SignNegativeResponse(resCache, c.cacheMiss.responseExtensions()...)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to sign the negative response.")
+		return nil, err
+	}
+
+	auth.negatives.Add(resCache, ocspReq.HashAlgorithm)
+
+	return &resCache, nil
+}
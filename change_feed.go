@@ -0,0 +1,168 @@
+package dyocsp
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yuxki/dyocsp/pkg/cache"
+	"github.com/yuxki/dyocsp/pkg/db"
+)
+
+// CADBChangeFeed is an interface that represents a feed of the changed entries
+// in a database. Watch sends the changes to the channel until the context is done
+// or an error occurs.
+type CADBChangeFeed interface {
+	Watch(ctx context.Context, changes chan<- []db.EntryChange) error
+}
+
+// watchChanges applies the changes received from the change feed until the
+// context is done. When the change feed fails, it watches the feed again after
+// the retry wait. The changes missed in the meantime are reconciled by the
+// next batch.
+// The snapshot is written once per the snapshot debounce after the changes
+// are applied, instead of after each change, and when the context is done.
+func (c *CacheBatch) watchChanges(ctx context.Context) {
+	logger := c.logger.With().Str("feed", "change").Logger()
+	ctx = logger.WithContext(ctx)
+
+	var saveSnapshot <-chan time.Time
+	defer func() {
+		if saveSnapshot != nil {
+			c.saveSnapshot(&logger)
+		}
+	}()
+
+	changes := make(chan []db.EntryChange)
+	for {
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- c.changeFeed.Watch(ctx, changes)
+		}()
+		logger.Info().Msg("Watching the change feed.")
+
+		var err error
+	watch:
+		for {
+			select {
+			case batch := <-changes:
+				c.applyChanges(ctx, batch)
+				if c.snapshotFile != "" && saveSnapshot == nil {
+					saveSnapshot = time.After(c.snapshotDebounce)
+				}
+			case <-saveSnapshot:
+				saveSnapshot = nil
+				c.saveSnapshot(&logger)
+			case err = <-errCh:
+				break watch
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Error().Err(err).Msgf("Change feed stopped, watching again in %v.", c.changeFeedRetry)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.changeFeedRetry):
+		}
+	}
+}
+
+// applyChanges signs the changed entries and swaps them into the cache store.
+// The caches of the removed or expired entries are deleted from the store.
+// The changed caches have the same Next Update as the served caches, so that
+// they are replaced by the next batch.
+func (c *CacheBatch) applyChanges(ctx context.Context, changes []db.EntryChange) {
+	logger := zerolog.Ctx(ctx)
+
+	c.genMu.Lock()
+	defer c.genMu.Unlock()
+
+	start := time.Now()
+
+	removed := make([]*big.Int, 0)
+	itmds := make([]db.IntermidiateEntry, 0, len(changes))
+	for idx := range changes {
+		if !changes[idx].Removed && changes[idx].Entry.RevType != "E" {
+			itmds = append(itmds, changes[idx].Entry)
+			continue
+		}
+
+		serial, ok := new(big.Int).SetString(changes[idx].Entry.Serial, db.SerialBase)
+		if !ok {
+			logger.Error().Msgf("Invalid serial of the removed entry: %s", changes[idx].Entry.Serial)
+			continue
+		}
+		removed = append(removed, serial)
+	}
+	logger.Debug().Msgf("List of changed entries from the change feed: %v", changes)
+
+	entries := c.parseEntries(itmds, logger)
+
+	thisUpdate := c.now()
+	interval := c.outOfCycleInterval(thisUpdate)
+
+	caches := make([]cache.ResponseCache, 0, len(entries))
+	for idx := range entries {
+		if resCache, ok := c.signEntry(entries[idx], thisUpdate, interval, logger); ok {
+			caches = append(caches, resCache)
+		}
+	}
+
+	invs := c.cacheStore.Patch(caches, removed)
+	for i := range invs {
+		logger.Error().Msgf("Invalid response cache: %s", invs[i].Entry().Serial)
+	}
+
+	if c.lastEntries != nil {
+		c.lastEntries = mergeEntries(c.lastEntries, entries, removed)
+	}
+
+	logger.Info().
+		Int("changed", len(caches)).
+		Int("removed", len(removed)).
+		Str("duration", fmt.Sprintf("%v", time.Since(start))).
+		Msg("Changes applied to the response caches.")
+}
+
+// saveSnapshot writes the cache store to the snapshot file. The lock keeps
+// the snapshot from being replaced by an older one than that of a batch.
+func (c *CacheBatch) saveSnapshot(logger *zerolog.Logger) {
+	c.genMu.Lock()
+	defer c.genMu.Unlock()
+
+	if err := c.cacheStore.SaveSnapshot(c.snapshotFile); err != nil {
+		logger.Error().Err(err).Msg("Failed to save the snapshot.")
+	}
+}
+
+// mergeEntries returns the entries that the changed entries replaced or added to,
+// and the removed entries are deleted from.
+func mergeEntries(
+	entries []db.CertificateEntry, changed []db.CertificateEntry, removed []*big.Int,
+) []db.CertificateEntry {
+	drop := make(map[string]struct{}, len(changed)+len(removed))
+	for idx := range changed {
+		drop[changed[idx].Serial.Text(db.SerialBase)] = struct{}{}
+	}
+	for idx := range removed {
+		drop[removed[idx].Text(db.SerialBase)] = struct{}{}
+	}
+
+	merged := make([]db.CertificateEntry, 0, len(entries)+len(changed))
+	for idx := range entries {
+		if entries[idx].Serial != nil {
+			if _, ok := drop[entries[idx].Serial.Text(db.SerialBase)]; ok {
+				continue
+			}
+		}
+		merged = append(merged, entries[idx])
+	}
+
+	return append(merged, changed...)
+}
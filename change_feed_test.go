@@ -0,0 +1,221 @@
+package dyocsp
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yuxki/dyocsp/pkg/cache"
+	"github.com/yuxki/dyocsp/pkg/date"
+	"github.com/yuxki/dyocsp/pkg/db"
+	"golang.org/x/crypto/ocsp"
+)
+
+var errStubWatch = errors.New("stub watch error")
+
+// StubChangeFeed fails the first watch when failFirst is true, and then sends
+// the changes in order.
+type StubChangeFeed struct {
+	changes   [][]db.EntryChange
+	failFirst bool
+	watches   int
+	mu        sync.Mutex
+}
+
+func (s *StubChangeFeed) Watch(ctx context.Context, changes chan<- []db.EntryChange) error {
+	s.mu.Lock()
+	s.watches++
+	first := s.watches == 1
+	s.mu.Unlock()
+
+	if first && s.failFirst {
+		return errStubWatch
+	}
+
+	for _, batch := range s.changes {
+		select {
+		case changes <- batch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func testWaitForCache(
+	t *testing.T, store *cache.ResponseCacheStore, serial int64, found func(*cache.ResponseCache, bool) bool,
+) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if found(store.Get(big.NewInt(serial))) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Change of the cache is not applied: %d", serial)
+}
+
+func TestCacheBatch_Run_ChangeFeed(t *testing.T) {
+	t.Parallel()
+
+	data := []struct {
+		testcase  string
+		failFirst bool
+	}{
+		{"changes are applied", false},
+		{"changes are applied after the feed failed", true},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			revoked := db.IntermidiateEntry{
+				Ca:        "test-ca",
+				Serial:    "01",
+				RevType:   "R",
+				ExpDate:   "330925234911Z",
+				RevDate:   "230925234911Z",
+				CRLReason: "keyCompromise",
+			}
+			feed := &StubChangeFeed{
+				changes: [][]db.EntryChange{
+					{
+						{Entry: revoked},
+						{Entry: db.IntermidiateEntry{Ca: "test-ca", Serial: "02"}, Removed: true},
+						{Entry: testIntermidiateEntries("03")[0]},
+					},
+				},
+				failFirst: d.failFirst,
+			}
+
+			client := StubCADBClient{"test-ca", testIntermidiateEntries("01", "02")}
+			responder := testCreateDelegatedResponder(t)
+			store := cache.NewResponseCacheStore()
+			notifyCh := make(chan struct{}, 1)
+			batch, err := NewCacheBatch(
+				"test-ca",
+				store,
+				client,
+				responder,
+				date.NowGMT(),
+				WithIntervalSec(60),
+				WithUpdatedNotifyChan(notifyCh),
+				WithChangeFeed(feed),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			batch.changeFeedRetry = 10 * time.Millisecond
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go batch.Run(ctx)
+			<-notifyCh
+
+			testWaitForCache(t, store, 1, func(c *cache.ResponseCache, ok bool) bool {
+				return ok && c.Template().Status == ocsp.Revoked
+			})
+			testWaitForCache(t, store, 2, func(_ *cache.ResponseCache, ok bool) bool {
+				return !ok
+			})
+			testWaitForCache(t, store, 3, func(_ *cache.ResponseCache, ok bool) bool {
+				return ok
+			})
+
+			batch.genMu.Lock()
+			defer batch.genMu.Unlock()
+
+			// The changed caches expire with the served caches
+			good := testGetCache(t, "03", store)
+			if !good.Template().NextUpdate.Equal(batch.servedNextUpdate) {
+				t.Errorf("Expected Next Update is %v but got: %v",
+					batch.servedNextUpdate, good.Template().NextUpdate)
+			}
+
+			// The next batch re-signs the changed entries in the resign mode
+			serials := make([]int64, 0, len(batch.lastEntries))
+			for _, entry := range batch.lastEntries {
+				serials = append(serials, entry.Serial.Int64())
+			}
+			if len(serials) != 2 || serials[0] != 1 || serials[1] != 3 {
+				t.Errorf("Unexpected last entries: %v", serials)
+			}
+		})
+	}
+}
+
+func TestCacheBatch_Run_ChangeFeedSnapshot(t *testing.T) {
+	t.Parallel()
+
+	feed := &StubChangeFeed{
+		changes: [][]db.EntryChange{
+			{{Entry: testIntermidiateEntries("02")[0]}},
+			{{Entry: testIntermidiateEntries("03")[0]}},
+		},
+	}
+
+	client := StubCADBClient{"test-ca", testIntermidiateEntries("01")}
+	store := cache.NewResponseCacheStore()
+	snapshotFile := filepath.Join(t.TempDir(), "test-ca.snapshot.json")
+	notifyCh := make(chan struct{}, 1)
+	batch, err := NewCacheBatch(
+		"test-ca",
+		store,
+		client,
+		testCreateDelegatedResponder(t),
+		date.NowGMT(),
+		WithIntervalSec(60),
+		WithUpdatedNotifyChan(notifyCh),
+		WithChangeFeed(feed),
+		WithSnapshotFile(snapshotFile),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The snapshot is not written by the changes until the context is done
+	batch.snapshotDebounce = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go batch.Run(ctx)
+	<-notifyCh
+
+	testWaitForCache(t, store, 3, func(_ *cache.ResponseCache, ok bool) bool {
+		return ok
+	})
+
+	loadSnapshot := func() int {
+		loaded := cache.NewResponseCacheStore()
+		count, _, err := loaded.LoadSnapshot(snapshotFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	batch.genMu.Lock()
+	count := loadSnapshot()
+	batch.genMu.Unlock()
+	if count != 1 {
+		t.Errorf("Expected the snapshot of the batch has 1 cache but got: %d", count)
+	}
+
+	// The pending changes are written when the context is done
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for loadSnapshot() != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("The changes are not written to the snapshot.")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
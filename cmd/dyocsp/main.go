@@ -1,19 +1,32 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
 	"errors"
 	"flag"
+	"fmt"
+	"io/fs"
 	stdlog "log"
+	"maps"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
 	"github.com/justinas/alice"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
 	"github.com/rs/zerolog/log"
 	"github.com/yuxki/dyocsp"
@@ -21,22 +34,51 @@ import (
 	"github.com/yuxki/dyocsp/pkg/config"
 	"github.com/yuxki/dyocsp/pkg/date"
 	"github.com/yuxki/dyocsp/pkg/db"
+	"github.com/yuxki/dyocsp/pkg/hsm"
 	"gopkg.in/yaml.v3"
 )
 
-func newResponder(cfg config.DyOCSPConfig) *dyocsp.Responder {
+// keyEnvSuffix returns the suffix of the environment variables of the key of
+// the CA in multi-CA mode, so that the CAs do not share a key by mistake. The
+// CA name is upper-cased, and the characters other than letters and digits are
+// replaced with '_' (e.g. "sub-ca" is "_SUB_CA").
+func keyEnvSuffix(ca string) string {
+	return "_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, ca)
+}
+
+// newResponder creates the responder of the CA. The private key, its
+// passphrase and the PIN of the PKCS #11 token are read from the environment
+// variables whose names end with envSuffix, if they are not set in the files.
+func newResponder(cfg config.DyOCSPConfig, envSuffix string) *dyocsp.Responder {
 	certPem, err := os.ReadFile(cfg.Certificate)
 	if err != nil {
 		stdlog.Fatalf("error:responder certificate: %v", err)
 	}
 
+	issuerCertPem, err := os.ReadFile(cfg.Issuer)
+	if err != nil {
+		stdlog.Fatalf("error:issuer certificate: %v", err)
+	}
+
+	keyEnv := "DYOCSP_PRIVATE_KEY" + envSuffix
+	if cfg.PKCS11Module != "" {
+		return newPKCS11Responder(cfg, keyEnv, "DYOCSP_PKCS11_PIN"+envSuffix, certPem, issuerCertPem)
+	}
+
 	var keyPem []byte
-	keyPem = []byte(os.Getenv("DYOCSP_PRIVATE_KEY"))
+	keyPem = []byte(os.Getenv(keyEnv))
 	if cfg.Key != "" {
 		if len(keyPem) > 0 {
-			stdlog.Fatal(
-				"error:DYOCSP_PRIVATE_KEY and .responder.responder_key are exclusive.",
-			)
+			stdlog.Fatalf("error:%s and .responder.responder_key are exclusive.", keyEnv)
 		}
 
 		keyPem, err = os.ReadFile(cfg.Key)
@@ -45,12 +87,49 @@ func newResponder(cfg config.DyOCSPConfig) *dyocsp.Responder {
 		}
 	}
 
-	issuerCertPem, err := os.ReadFile(cfg.Issuer)
+	passphraseEnv := "DYOCSP_PRIVATE_KEY_PASSPHRASE" + envSuffix
+	var passphrase []byte
+	passphrase = []byte(os.Getenv(passphraseEnv))
+	if cfg.KeyPassphraseFile != "" {
+		if len(passphrase) > 0 {
+			stdlog.Fatalf("error:%s and .responder.responder_key_passphrase_file are exclusive.", passphraseEnv)
+		}
+
+		passphrase, err = os.ReadFile(cfg.KeyPassphraseFile)
+		if err != nil {
+			stdlog.Fatalf("error:responder key passphrase: %v", err)
+		}
+		passphrase = bytes.TrimRight(passphrase, "\r\n")
+	}
+
+	responder, err := dyocsp.BuildResponder(
+		certPem, keyPem, issuerCertPem, date.NowGMT(), dyocsp.WithKeyPassphrase(passphrase),
+	)
 	if err != nil {
-		stdlog.Fatalf("error:issuer certificate: %v", err)
+		stdlog.Fatal(err.Error())
+	}
+
+	return responder
+}
+
+func newPKCS11Responder(
+	cfg config.DyOCSPConfig, keyEnv string, pinEnv string, certPem, issuerCertPem []byte,
+) *dyocsp.Responder {
+	if os.Getenv(keyEnv) != "" {
+		stdlog.Fatalf("error:%s and .responder.pkcs11 are exclusive.", keyEnv)
 	}
 
-	responder, err := dyocsp.BuildResponder(certPem, keyPem, issuerCertPem, date.NowGMT())
+	signer, err := hsm.NewPKCS11Signer(hsm.PKCS11Key{
+		Module:     cfg.PKCS11Module,
+		TokenLabel: cfg.PKCS11TokenLabel,
+		KeyLabel:   cfg.PKCS11KeyLabel,
+		PIN:        os.Getenv(pinEnv),
+	})
+	if err != nil {
+		stdlog.Fatalf("error:responder key: %v", err)
+	}
+
+	responder, err := dyocsp.BuildResponderWithSigner(certPem, signer, issuerCertPem, date.NowGMT())
 	if err != nil {
 		stdlog.Fatal(err.Error())
 	}
@@ -75,7 +154,28 @@ func newFileDBClient(cfg config.DyOCSPConfig) (db.FileDBClient, error) {
 		return dbClient, ErrFileDBInvalid
 	}
 
-	return db.NewFileDBClient(cfg.CA, cfg.FileDBFile), nil
+	var opts []func(*db.FileDBClient)
+	if cfg.FileDBWatch {
+		opts = append(opts, db.WithFileWatch(time.Duration(cfg.FileDBWatchDebounce)*time.Second))
+	}
+
+	return db.NewFileDBClient(cfg.CA, cfg.FileDBFile, opts...), nil
+}
+
+func loadAWSConfig(cfg config.DyOCSPConfig) (aws.Config, error) {
+	return awsconfig.LoadDefaultConfig(context.TODO(),
+		awsconfig.WithRegion(cfg.DynamoDBRegion),
+		awsconfig.WithRetryMaxAttempts(cfg.DynamoDBRetryMaxAttempts),
+	)
+}
+
+func newAWSDynamoDBClient(aCfg aws.Config, cfg config.DyOCSPConfig) *dynamodb.Client {
+	if cfg.DynamoDBEndpoint == "" {
+		return dynamodb.NewFromConfig(aCfg)
+	}
+	return dynamodb.NewFromConfig(aCfg, func(o *dynamodb.Options) {
+		o.BaseEndpoint = &cfg.DynamoDBEndpoint
+	})
 }
 
 func newDynamoDBClient(cfg config.DyOCSPConfig) (db.DynamoDBClient, error) {
@@ -83,25 +183,168 @@ func newDynamoDBClient(cfg config.DyOCSPConfig) (db.DynamoDBClient, error) {
 	caTable := cfg.DynamoDBTableName
 	caGsi := cfg.DynamoDBCAGsi
 
-	aCfg, err := awsconfig.LoadDefaultConfig(context.TODO(),
-		awsconfig.WithRegion(cfg.DynamoDBRegion),
-		awsconfig.WithRetryMaxAttempts(cfg.DynamoDBRetryMaxAttempts),
-	)
+	aCfg, err := loadAWSConfig(cfg)
 	if err != nil {
 		var dynamoDBClient db.DynamoDBClient
 		return dynamoDBClient, err
 	}
+	client := newAWSDynamoDBClient(aCfg, cfg)
 
-	var client *dynamodb.Client
+	return db.NewDynamoDBClient(
+		client, &ca, &caTable, &caGsi, cfg.DynamoDBTimeout,
+		db.WithSegments(cfg.DynamoDBSegments),
+	)
+}
+
+func newDynamoDBStreamClient(cfg config.DyOCSPConfig) (db.DynamoDBStreamClient, error) {
+	ca := cfg.CA
+	caTable := cfg.DynamoDBTableName
+
+	aCfg, err := loadAWSConfig(cfg)
+	if err != nil {
+		var streamClient db.DynamoDBStreamClient
+		return streamClient, err
+	}
+
+	// DynamoDB Local serves the streams on the same endpoint as the tables
+	var streams *dynamodbstreams.Client
 	if cfg.DynamoDBEndpoint == "" {
-		client = dynamodb.NewFromConfig(aCfg)
+		streams = dynamodbstreams.NewFromConfig(aCfg)
 	} else {
-		client = dynamodb.NewFromConfig(aCfg, func(o *dynamodb.Options) {
+		streams = dynamodbstreams.NewFromConfig(aCfg, func(o *dynamodbstreams.Options) {
 			o.BaseEndpoint = &cfg.DynamoDBEndpoint
 		})
 	}
 
-	return db.NewDynamoDBClient(client, &ca, &caTable, &caGsi, cfg.DynamoDBTimeout), nil
+	return db.NewDynamoDBStreamClient(
+		newAWSDynamoDBClient(aCfg, cfg), streams, &ca, &caTable,
+		db.WithPollInterval(time.Second*time.Duration(cfg.DynamoDBStreamsPoll)),
+	), nil
+}
+
+var ErrIssuerNotSingle = errors.New("issuer certificate file must have one certificate")
+
+func loadIssuerCertificate(cfg config.DyOCSPConfig) (*x509.Certificate, error) {
+	issuerCertPem, err := os.ReadFile(cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	issuers, err := db.ParseCertificates(issuerCertPem)
+	if err != nil {
+		return nil, err
+	}
+	if len(issuers) != 1 {
+		return nil, ErrIssuerNotSingle
+	}
+
+	return issuers[0], nil
+}
+
+func newCRLDBClient(cfg config.DyOCSPConfig) (db.CRLDBClient, error) {
+	issuer, err := loadIssuerCertificate(cfg)
+	if err != nil {
+		return db.CRLDBClient{}, err
+	}
+
+	opts := []func(*db.CRLDBClient){
+		db.WithCRLWatchDebounce(time.Duration(cfg.CRLDBWatchDebounce) * time.Second),
+	}
+	if cfg.CRLDBCertDir != "" {
+		opts = append(opts, db.WithCRLCertDir(cfg.CRLDBCertDir))
+	}
+
+	return db.NewCRLDBClient(cfg.CA, cfg.CRLDBFiles, issuer, opts...), nil
+}
+
+func newCertDirDBClient(cfg config.DyOCSPConfig) (db.CertDirDBClient, error) {
+	issuer, err := loadIssuerCertificate(cfg)
+	if err != nil {
+		return db.CertDirDBClient{}, err
+	}
+
+	opts := []func(*db.CertDirDBClient){
+		db.WithCertDirWatchDebounce(time.Duration(cfg.CertDirDBWatchDebounce) * time.Second),
+	}
+	if cfg.CertDirDBRevocations != "" {
+		opts = append(opts, db.WithCertDirRevocations(cfg.CertDirDBRevocations))
+	}
+
+	return db.NewCertDirDBClient(cfg.CA, cfg.CertDirDBDir, issuer, opts...), nil
+}
+
+var ErrSQLDSNExclusive = errors.New("DYOCSP_SQL_DSN and .db.sql.dsn are exclusive")
+
+func newSQLDBClient(cfg config.DyOCSPConfig) (db.SQLDBClient, error) {
+	dsn := os.Getenv("DYOCSP_SQL_DSN")
+	if cfg.SQLDSN != "" {
+		if dsn != "" {
+			return db.SQLDBClient{}, ErrSQLDSNExclusive
+		}
+		dsn = cfg.SQLDSN
+	}
+
+	var opt func(*db.SQLDBClient)
+	if cfg.SQLQuery != "" {
+		opt = db.WithSQLQuery(cfg.SQLQuery)
+	} else {
+		opt = db.WithSQLTable(cfg.SQLTable, db.SQLColumns{
+			CA:        cfg.SQLColumns.CA,
+			Serial:    cfg.SQLColumns.Serial,
+			RevType:   cfg.SQLColumns.RevType,
+			ExpDate:   cfg.SQLColumns.ExpDate,
+			RevDate:   cfg.SQLColumns.RevDate,
+			CRLReason: cfg.SQLColumns.CRLReason,
+		})
+	}
+
+	return db.NewSQLDBClient(cfg.SQLDriver, dsn, cfg.CA, cfg.SQLTimeout, opt)
+}
+
+func newHTTPDBClient(cfg config.DyOCSPConfig) (db.HTTPDBClient, error) {
+	opts := []func(*db.HTTPDBClient){
+		db.WithHTTPFields(db.HTTPFields{
+			Items:     cfg.HTTPDBFields.Items,
+			CA:        cfg.HTTPDBFields.CA,
+			Serial:    cfg.HTTPDBFields.Serial,
+			RevType:   cfg.HTTPDBFields.RevType,
+			ExpDate:   cfg.HTTPDBFields.ExpDate,
+			RevDate:   cfg.HTTPDBFields.RevDate,
+			CRLReason: cfg.HTTPDBFields.CRLReason,
+			Subject:   cfg.HTTPDBFields.Subject,
+		}),
+		db.WithHTTPRetries(cfg.HTTPDBRetries, db.DefaultHTTPRetryWait),
+	}
+
+	switch db.HTTPPagination(cfg.HTTPDBPagination) {
+	case db.HTTPCursorPagination:
+		opts = append(opts, db.WithHTTPCursorPagination(cfg.HTTPDBCursorParam, cfg.HTTPDBNextCursor))
+	case db.HTTPOffsetPagination:
+		opts = append(opts, db.WithHTTPOffsetPagination(cfg.HTTPDBOffsetParam, cfg.HTTPDBLimitParam, cfg.HTTPDBLimit))
+	case db.HTTPNoPagination:
+	}
+
+	if token := os.Getenv("DYOCSP_HTTP_TOKEN"); token != "" {
+		opts = append(opts, db.WithHTTPBearerToken(token))
+	}
+
+	if cfg.HTTPDBCAFile != "" {
+		caPem, err := os.ReadFile(cfg.HTTPDBCAFile)
+		if err != nil {
+			return db.HTTPDBClient{}, err
+		}
+		cas, err := db.ParseCertificates(caPem)
+		if err != nil {
+			return db.HTTPDBClient{}, err
+		}
+
+		rootCAs := x509.NewCertPool()
+		for _, ca := range cas {
+			rootCAs.AddCert(ca)
+		}
+		opts = append(opts, db.WithHTTPRootCAs(rootCAs))
+	}
+
+	return db.NewHTTPDBClient(cfg.HTTPDBURL, cfg.CA, cfg.HTTPDBTimeout, opts...)
 }
 
 const (
@@ -124,89 +367,418 @@ func chainHTTPAccessHandler(c alice.Chain) alice.Chain {
 	return chain
 }
 
-func run(cfg config.DyOCSPConfig, responder *dyocsp.Responder) error {
-	setupLogger(cfg)
+func newCADBClient(cfg config.DyOCSPConfig) (dyocsp.CADBClient, error) {
+	switch cfg.DBType {
+	case config.FileDBType:
+		return newFileDBClient(cfg)
+	case config.DynamoDBType:
+		return newDynamoDBClient(cfg)
+	case config.SQLDBType:
+		return newSQLDBClient(cfg)
+	case config.CRLDBType:
+		return newCRLDBClient(cfg)
+	case config.CertDirDBType:
+		return newCertDirDBClient(cfg)
+	case config.HTTPDBType:
+		return newHTTPDBClient(cfg)
+	default:
+		return nil, config.MissingParameterError{Param: "db.<db-type>"}
+	}
+}
+
+const snapshotFileSuffix = ".snapshot.json"
+
+func loadSnapshot(cacheStore *cache.ResponseCacheStore, snapshotFile string, logger *zerolog.Logger) {
+	loaded, skipped, err := cacheStore.LoadSnapshot(snapshotFile)
+	for _, err := range skipped {
+		logger.Warn().Err(err).Msgf("Skipped a corrupt cache in the snapshot: %s", snapshotFile)
+	}
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		logger.Info().Msgf("Snapshot is not found: %s", snapshotFile)
+	case err != nil:
+		logger.Warn().Err(err).Msgf("Failed to load the snapshot: %s", snapshotFile)
+	default:
+		logger.Info().Msgf("%d caches are loaded from the snapshot: %s", loaded, snapshotFile)
+	}
+}
+
+func withScanFailure(scanFailure string) dyocsp.CacheBatchOption {
+	if scanFailure == "resign" {
+		return dyocsp.WithScanFailure(dyocsp.ResignCaches)
+	}
+	return dyocsp.WithScanFailure(dyocsp.KeepCaches)
+}
+
+// newServers creates the plain HTTP server and the HTTPS server. The HTTPS
+// server is created when http.tls is set, and the plain HTTP server is created
+// unless http.tls.disable_plain is set.
+func newServers(cfg config.DyOCSPConfig, handler http.Handler, logger *zerolog.Logger) ([]*http.Server, error) {
+	servers := make([]*http.Server, 0, 2)
+
+	if !cfg.TLS || !cfg.TLSDisablePlain {
+		servers = append(servers, dyocsp.CreateHTTPServer(net.JoinHostPort(cfg.Domain, cfg.Port), cfg, handler))
+	}
+
+	if cfg.TLS {
+		reloader, err := dyocsp.NewCertReloader(
+			cfg.TLSCertificate,
+			cfg.TLSKey,
+			dyocsp.WithReloadInterval(time.Duration(cfg.TLSReloadInterval)*time.Second),
+			dyocsp.WithReloaderLogger(logger),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig, err := dyocsp.CreateTLSConfig(cfg, reloader)
+		if err != nil {
+			return nil, err
+		}
+
+		servers = append(
+			servers, dyocsp.CreateHTTPSServer(net.JoinHostPort(cfg.Domain, cfg.TLSPort), cfg, handler, tlsConfig),
+		)
+	}
 
-	rootCtx := context.Background()
-	rootCtx, cancel := context.WithCancel(rootCtx)
+	return servers, nil
+}
+
+func listenAndServe(server *http.Server) error {
+	if server.TLSConfig != nil {
+		// The certificate is served by tlsConfig.GetCertificate
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+// shutdownServers marks the process not ready, waits for the delay so that the
+// load balancer observes it, and gracefully shuts down the servers. The admin
+// server is shut down after the others, so that the readiness is served while
+// they are drained. The in-flight requests are drained until the timeout, and
+// then the remaining connections are closed. The admin server may be nil.
+func shutdownServers(
+	servers []*http.Server,
+	admin *http.Server,
+	readiness *dyocsp.Readiness,
+	delay time.Duration,
+	timeout time.Duration,
+	logger *zerolog.Logger,
+) {
+	readiness.MarkShuttingDown()
+	if delay > 0 {
+		logger.Info().Msgf("Marked not ready, shutting down the servers in %v.", delay)
+		time.Sleep(delay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	// Create DB client
-	var dbClient dyocsp.CADBClient
-	var err error
+	shutdown := func(server *http.Server) {
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error().Err(err).Msgf("Failed to drain the server: %s", server.Addr)
+			_ = server.Close()
+			return
+		}
+		logger.Info().Msgf("Server is shut down: %s", server.Addr)
+	}
 
-	switch cfg.DBType {
-	case config.FileDBType:
-		dbClient, err = newFileDBClient(cfg)
-	case config.DynamoDBType:
-		dbClient, err = newDynamoDBClient(cfg)
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Go(func() {
+			shutdown(server)
+		})
+	}
+	wg.Wait()
+
+	if admin != nil {
+		shutdown(admin)
+	}
+}
+
+var ErrAdminTokenExclusive = errors.New("DYOCSP_ADMIN_TOKEN and .admin.api.token_file are exclusive")
+
+// newAdminHandler creates the handler of the admin API. The token is read
+// from DYOCSP_ADMIN_TOKEN or admin.api.token_file.
+func newAdminHandler(
+	cfg config.DyOCSPConfig, batches []*dyocsp.CacheBatch, logger *zerolog.Logger,
+) (*dyocsp.AdminHandler, error) {
+	token := []byte(os.Getenv("DYOCSP_ADMIN_TOKEN"))
+	if cfg.AdminAPITokenFile != "" {
+		if len(token) > 0 {
+			return nil, ErrAdminTokenExclusive
+		}
+
+		var err error
+		token, err = os.ReadFile(cfg.AdminAPITokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("error:admin API token: %w", err)
+		}
+		token = bytes.TrimRight(token, "\r\n")
+	}
+
+	opts := make([]dyocsp.AdminHandlerOption, 0, len(batches)+1)
+	for _, batch := range batches {
+		opts = append(opts, dyocsp.WithAdminCacheBatch(batch))
+	}
+	opts = append(opts, dyocsp.WithAdminLogger(logger))
+
+	return dyocsp.NewAdminHandler(token, opts...)
+}
+
+func withCacheMiss(cacheMiss string) dyocsp.CacheHandlerOption {
+	switch cacheMiss {
+	case "unknown":
+		return dyocsp.WithCacheMiss(dyocsp.CacheMissUnknown)
+	case "revoked":
+		return dyocsp.WithCacheMiss(dyocsp.CacheMissRevoked)
 	default:
-		err = config.MissingParameterError{Param: "db.<db-type>"}
+		return dyocsp.WithCacheMiss(dyocsp.CacheMissUnauthorized)
 	}
-	if err != nil {
-		return err
+}
+
+// newResponders creates the responders of the CAs. In multi-CA mode, the keys
+// are read from the environment variables of each CA, and the shared
+// environment variables are rejected.
+func newResponders(cfg config.DyOCSPConfig) []*dyocsp.Responder {
+	if len(cfg.Responders) == 0 {
+		return []*dyocsp.Responder{newResponder(cfg, "")}
 	}
 
-	// Create cache store
-	cacheStore := cache.NewResponseCacheStore()
-
-	// Create CacheBatch
-	quite := make(chan string)
-
-	blogger := log.Logger.With().Str("role", cacheBatchRole).Logger()
-	batch, err := dyocsp.NewCacheBatch(
-		cfg.CA,
-		cacheStore,
-		dbClient,
-		responder,
-		date.NowGMT(),
-		// bSpec,
-		dyocsp.WithIntervalSec(cfg.Interval),
-		dyocsp.WithDelay(time.Second*time.Duration(cfg.Delay)),
-		dyocsp.WithStrict(cfg.Strict),
-		dyocsp.WithLogger(&blogger),
-		dyocsp.WithQuiteChan(quite),
-	)
-	if err != nil {
-		return err
+	for _, env := range []string{"DYOCSP_PRIVATE_KEY", "DYOCSP_PRIVATE_KEY_PASSPHRASE", "DYOCSP_PKCS11_PIN"} {
+		if os.Getenv(env) != "" {
+			stdlog.Fatalf("error:%s is not used in multi-CA mode, set %s_<CA> for each CA.", env, env)
+		}
+	}
+
+	suffixes := make(map[string]string, len(cfg.Responders))
+	responders := make([]*dyocsp.Responder, 0, len(cfg.Responders))
+	for _, rCfg := range cfg.Responders {
+		suffix := keyEnvSuffix(rCfg.CA)
+		if dup, ok := suffixes[suffix]; ok {
+			stdlog.Fatalf("error:CAs %q and %q have the same key environment variables.", dup, rCfg.CA)
+		}
+		suffixes[suffix] = rCfg.CA
+
+		responders = append(responders, newResponder(rCfg, suffix))
+	}
+	return responders
+}
+
+func run(cfg config.DyOCSPConfig, responders []*dyocsp.Responder) error {
+	setupLogger(cfg)
+
+	// The batches are canceled after the servers are drained
+	batchCtx, cancelBatches := context.WithCancel(context.Background())
+	defer cancelBatches()
+	var batches sync.WaitGroup
+
+	var metrics *dyocsp.Metrics
+	if cfg.Metrics {
+		metrics = dyocsp.NewMetrics()
 	}
 
-	// Run batch generating caches
-	go batch.Run(rootCtx)
+	rCfgs := cfg.ResponderConfigs()
+	handlerOpts := make([]dyocsp.CacheHandlerOption, 0, len(rCfgs))
+	healthOpts := make([]dyocsp.HealthHandlerOption, 0, len(rCfgs))
+	cacheBatches := make([]*dyocsp.CacheBatch, 0, len(rCfgs))
+	var cacheStoreRO *cache.ResponseCacheStoreRO
+
+	for idx, rCfg := range rCfgs {
+		// Create DB client
+		dbClient, err := newCADBClient(rCfg)
+		if err != nil {
+			return err
+		}
+
+		// Create cache store
+		cacheStore := cache.NewResponseCacheStore()
+
+		// Create CacheBatch
+		blogger := log.Logger.With().Str("role", cacheBatchRole).Str("ca", rCfg.CA).Logger()
+		batchOpts := []dyocsp.CacheBatchOption{
+			dyocsp.WithIntervalSec(rCfg.Interval),
+			dyocsp.WithDelay(time.Second * time.Duration(rCfg.Delay)),
+			dyocsp.WithStrict(rCfg.Strict),
+			withScanFailure(rCfg.ScanFailure),
+			dyocsp.WithDropThreshold(rCfg.DropThreshold),
+			dyocsp.WithWorkers(rCfg.Workers),
+			dyocsp.WithLogger(&blogger),
+		}
+
+		if metrics != nil {
+			batchOpts = append(batchOpts, dyocsp.WithMetrics(metrics))
+			metrics.AddCacheStore(rCfg.CA, cacheStore.NewReadOnlyCacheStore(), responders[idx])
+		}
+
+		// Apply the changes from the streams between the batches
+		if rCfg.DBType == config.DynamoDBType && rCfg.DynamoDBStreams {
+			streamClient, err := newDynamoDBStreamClient(rCfg)
+			if err != nil {
+				return err
+			}
+			batchOpts = append(batchOpts, dyocsp.WithChangeFeed(streamClient))
+		}
+
+		// Refresh the caches as soon as the files of the DB are updated
+		watch := rCfg.FileDBWatch || rCfg.CRLDBWatch || rCfg.CertDirDBWatch
+		if watcher, ok := dbClient.(dyocsp.CADBWatcher); ok && watch {
+			batchOpts = append(batchOpts, dyocsp.WithWatcher(watcher))
+		}
+
+		// Load the snapshot to answer before the first batch is completed
+		if rCfg.SnapshotDir != "" {
+			snapshotFile := filepath.Join(rCfg.SnapshotDir, rCfg.CA+snapshotFileSuffix)
+			loadSnapshot(cacheStore, snapshotFile, &blogger)
+			batchOpts = append(batchOpts, dyocsp.WithSnapshotFile(snapshotFile))
+		}
+
+		batch, err := dyocsp.NewCacheBatch(
+			rCfg.CA,
+			cacheStore,
+			dbClient,
+			responders[idx],
+			date.NowGMT(),
+			batchOpts...,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Run batch generating caches
+		batches.Go(func() {
+			batch.Run(batchCtx)
+		})
+		cacheBatches = append(cacheBatches, batch)
+
+		healthOpts = append(healthOpts, dyocsp.WithHealthCacheStore(rCfg.CA, cacheStore.NewReadOnlyCacheStore()))
+
+		if idx == 0 {
+			cacheStoreRO = cacheStore.NewReadOnlyCacheStore()
+			continue
+		}
+		handlerOpts = append(
+			handlerOpts, dyocsp.WithResponder(cacheStore.NewReadOnlyCacheStore(), responders[idx]),
+		)
+	}
 
 	// Create Server
 	hLogger := log.Logger.With().Str("role", CacheHandlerRole).Logger()
-	cacheStoreRO := cacheStore.NewReadOnlyCacheStore()
 
 	chain := alice.New()
 	chain = chain.Append(hlog.NewHandler(hLogger))
 	chain = chainHTTPAccessHandler(chain)
-	cacheHander := dyocsp.NewCacheHandler(
-		cacheStoreRO,
-		responder,
-		chain,
+	handlerOpts = append(handlerOpts,
 		dyocsp.WithMaxAge(cfg.CacheControlMaxAge),
 		dyocsp.WithMaxRequestBytes(cfg.MaxRequestBytes),
 		dyocsp.WithHandlerLogger(&hLogger),
+		withCacheMiss(cfg.CacheMiss),
+		dyocsp.WithNegativeCacheTTL(time.Duration(cfg.CacheMissTTL)*time.Second),
+		dyocsp.WithNegativeCacheSize(cfg.CacheMissMaxEntries),
+		dyocsp.WithNegativeCacheSigners(cfg.CacheMissConcurrency),
+		dyocsp.WithHashCacheSize(cfg.HashCacheMaxEntries),
+		dyocsp.WithHashCacheSigners(cfg.HashCacheConcurrency),
+	)
+	if cfg.NonceSigning {
+		handlerOpts = append(handlerOpts,
+			dyocsp.WithNonceSigning(cfg.NonceMaxConcurrency),
+			dyocsp.WithNonceMaxLength(cfg.NonceMaxLength),
+		)
+	}
+	if metrics != nil {
+		handlerOpts = append(handlerOpts, dyocsp.WithHandlerMetrics(metrics))
+	}
+	if cfg.MultiCertIDs {
+		handlerOpts = append(handlerOpts,
+			dyocsp.WithMaxCertIDs(cfg.MaxCertIDs),
+			dyocsp.WithMultiCertSigners(cfg.MultiCertIDsConcurrency),
+		)
+	}
+	cacheHander := dyocsp.NewCacheHandler(
+		cacheStoreRO,
+		responders[0],
+		chain,
+		handlerOpts...,
 	)
 
-	host := net.JoinHostPort(cfg.Domain, cfg.Port)
-	server := dyocsp.CreateHTTPServer(
-		host,
-		cfg,
-		cacheHander,
+	// Marked not ready when the shutdown starts
+	readiness := dyocsp.NewReadiness()
+	healthOpts = append(healthOpts,
+		dyocsp.WithMaxUpdateAge(time.Duration(cfg.HealthMaxUpdateAge)*time.Second),
+		dyocsp.WithMinEntries(cfg.HealthMinEntries),
+		dyocsp.WithHealthLogger(&hLogger),
 	)
+	health := dyocsp.NewHealthHandler(readiness, healthOpts...)
 
-	// Run Server
-	err = server.ListenAndServe()
-	if err != nil {
-		if errors.Is(err, http.ErrServerClosed) {
-			panic(err)
+	routes := map[string]http.Handler{
+		dyocsp.HealthzPath: health,
+		dyocsp.ReadyzPath:  health,
+	}
+
+	// The health endpoints are served on the admin listener if admin.port is
+	// set, otherwise on the OCSP listeners
+	var handler http.Handler = cacheHander
+	if cfg.AdminPort == "" {
+		handler = dyocsp.RoutePaths(cacheHander, maps.Clone(routes))
+	}
+
+	// The metrics and the admin API are served only on the admin listener,
+	// which is required by the configuration
+	if metrics != nil && cfg.AdminPort != "" {
+		routes[dyocsp.MetricsPath] = metrics
+	}
+	if cfg.AdminAPI && cfg.AdminPort != "" {
+		admin, err := newAdminHandler(cfg, cacheBatches, &hLogger)
+		if err != nil {
+			return err
 		}
-		cancel()
-		quite <- "Quite on error"
-		stdlog.Printf("Batch loop quited: received message from caching batch: %s", <-quite)
-		stdlog.Fatalf("error:listening server: %v", err)
+		routes[dyocsp.AdminPathPrefix] = admin
+	}
+
+	servers, err := newServers(cfg, handler, &hLogger)
+	if err != nil {
+		return err
+	}
+	var adminServer *http.Server
+	if cfg.AdminPort != "" {
+		admin := dyocsp.RoutePaths(http.NotFoundHandler(), routes)
+		adminServer = dyocsp.CreateHTTPServer(net.JoinHostPort(cfg.Domain, cfg.AdminPort), cfg, admin)
+	}
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	// Run Servers, and wait for the first one that stops or a signal
+	listening := slices.Clone(servers)
+	if adminServer != nil {
+		listening = append(listening, adminServer)
+	}
+	errCh := make(chan error, len(listening))
+	for _, server := range listening {
+		go func() {
+			errCh <- listenAndServe(server)
+		}()
+	}
+
+	select {
+	case err = <-errCh:
+	case <-sigCtx.Done():
+		log.Info().Msg("Signal received, shutting down.")
+	}
+
+	shutdownServers(
+		servers, adminServer, readiness,
+		time.Duration(cfg.ShutdownDelay)*time.Second,
+		time.Duration(cfg.ShutdownTimeout)*time.Second,
+		&log.Logger,
+	)
+
+	cancelBatches()
+	batches.Wait()
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("error:listening server: %w", err)
 	}
 
 	return nil
@@ -255,9 +827,9 @@ func main() {
 		stdlog.Printf("failed to close file: %v\n", err)
 	}
 
-	responder := newResponder(cfg)
+	responders := newResponders(cfg)
 
-	err = run(cfg, responder)
+	err = run(cfg, responders)
 	if err != nil {
 		stdlog.Fatal(err)
 	}
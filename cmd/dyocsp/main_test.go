@@ -2,14 +2,18 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/x509"
 	"encoding/pem"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/rs/zerolog/log"
+	"github.com/yuxki/dyocsp"
 	"github.com/yuxki/dyocsp/pkg/config"
 	"golang.org/x/crypto/ocsp"
 	"gopkg.in/yaml.v3"
@@ -84,8 +88,8 @@ func TestMain_InternalIntegration(t *testing.T) {
 	}
 
 	// Run Responder Batch & Server
-	responder := newResponder(cfg)
-	go run(cfg, responder)
+	responders := newResponders(cfg)
+	go run(cfg, responders)
 
 	endpoint := "http://localhost:9080"
 	tryN := 20
@@ -125,3 +129,95 @@ func TestMain_InternalIntegration(t *testing.T) {
 		t.Fatal("Invald response.")
 	}
 }
+
+func TestMain_keyEnvSuffix(t *testing.T) {
+	t.Parallel()
+
+	data := []struct {
+		ca string
+		// want
+		suffix string
+	}{
+		{"sub-ca", "_SUB_CA"},
+		{"Root CA 2", "_ROOT_CA_2"},
+		{"ca.example.com", "_CA_EXAMPLE_COM"},
+	}
+
+	for _, d := range data {
+		t.Run(d.ca, func(t *testing.T) {
+			t.Parallel()
+
+			if suffix := keyEnvSuffix(d.ca); suffix != d.suffix {
+				t.Errorf("Expected %s but got %s", d.suffix, suffix)
+			}
+		})
+	}
+}
+
+func TestMain_shutdownServers(t *testing.T) {
+	t.Parallel()
+
+	serve := func(handler http.Handler) (*http.Server, string) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := &http.Server{Handler: handler, ReadHeaderTimeout: time.Second}
+		go func() {
+			_ = server.Serve(ln)
+		}()
+		return server, "http://" + ln.Addr().String()
+	}
+
+	readiness := dyocsp.NewReadiness()
+	ocspServer, ocspURL := serve(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	adminServer, adminURL := serve(dyocsp.NewHealthHandler(readiness))
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	get := func(url string) (int, error) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+		if err != nil {
+			return 0, err
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		res.Body.Close()
+		return res.StatusCode, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		shutdownServers(
+			[]*http.Server{ocspServer}, adminServer, readiness, time.Second, time.Second, &log.Logger,
+		)
+		close(done)
+	}()
+
+	// Not ready during the delay, while the OCSP requests are still accepted
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for readiness.Ready() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if code, err := get(adminURL + dyocsp.ReadyzPath); err != nil || code != http.StatusServiceUnavailable {
+		t.Errorf("Expected %s is %d but got: %d %v", dyocsp.ReadyzPath, http.StatusServiceUnavailable, code, err)
+	}
+	if code, err := get(ocspURL); err != nil || code != http.StatusOK {
+		t.Errorf("Expected OCSP server is %d but got: %d %v", http.StatusOK, code, err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdownServers did not return.")
+	}
+
+	for _, url := range []string{ocspURL, adminURL + dyocsp.ReadyzPath} {
+		if _, err := get(url); err == nil {
+			t.Errorf("Expected the server is shut down: %s", url)
+		}
+	}
+}
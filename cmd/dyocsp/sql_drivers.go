@@ -0,0 +1,6 @@
+package main
+
+// The drivers of db.sql.driver.
+import (
+	_ "github.com/lib/pq"
+)
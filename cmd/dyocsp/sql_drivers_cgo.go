@@ -0,0 +1,8 @@
+//go:build cgo
+
+package main
+
+// The SQLite driver requires cgo, and it is not registered without cgo.
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
@@ -0,0 +1,186 @@
+package dyocsp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/pbkdf2"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// RFC 8018: Appendix C. ASN.1 Module.
+// (https://www.rfc-editor.org/rfc/rfc8018#appendix-C)
+var (
+	oidPBES2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA224 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 8}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// RFC 5958: 3. Encrypted Private Key Info.
+type encryptedPrivateKeyInfo struct {
+	EncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+var errKeyDecryptionFailed = errors.New("failed to decrypt private key, the passphrase may be incorrect")
+
+func prfHash(prf pkix.AlgorithmIdentifier) (func() hash.Hash, error) {
+	switch {
+	// hmacWithSHA1 is the default PRF
+	case len(prf.Algorithm) == 0, prf.Algorithm.Equal(oidHMACWithSHA1):
+		return crypto.SHA1.New, nil
+	case prf.Algorithm.Equal(oidHMACWithSHA224):
+		return crypto.SHA224.New, nil
+	case prf.Algorithm.Equal(oidHMACWithSHA256):
+		return crypto.SHA256.New, nil
+	case prf.Algorithm.Equal(oidHMACWithSHA384):
+		return crypto.SHA384.New, nil
+	case prf.Algorithm.Equal(oidHMACWithSHA512):
+		return crypto.SHA512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported PBKDF2 pseudorandom function: %s", prf.Algorithm)
+}
+
+func encryptionSchemeCipher(scheme asn1.ObjectIdentifier) (func([]byte) (cipher.Block, error), int, error) {
+	switch {
+	case scheme.Equal(oidAES128CBC):
+		return aes.NewCipher, 16, nil
+	case scheme.Equal(oidAES192CBC):
+		return aes.NewCipher, 24, nil
+	case scheme.Equal(oidAES256CBC):
+		return aes.NewCipher, 32, nil
+	case scheme.Equal(oidDESEDE3CBC):
+		return des.NewTripleDESCipher, 24, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported encryption scheme: %s", scheme)
+}
+
+func unpadPKCS7(data []byte, blockSize int) ([]byte, error) {
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return nil, errKeyDecryptionFailed
+	}
+
+	padLen := int(data[len(data)-1])
+	if padLen == 0 || padLen > blockSize {
+		return nil, errKeyDecryptionFailed
+	}
+	if !bytes.Equal(data[len(data)-padLen:], bytes.Repeat([]byte{byte(padLen)}, padLen)) {
+		return nil, errKeyDecryptionFailed
+	}
+
+	return data[:len(data)-padLen], nil
+}
+
+// decryptPKCS8PrivKey decrypts a PKCS #8 EncryptedPrivateKeyInfo encrypted
+// with PBES2 (PBKDF2 and AES-CBC or DES-EDE3-CBC), and returns a PKCS #8,
+// ASN.1 DER private key.
+func decryptPKCS8PrivKey(der []byte, passphrase []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse EncryptedPrivateKeyInfo: %w", err)
+	}
+
+	if !info.EncryptionAlgorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported encryption algorithm: %s", info.EncryptionAlgorithm.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.EncryptionAlgorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("failed to parse PBES2 parameters: %w", err)
+	}
+
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function: %s", params.KeyDerivationFunc.Algorithm)
+	}
+
+	var kdfParams pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, fmt.Errorf("failed to parse PBKDF2 parameters: %w", err)
+	}
+
+	h, err := prfHash(kdfParams.PRF)
+	if err != nil {
+		return nil, err
+	}
+
+	newCipher, keyLen, err := encryptionSchemeCipher(params.EncryptionScheme.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if kdfParams.KeyLength != 0 && kdfParams.KeyLength != keyLen {
+		return nil, fmt.Errorf("unexpected PBKDF2 key length: %d", kdfParams.KeyLength)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("failed to parse initialization vector: %w", err)
+	}
+
+	key, err := pbkdf2.Key(h, string(passphrase), kdfParams.Salt, kdfParams.IterationCount, keyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, fmt.Errorf("unexpected initialization vector length: %d", len(iv))
+	}
+	if len(info.EncryptedData) == 0 || len(info.EncryptedData)%block.BlockSize() != 0 {
+		return nil, errKeyDecryptionFailed
+	}
+
+	plain := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, info.EncryptedData)
+
+	return unpadPKCS7(plain, block.BlockSize())
+}
+
+func parseEncryptedPKCS8PrivKey(der []byte, passphrase []byte) (crypto.PrivateKey, error) {
+	if len(passphrase) == 0 {
+		return nil, invalidPKIResourceError{responderKey, "passphrase is required for encrypted private key."}
+	}
+
+	plain, err := decryptPKCS8PrivKey(der, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt a PKCS #8 encrypted private key: %w", err)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(plain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt a PKCS #8 encrypted private key: %w", errKeyDecryptionFailed)
+	}
+
+	return key, nil
+}
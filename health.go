@@ -0,0 +1,214 @@
+package dyocsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/yuxki/dyocsp/pkg/cache"
+	"github.com/yuxki/dyocsp/pkg/date"
+)
+
+// Paths of the health check endpoints.
+const (
+	HealthzPath = "/healthz"
+	ReadyzPath  = "/readyz"
+)
+
+// DefaultHealthMinEntries is the default minimum number of the caches of each
+// cache store to be ready.
+const DefaultHealthMinEntries = 1
+
+// Status of the health check endpoints.
+const (
+	healthOK       = "ok"
+	healthNotReady = "not ready"
+)
+
+type healthCacheStore struct {
+	ca    string
+	store *cache.ResponseCacheStoreRO
+}
+
+// HealthHandler serves the health check endpoints. HealthzPath responds
+// 200 OK while the process is serving. ReadyzPath responds 200 OK when the
+// process can answer with the cached responses, otherwise 503 Service Unavailable.
+// Both respond with a JSON body, which describes why the process is not ready.
+type HealthHandler struct {
+	stores       []healthCacheStore
+	readiness    *Readiness
+	maxUpdateAge time.Duration
+	minEntries   int
+	now          date.Now
+	logger       *zerolog.Logger
+}
+
+// HealthHandlerOption is type of an functional option for dyocsp.HealthHandler.
+type HealthHandlerOption func(*HealthHandler)
+
+// WithHealthCacheStore adds the cache store of the CA to the stores checked
+// by the readiness.
+func WithHealthCacheStore(ca string, store *cache.ResponseCacheStoreRO) func(*HealthHandler) {
+	return func(h *HealthHandler) {
+		h.stores = append(h.stores, healthCacheStore{ca: ca, store: store})
+	}
+}
+
+// WithMaxUpdateAge sets the maximum duration since a cache store was updated
+// to be ready. If 0 or less than 0 is set, the duration is not checked.
+func WithMaxUpdateAge(age time.Duration) func(*HealthHandler) {
+	return func(h *HealthHandler) {
+		h.maxUpdateAge = age
+	}
+}
+
+// WithMinEntries sets the minimum number of the caches of each cache store
+// to be ready. Default value is DefaultHealthMinEntries (1). If less than 0 is
+// set, the default value is used.
+func WithMinEntries(entries int) func(*HealthHandler) {
+	return func(h *HealthHandler) {
+		h.minEntries = entries
+	}
+}
+
+// WithHealthLogger sets logger. If not set, global logger is used.
+func WithHealthLogger(logger *zerolog.Logger) func(*HealthHandler) {
+	return func(h *HealthHandler) {
+		h.logger = logger
+	}
+}
+
+// NewHealthHandler creates a new instance of dyocsp.HealthHandler. The process
+// is not ready after the readiness is marked shutting down.
+func NewHealthHandler(readiness *Readiness, opts ...HealthHandlerOption) *HealthHandler {
+	handler := HealthHandler{
+		readiness:  readiness,
+		minEntries: DefaultHealthMinEntries,
+		now:        date.NowGMT,
+	}
+
+	for _, opt := range opts {
+		opt(&handler)
+	}
+
+	if handler.minEntries < 0 {
+		handler.minEntries = DefaultHealthMinEntries
+	}
+
+	if handler.logger == nil {
+		handler.logger = &log.Logger
+	}
+
+	return &handler
+}
+
+// cacheHealth is the state of a cache store in the body of the responses.
+type cacheHealth struct {
+	CA               string     `json:"ca"`
+	Entries          int        `json:"entries"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	OldestNextUpdate *time.Time `json:"oldest_next_update,omitempty"`
+}
+
+// healthStatus is the body of the responses.
+type healthStatus struct {
+	Status  string        `json:"status"`
+	Reasons []string      `json:"reasons,omitempty"`
+	Caches  []cacheHealth `json:"caches,omitempty"`
+}
+
+// ready checks whether the process can answer with the cached responses. Each
+// cache store must have the minimum number of the caches, must be updated
+// within the maximum age, and must not have an expired cache.
+func (h *HealthHandler) ready() healthStatus {
+	status := healthStatus{Status: healthOK}
+	nowT := h.now()
+
+	if !h.readiness.Ready() {
+		status.Reasons = append(status.Reasons, "shutting down")
+	}
+
+	for _, hs := range h.stores {
+		stats := hs.store.Stats()
+		ch := cacheHealth{CA: hs.ca, Entries: stats.Entries, UpdatedAt: stats.UpdatedAt}
+
+		if stats.Entries < h.minEntries {
+			status.Reasons = append(status.Reasons, fmt.Sprintf(
+				"%s: %d cached responses, at least %d required", hs.ca, stats.Entries, h.minEntries,
+			))
+		}
+
+		if h.maxUpdateAge > 0 && nowT.Sub(stats.UpdatedAt) > h.maxUpdateAge {
+			status.Reasons = append(status.Reasons, fmt.Sprintf(
+				"%s: cache store is not updated since %s", hs.ca, stats.UpdatedAt.Format(time.RFC3339),
+			))
+		}
+
+		if !stats.OldestNextUpdate.IsZero() {
+			oldest := stats.OldestNextUpdate
+			ch.OldestNextUpdate = &oldest
+			if !nowT.Before(oldest) {
+				status.Reasons = append(status.Reasons, fmt.Sprintf(
+					"%s: cached response expired at %s", hs.ca, oldest.Format(time.RFC3339),
+				))
+			}
+		}
+
+		status.Caches = append(status.Caches, ch)
+	}
+
+	if len(status.Reasons) != 0 {
+		status.Status = healthNotReady
+	}
+
+	return status
+}
+
+func (h *HealthHandler) writeStatus(w http.ResponseWriter, r *http.Request, code int, status healthStatus) {
+	body, err := json.Marshal(status)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(body); err != nil {
+		h.logger.Error().Err(err).Msg("")
+	}
+}
+
+// ServeHTTP serves HealthzPath and ReadyzPath, and responds 404 Not Found to
+// the other paths. Only GET and HEAD methods are allowed.
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != HealthzPath && r.URL.Path != ReadyzPath {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Path == HealthzPath {
+		h.writeStatus(w, r, http.StatusOK, healthStatus{Status: healthOK})
+		return
+	}
+
+	status := h.ready()
+	if status.Status != healthOK {
+		h.writeStatus(w, r, http.StatusServiceUnavailable, status)
+		return
+	}
+	h.writeStatus(w, r, http.StatusOK, status)
+}
@@ -0,0 +1,118 @@
+package dyocsp
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuxki/dyocsp/pkg/cache"
+	"github.com/yuxki/dyocsp/pkg/date"
+)
+
+func TestHealthHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDelegatedResponder(t)
+	cacheStore := cache.NewResponseCacheStore()
+	cacheStore.Update([]cache.ResponseCache{testCreateCacheForCert(t, responder, big.NewInt(1), 500)})
+	emptyStore := cache.NewResponseCacheStore()
+
+	data := []struct {
+		testcase     string
+		path         string
+		stores       map[string]*cache.ResponseCacheStore
+		opts         []HealthHandlerOption
+		after        time.Duration
+		shuttingDown bool
+		// want
+		code   int
+		reason string
+	}{
+		{"ready", ReadyzPath, map[string]*cache.ResponseCacheStore{"ca": cacheStore}, nil, 0, false, http.StatusOK, ""},
+		{
+			"no cached response", ReadyzPath,
+			map[string]*cache.ResponseCacheStore{"ca": cacheStore, "empty": emptyStore},
+			nil, 0, false, http.StatusServiceUnavailable, "empty: 0 cached responses, at least 1 required",
+		},
+		{
+			"no cached response is allowed", ReadyzPath,
+			map[string]*cache.ResponseCacheStore{"empty": emptyStore},
+			[]HealthHandlerOption{WithMinEntries(0)}, 0, false, http.StatusOK, "",
+		},
+		{
+			"cached response expired", ReadyzPath,
+			map[string]*cache.ResponseCacheStore{"ca": cacheStore},
+			nil, time.Second * 600, false, http.StatusServiceUnavailable, "ca: cached response expired at",
+		},
+		{
+			"cache store not updated", ReadyzPath,
+			map[string]*cache.ResponseCacheStore{"ca": cacheStore},
+			[]HealthHandlerOption{WithMaxUpdateAge(time.Minute)},
+			time.Minute * 2, false, http.StatusServiceUnavailable, "ca: cache store is not updated since",
+		},
+		{
+			"shutting down", ReadyzPath,
+			map[string]*cache.ResponseCacheStore{"ca": cacheStore},
+			nil, 0, true, http.StatusServiceUnavailable, "shutting down",
+		},
+		{
+			"alive while not ready", HealthzPath,
+			map[string]*cache.ResponseCacheStore{"empty": emptyStore},
+			nil, 0, true, http.StatusOK, "",
+		},
+		{"unknown path", "/livez", nil, nil, 0, false, http.StatusNotFound, ""},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			opts := d.opts
+			for ca, store := range d.stores {
+				opts = append(opts, WithHealthCacheStore(ca, store.NewReadOnlyCacheStore()))
+			}
+			readiness := NewReadiness()
+			if d.shuttingDown {
+				readiness.MarkShuttingDown()
+			}
+			handler := NewHealthHandler(readiness, opts...)
+			handler.now = func() time.Time { return date.NowGMT().Add(d.after) }
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, d.path, nil))
+
+			if rec.Code != d.code {
+				t.Fatalf("Expected status code is %d but got: %d", d.code, rec.Code)
+			}
+			if d.code == http.StatusNotFound {
+				return
+			}
+
+			var status healthStatus
+			if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+				t.Fatal(err)
+			}
+			if d.reason == "" {
+				if status.Status != healthOK || len(status.Reasons) != 0 {
+					t.Errorf("Expected ok but got: %#v", status)
+				}
+				return
+			}
+
+			if status.Status != healthNotReady {
+				t.Errorf("Expected status is %s but got: %s", healthNotReady, status.Status)
+			}
+			found := false
+			for _, reason := range status.Reasons {
+				found = found || strings.HasPrefix(reason, d.reason)
+			}
+			if !found {
+				t.Errorf("Expected reason %q is not found: %v", d.reason, status.Reasons)
+			}
+		})
+	}
+}
@@ -7,5 +7,6 @@ aws dynamodb create-table \
   AttributeName=serial,AttributeType=S \
   --key-schema AttributeName=ca,KeyType=HASH AttributeName=serial,KeyType=RANGE \
   --provisioned-throughput ReadCapacityUnits=1,WriteCapacityUnits=1 \
+  --stream-specification StreamEnabled=true,StreamViewType=NEW_AND_OLD_IMAGES \
   --global-secondary-indexes "{\"IndexName\":\"ca_gsi\",\"KeySchema\":[{\"AttributeName\":\"ca\",\"KeyType\":\"HASH\"}],\"ProvisionedThroughput\":{\"ReadCapacityUnits\":1,\"WriteCapacityUnits\":1},\"Projection\":{\"ProjectionType\":\"ALL\"}}" \
   --endpoint-url http://dynamodb-local:8000
@@ -0,0 +1,365 @@
+package dyocsp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/yuxki/dyocsp/pkg/cache"
+	"github.com/yuxki/dyocsp/pkg/date"
+	"github.com/yuxki/dyocsp/pkg/db"
+	"golang.org/x/crypto/ocsp"
+)
+
+// MetricsPath is the path of the metrics endpoint.
+const MetricsPath = "/metrics"
+
+// Buckets of the histograms in seconds.
+var (
+	requestDurationBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+	batchDurationBuckets   = []float64{0.01, 0.1, 1, 10, 30, 60, 300, 600, 1800}
+)
+
+// Labels of the status of the responses.
+const (
+	statusSuccessful       = "successful"
+	statusNotModified      = "not_modified"
+	statusMalformedRequest = "malformed_request"
+	statusInternalError    = "internal_error"
+	statusTryLater         = "try_later"
+	statusSigRequired      = "sig_required"
+	statusUnauthorized     = "unauthorized"
+	// The request is rejected without an OCSP response (e.g. 405, 413)
+	statusRejected = "rejected"
+)
+
+// The certificate status label of the response with multiple SingleResponses.
+const certStatusMultiple = "multiple"
+
+var certStatusLabels = map[int]string{
+	ocsp.Good:    "good",
+	ocsp.Revoked: "revoked",
+	ocsp.Unknown: "unknown",
+}
+
+var errorResponseLabels = []struct {
+	res   []byte
+	label string
+}{
+	{ocsp.MalformedRequestErrorResponse, statusMalformedRequest},
+	{ocsp.InternalErrorErrorResponse, statusInternalError},
+	{ocsp.TryLaterErrorResponse, statusTryLater},
+	{ocsp.SigRequredErrorResponse, statusSigRequired},
+	{ocsp.UnauthorizedErrorResponse, statusUnauthorized},
+}
+
+// Labels of the reasons of the rejected entries.
+var invalidWithLabels = map[db.InvalidWith]string{
+	db.MalformSerial:      "malformed_serial",
+	db.UndefinedRevType:   "undefined_rev_type",
+	db.MalformExpDate:     "malformed_exp_date",
+	db.MalformRevDate:     "malformed_rev_date",
+	db.UndefinedCRLReason: "undefined_crl_reason",
+}
+
+// Labels of the phases of the batch, and the reasons of the batch failures.
+const (
+	phaseScan   = "scan"
+	phaseParse  = "parse"
+	phaseSign   = "sign"
+	phaseUpdate = "update"
+
+	batchFailureScan      = "scan"
+	batchFailureCountDrop = "entry_count_drop"
+)
+
+// Labels of the actions to the expired entries.
+const (
+	expiredDropped = "dropped"
+	expiredWarned  = "warned"
+)
+
+type metricsAuthority struct {
+	ca         string
+	cacheStore *cache.ResponseCacheStoreRO
+	responder  *Responder
+}
+
+// cacheCollector collects the state of the response caches and the responder
+// certificates, which are computed when the metrics are collected.
+type cacheCollector struct {
+	now                    date.Now
+	cacheEntries           *prometheus.Desc
+	nextUpdateSeconds      *prometheus.Desc
+	responderExpirySeconds *prometheus.Desc
+	authorities            []metricsAuthority
+	mu                     sync.Mutex
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cacheEntries
+	ch <- c.nextUpdateSeconds
+	ch <- c.responderExpirySeconds
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	auths := c.authorities
+	c.mu.Unlock()
+
+	nowT := c.now()
+	for _, auth := range auths {
+		stats := auth.cacheStore.Stats()
+		ch <- prometheus.MustNewConstMetric(
+			c.cacheEntries, prometheus.GaugeValue, float64(stats.Entries), auth.ca,
+		)
+		if !stats.OldestNextUpdate.IsZero() {
+			ch <- prometheus.MustNewConstMetric(
+				c.nextUpdateSeconds, prometheus.GaugeValue, stats.OldestNextUpdate.Sub(nowT).Seconds(), auth.ca,
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.responderExpirySeconds, prometheus.GaugeValue, auth.responder.rCert.NotAfter.Sub(nowT).Seconds(), auth.ca,
+		)
+	}
+}
+
+// Metrics is the set of the metrics of the requests, the batches and the caches.
+// It is shared by dyocsp.CacheHandler (WithHandlerMetrics) and dyocsp.CacheBatch
+// (WithMetrics), and served by the Prometheus client library.
+// The methods of a nil Metrics do nothing, so that the metrics are optional.
+type Metrics struct {
+	handler http.Handler
+	// Requests
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	// Batches
+	batchDuration   *prometheus.HistogramVec
+	batchFailures   *prometheus.CounterVec
+	entriesScanned  *prometheus.CounterVec
+	entriesRejected *prometheus.CounterVec
+	entriesExpired  *prometheus.CounterVec
+	entriesSigned   *prometheus.CounterVec
+	// Caches
+	caches *cacheCollector
+}
+
+// NewMetrics creates a new instance of dyocsp.Metrics. The metrics are
+// registered in its own registry, not in the default registry.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dyocsp_requests_total",
+			Help: "The number of OCSP requests by the method, the response status and the certificate status.",
+		}, []string{"method", "status", "cert_status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "dyocsp_request_duration_seconds",
+			Help:    "The latency of OCSP requests.",
+			Buckets: requestDurationBuckets,
+		}, []string{"method"}),
+		batchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "dyocsp_batch_phase_duration_seconds",
+			Help:    "The duration of each phase of the cache generation batches.",
+			Buckets: batchDurationBuckets,
+		}, []string{"ca", "phase"}),
+		batchFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dyocsp_batch_failures_total",
+			Help: "The number of the batches whose scan failed, and the previous generation was used.",
+		}, []string{"ca", "reason"}),
+		entriesScanned: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dyocsp_entries_scanned_total",
+			Help: "The number of the entries scanned from the CA database.",
+		}, []string{"ca"}),
+		entriesRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dyocsp_entries_rejected_total",
+			Help: "The number of the scanned entries rejected by the verification.",
+		}, []string{"ca", "reason"}),
+		entriesExpired: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dyocsp_entries_expired_total",
+			Help: "The number of the entries past the expiration date, which are dropped or warned.",
+		}, []string{"ca", "action"}),
+		entriesSigned: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dyocsp_entries_signed_total",
+			Help: "The number of the response caches signed by the batches.",
+		}, []string{"ca"}),
+		caches: &cacheCollector{
+			now: date.NowGMT,
+			cacheEntries: prometheus.NewDesc(
+				"dyocsp_cache_entries",
+				"The number of the response caches served.",
+				[]string{"ca"}, nil,
+			),
+			nextUpdateSeconds: prometheus.NewDesc(
+				"dyocsp_cache_next_update_seconds",
+				"The seconds until the earliest nextUpdate of the response caches served.",
+				[]string{"ca"}, nil,
+			),
+			responderExpirySeconds: prometheus.NewDesc(
+				"dyocsp_responder_certificate_expiry_seconds",
+				"The seconds until the responder certificate expires.",
+				[]string{"ca"}, nil,
+			),
+		},
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		m.requests, m.requestDuration,
+		m.batchDuration, m.batchFailures,
+		m.entriesScanned, m.entriesRejected, m.entriesExpired, m.entriesSigned,
+		m.caches,
+	)
+	m.handler = promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+
+	return m
+}
+
+// AddCacheStore adds the cache store and the responder of the CA, whose state
+// is exposed when the metrics are collected.
+func (m *Metrics) AddCacheStore(ca string, cacheStore *cache.ResponseCacheStoreRO, responder *Responder) {
+	m.caches.mu.Lock()
+	defer m.caches.mu.Unlock()
+	m.caches.authorities = append(
+		m.caches.authorities, metricsAuthority{ca: ca, cacheStore: cacheStore, responder: responder},
+	)
+}
+
+// ServeHTTP writes the metrics in the Prometheus exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.handler.ServeHTTP(w, r)
+}
+
+func (m *Metrics) observeBatch(ca string, timings batchTimings) {
+	if m == nil {
+		return
+	}
+	m.batchDuration.WithLabelValues(ca, phaseScan).Observe(timings.scan.Seconds())
+	m.batchDuration.WithLabelValues(ca, phaseParse).Observe(timings.parse.Seconds())
+	m.batchDuration.WithLabelValues(ca, phaseSign).Observe(timings.sign.Seconds())
+	m.batchDuration.WithLabelValues(ca, phaseUpdate).Observe(timings.update.Seconds())
+}
+
+func (m *Metrics) incBatchFailure(ca, reason string) {
+	if m == nil {
+		return
+	}
+	m.batchFailures.WithLabelValues(ca, reason).Inc()
+}
+
+func (m *Metrics) addEntriesScanned(ca string, count int) {
+	if m == nil {
+		return
+	}
+	m.entriesScanned.WithLabelValues(ca).Add(float64(count))
+}
+
+func (m *Metrics) incEntryRejected(ca string, reason db.InvalidWith) {
+	if m == nil {
+		return
+	}
+	m.entriesRejected.WithLabelValues(ca, invalidWithLabels[reason]).Inc()
+}
+
+func (m *Metrics) incEntryExpired(ca, action string) {
+	if m == nil {
+		return
+	}
+	m.entriesExpired.WithLabelValues(ca, action).Inc()
+}
+
+func (m *Metrics) addEntriesSigned(ca string, count int) {
+	if m == nil {
+		return
+	}
+	m.entriesSigned.WithLabelValues(ca).Add(float64(count))
+}
+
+// requestMetrics records the response of a request.
+type requestMetrics struct {
+	http.ResponseWriter
+	code       int
+	body       []byte
+	certStatus string
+	succeeded  bool
+}
+
+func (r *requestMetrics) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *requestMetrics) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	// Only the error responses are compared
+	if r.body == nil && len(b) <= len(ocsp.MalformedRequestErrorResponse) {
+		r.body = bytes.Clone(b)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, so that http.ResponseController
+// reaches its optional interfaces.
+func (r *requestMetrics) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush flushes the wrapped http.ResponseWriter if it supports flushing.
+func (r *requestMetrics) Flush() {
+	_ = http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *requestMetrics) status() string {
+	if r.succeeded {
+		if r.code == http.StatusNotModified {
+			return statusNotModified
+		}
+		return statusSuccessful
+	}
+
+	for _, errRes := range errorResponseLabels {
+		if bytes.Equal(r.body, errRes.res) {
+			return errRes.label
+		}
+	}
+
+	return statusRejected
+}
+
+type requestMetricsKey struct{}
+
+// observeSuccess records the certificate status of the successful response.
+// For the response with multiple SingleResponses, certStatusMultiple is recorded.
+func observeSuccess(r *http.Request, certStatus string) {
+	if rm, ok := r.Context().Value(requestMetricsKey{}).(*requestMetrics); ok {
+		rm.succeeded = true
+		rm.certStatus = certStatus
+	}
+}
+
+// handleRequestMetrics records the number and the latency of the requests.
+func (m *Metrics) handleRequestMetrics(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rm := &requestMetrics{ResponseWriter: w}
+		h.ServeHTTP(rm, r.WithContext(context.WithValue(r.Context(), requestMetricsKey{}, rm)))
+
+		// Unexpected methods are not labeled as they are
+		method := r.Method
+		switch method {
+		case http.MethodGet, http.MethodPost, http.MethodHead:
+		default:
+			method = "other"
+		}
+
+		m.requestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		m.requests.WithLabelValues(method, rm.status(), rm.certStatus).Inc()
+	})
+}
@@ -0,0 +1,138 @@
+package dyocsp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/justinas/alice"
+	"github.com/yuxki/dyocsp/pkg/cache"
+	"github.com/yuxki/dyocsp/pkg/date"
+	"github.com/yuxki/dyocsp/pkg/db"
+)
+
+func testScrapeMetrics(t *testing.T, m *Metrics) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, MetricsPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code is 200 but got: %d", rec.Code)
+	}
+
+	return rec.Body.String()
+}
+
+func testAssertMetrics(t *testing.T, scraped string, want []string) {
+	t.Helper()
+
+	for _, line := range want {
+		if !strings.Contains(scraped, line+"\n") {
+			t.Errorf("Expected metric %q is not found in:\n%s", line, scraped)
+		}
+	}
+}
+
+func TestCacheHandler_ServeHTTP_Metrics(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDirectResponder(t)
+	cacheStore := cache.NewResponseCacheStore()
+	cacheStore.Update([]cache.ResponseCache{testCreateCacheForCert(t, responder, big.NewInt(1), 500)})
+
+	metrics := NewMetrics()
+	metrics.AddCacheStore("ca", cacheStore.NewReadOnlyCacheStore(), responder)
+	handler := NewCacheHandler(
+		cacheStore.NewReadOnlyCacheStore(), responder, alice.New(), WithHandlerMetrics(metrics),
+	)
+
+	good := testCreateMultiRequest(t, []testCertID{{responder, big.NewInt(1), crypto.SHA1}})
+	notFound := testCreateMultiRequest(t, []testCertID{{responder, big.NewInt(2), crypto.SHA1}})
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(good)),
+		httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(good)),
+		httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(notFound)),
+		httptest.NewRequest(http.MethodPost, "/", strings.NewReader("malformed")),
+		httptest.NewRequest(http.MethodPut, "/", bytes.NewReader(good)),
+	}
+	for _, req := range requests {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	scraped := testScrapeMetrics(t, metrics)
+	testAssertMetrics(t, scraped, []string{
+		`dyocsp_requests_total{cert_status="good",method="POST",status="successful"} 2`,
+		`dyocsp_requests_total{cert_status="",method="POST",status="unauthorized"} 1`,
+		`dyocsp_requests_total{cert_status="",method="POST",status="malformed_request"} 1`,
+		`dyocsp_requests_total{cert_status="",method="other",status="rejected"} 1`,
+		`dyocsp_request_duration_seconds_count{method="POST"} 4`,
+		`dyocsp_cache_entries{ca="ca"} 1`,
+	})
+	for _, name := range []string{"dyocsp_cache_next_update_seconds", "dyocsp_responder_certificate_expiry_seconds"} {
+		if !strings.Contains(scraped, name+`{ca="ca"} `) {
+			t.Errorf("Expected metric %s is not found.", name)
+		}
+	}
+}
+
+func TestCacheBatch_RunOnce_Metrics(t *testing.T) {
+	t.Parallel()
+
+	entries := testIntermidiateEntries("01", "02", "ZZ")
+	entries = append(entries, db.IntermidiateEntry{Ca: "test-ca", Serial: "03", RevType: "V", ExpDate: "200101000000Z"})
+
+	client := &StubScriptedCADBClient{scans: [][]db.IntermidiateEntry{entries, nil}}
+	responder := testCreateDelegatedResponder(t)
+	metrics := NewMetrics()
+	batch, err := NewCacheBatch(
+		"test-ca", cache.NewResponseCacheStore(), client, responder, date.NowGMT(),
+		WithExpiration(Invalid),
+		WithMetrics(metrics),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.TODO()
+	batch.RunOnce(ctx)
+	batch.metrics.observeBatch(batch.ca, batch.timings)
+	// The scan fails
+	batch.RunOnce(ctx)
+
+	testAssertMetrics(t, testScrapeMetrics(t, metrics), []string{
+		`dyocsp_entries_scanned_total{ca="test-ca"} 4`,
+		`dyocsp_entries_rejected_total{ca="test-ca",reason="malformed_serial"} 1`,
+		`dyocsp_entries_expired_total{action="dropped",ca="test-ca"} 1`,
+		`dyocsp_entries_signed_total{ca="test-ca"} 2`,
+		`dyocsp_batch_failures_total{ca="test-ca",reason="scan"} 1`,
+		`dyocsp_batch_phase_duration_seconds_count{ca="test-ca",phase="sign"} 1`,
+	})
+}
+
+func TestMetrics_handleRequestMetrics_ResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics()
+	rec := httptest.NewRecorder()
+	handler := m.handleRequestMetrics(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if u, ok := w.(interface{ Unwrap() http.ResponseWriter }); !ok || u.Unwrap() != rec {
+			t.Error("Expected the wrapped ResponseWriter is unwrapped.")
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("Expected the ResponseWriter is http.Flusher.")
+		}
+		flusher.Flush()
+	}))
+
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !rec.Flushed {
+		t.Error("Expected the response is flushed.")
+	}
+}
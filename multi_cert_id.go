@@ -0,0 +1,164 @@
+package dyocsp
+
+import (
+	"crypto"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog"
+	"github.com/yuxki/dyocsp/pkg/cache"
+	"github.com/yuxki/dyocsp/pkg/db"
+	"golang.org/x/crypto/ocsp"
+)
+
+// Default values of the requests with multiple CertIDs.
+const (
+	DefaultMaxCertIDs       = 1
+	DefaultMultiCertSigners = 8
+)
+
+// RFC 6960: 4.1.1. ASN.1 Specification of the OCSP Request.
+// (https://www.rfc-editor.org/rfc/rfc6960#section-4.1.1)
+type singleRequest struct {
+	ReqCert                 certID
+	SingleRequestExtensions []pkix.Extension `asn1:"explicit,tag:0,optional"`
+}
+
+var errUnknownHashAlgorithm = errors.New("unknown hash algorithm of CertID")
+
+// parseRequestCertIDs parses all CertIDs in the requestList of the OCSP request.
+// golang.org/x/crypto/ocsp parses only the first one. Each CertID is returned
+// as an ocsp.Request.
+func parseRequestCertIDs(der []byte) ([]*ocsp.Request, error) {
+	var req ocspRequestASN1
+	if _, err := asn1.Unmarshal(der, &req); err != nil {
+		return nil, err
+	}
+
+	reqs := make([]*ocsp.Request, 0, len(req.TBSRequest.RequestList))
+	for idx := range req.TBSRequest.RequestList {
+		var single singleRequest
+		if _, err := asn1.Unmarshal(req.TBSRequest.RequestList[idx].FullBytes, &single); err != nil {
+			return nil, err
+		}
+
+		hash, ok := hashFromOID(single.ReqCert.HashAlgorithm.Algorithm)
+		if !ok {
+			return nil, fmt.Errorf("%w: %v", errUnknownHashAlgorithm, single.ReqCert.HashAlgorithm.Algorithm)
+		}
+
+		reqs = append(reqs, &ocsp.Request{
+			HashAlgorithm:  hash,
+			IssuerNameHash: single.ReqCert.NameHash,
+			IssuerKeyHash:  single.ReqCert.IssuerKeyHash,
+			SerialNumber:   single.ReqCert.SerialNumber,
+		})
+	}
+
+	return reqs, nil
+}
+
+func hashFromOID(oid asn1.ObjectIdentifier) (crypto.Hash, bool) {
+	for hash, hashOID := range hashOIDs {
+		if hashOID.Equal(oid) {
+			return hash, true
+		}
+	}
+	return 0, false
+}
+
+// writeMultiResponse signs a response that has a SingleResponse for each CertID
+// of the request on demand, and writes it. All CertIDs must be of the same issuer.
+// If any of the serial numbers is not found and the cache miss behavior is
+// CacheMissUnauthorized, or its cache is expired, it sends ocsp.UnauthorizedErrorResponse.
+func (c CacheHandler) writeMultiResponse(
+	w http.ResponseWriter, r *http.Request, body []byte, reqs []*ocsp.Request, logger *zerolog.Logger,
+) {
+	writeRes := func(res []byte) {
+		if _, err := w.Write(res); err != nil {
+			logger.Error().Err(err).Msg("")
+		}
+	}
+
+	auth, err := c.matchAuthority(reqs[0])
+	if err == nil {
+		for _, req := range reqs[1:] {
+			if err = verifyIssuer(req, auth.responder); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("")
+		writeRes(ocsp.UnauthorizedErrorResponse)
+		return
+	}
+
+	nowT := c.now()
+	templates := make([]ocsp.Response, 0, len(reqs))
+	var exts []pkix.Extension
+	for _, req := range reqs {
+		resCache, ok := auth.cacheStore.Get(req.SerialNumber)
+		if !ok && c.cacheMiss != CacheMissUnauthorized {
+			negative, err := cache.CreateNegativeResponseCache(
+				req.SerialNumber, req.HashAlgorithm, c.cacheMiss.status(), nowT, c.negativeCacheTTL,
+			)
+			if err == nil {
+				resCache, ok = &negative, true
+				exts = c.cacheMiss.responseExtensions()
+			}
+		}
+		if !ok || nowT.Compare(resCache.Template().NextUpdate) > 0 {
+			logger.Error().Msgf("Request serial not matched or expired: %s", req.SerialNumber.Text(db.SerialBase))
+			writeRes(ocsp.UnauthorizedErrorResponse)
+			return
+		}
+
+		tmpl := resCache.Template()
+		// The CertID of the response matches the request
+		tmpl.IssuerHash = req.HashAlgorithm
+		templates = append(templates, tmpl)
+	}
+
+	if c.nonceSem != nil {
+		nonce, ok, err := parseRequestNonce(body, c.nonceMaxLength)
+		if err != nil {
+			logger.Debug().Err(err).Bytes("ocsp-request-bytes", body).Msg("")
+			writeRes(ocsp.MalformedRequestErrorResponse)
+			return
+		}
+		if ok {
+			exts = append([]pkix.Extension{nonce}, exts...)
+		}
+	}
+
+	select {
+	case c.multiCertSem <- struct{}{}:
+		defer func() { <-c.multiCertSem }()
+	default:
+		logger.Warn().Msg("Signing limit of multiple CertIDs reached.")
+		writeRes(ocsp.TryLaterErrorResponse)
+		return
+	}
+
+	res, err := auth.responder.SignMultiResponse(templates, exts...)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to sign the response of multiple CertIDs.")
+		writeRes(ocsp.InternalErrorErrorResponse)
+		return
+	}
+
+	observeSuccess(r, certStatusMultiple)
+	// The response is unique to the combination of the CertIDs, so it is not cached
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Date", nowT.Format(http.TimeFormat))
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.Itoa(len(res)))
+		return
+	}
+	writeRes(res)
+}
@@ -0,0 +1,250 @@
+package dyocsp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/justinas/alice"
+	"github.com/rs/zerolog/log"
+	"github.com/yuxki/dyocsp/pkg/cache"
+	"github.com/yuxki/dyocsp/pkg/date"
+	"golang.org/x/crypto/ocsp"
+)
+
+type testCertID struct {
+	responder *Responder
+	serial    *big.Int
+	alg       crypto.Hash
+}
+
+func testCreateMultiRequest(t *testing.T, certIDs []testCertID) []byte {
+	t.Helper()
+
+	var req ocspRequestASN1
+	for _, id := range certIDs {
+		nameHash, _ := id.responder.IssuerNameHash.Hash(id.alg)
+		keyHash, _ := id.responder.IssuerKeyHash.Hash(id.alg)
+		oid, ok := hashOIDs[id.alg]
+		if !ok {
+			// MD5
+			oid = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 5}
+		}
+
+		single, err := asn1.Marshal(singleRequest{
+			ReqCert: certID{
+				HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oid, Parameters: asn1.NullRawValue},
+				NameHash:      nameHash,
+				IssuerKeyHash: keyHash,
+				SerialNumber:  id.serial,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.TBSRequest.RequestList = append(req.TBSRequest.RequestList, asn1.RawValue{FullBytes: single})
+	}
+
+	der, err := asn1.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return der
+}
+
+func testSingleResponses(t *testing.T, der []byte) []singleResponse {
+	t.Helper()
+
+	var res responseASN1
+	if _, err := asn1.Unmarshal(der, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	var basic basicResponse
+	if _, err := asn1.Unmarshal(res.Response.Response, &basic); err != nil {
+		t.Fatal(err)
+	}
+
+	return basic.TBSResponseData.Responses
+}
+
+func TestParseRequestCertIDs(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDelegatedResponder(t)
+
+	certIDs := []testCertID{
+		{responder, big.NewInt(1), crypto.SHA1},
+		{responder, big.NewInt(2), crypto.SHA256},
+	}
+	reqs, err := parseRequestCertIDs(testCreateMultiRequest(t, certIDs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != len(certIDs) {
+		t.Fatalf("Expected %d CertIDs but got: %d", len(certIDs), len(reqs))
+	}
+	for idx, req := range reqs {
+		if req.SerialNumber.Cmp(certIDs[idx].serial) != 0 || req.HashAlgorithm != certIDs[idx].alg {
+			t.Errorf("Unexpected CertID: %v", req)
+		}
+		if err := verifyIssuer(req, responder); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// The unknown hash algorithm
+	unknown := testCreateMultiRequest(t, []testCertID{{responder, big.NewInt(1), crypto.MD5}})
+	if _, err := parseRequestCertIDs(unknown); !errors.Is(err, errUnknownHashAlgorithm) {
+		t.Errorf("Expected error is %#v but got: %#v", errUnknownHashAlgorithm, err)
+	}
+}
+
+func TestCacheHandler_ServeHTTP_MultipleCertIDs(t *testing.T) {
+	t.Parallel()
+
+	// The direct responder does not include its certificate in the response
+	responder := testCreateDirectResponder(t)
+	delegated := testCreateDelegatedResponder(t)
+
+	cached := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}
+	caches := make([]cache.ResponseCache, 0, len(cached))
+	for _, serial := range cached {
+		caches = append(caches, testCreateCacheForCert(t, responder, serial, 500))
+	}
+	cacheStore := cache.NewResponseCacheStore()
+	cacheStore.Update(caches)
+
+	data := []struct {
+		testcase string
+		opts     []CacheHandlerOption
+		certIDs  []testCertID
+		// want: the statuses of the SingleResponses, or the error response
+		statuses []int
+		errRes   []byte
+	}{
+		{
+			"only the first CertID by default",
+			nil,
+			[]testCertID{{responder, cached[0], crypto.SHA1}, {responder, cached[1], crypto.SHA1}},
+			[]int{ocsp.Good}, nil,
+		},
+		{
+			"all CertIDs are responded",
+			[]CacheHandlerOption{WithMaxCertIDs(3)},
+			[]testCertID{
+				{responder, cached[0], crypto.SHA1},
+				{responder, cached[1], crypto.SHA256},
+				{responder, cached[2], crypto.SHA1},
+			},
+			[]int{ocsp.Good, ocsp.Good, ocsp.Good}, nil,
+		},
+		{
+			"over max CertIDs",
+			[]CacheHandlerOption{WithMaxCertIDs(2)},
+			[]testCertID{
+				{responder, cached[0], crypto.SHA1},
+				{responder, cached[1], crypto.SHA1},
+				{responder, cached[2], crypto.SHA1},
+			},
+			nil, ocsp.MalformedRequestErrorResponse,
+		},
+		{
+			"serial not matched",
+			[]CacheHandlerOption{WithMaxCertIDs(2)},
+			[]testCertID{{responder, cached[0], crypto.SHA1}, {responder, big.NewInt(9), crypto.SHA1}},
+			nil, ocsp.UnauthorizedErrorResponse,
+		},
+		{
+			"serial not matched with unknown",
+			[]CacheHandlerOption{WithMaxCertIDs(2), WithCacheMiss(CacheMissUnknown)},
+			[]testCertID{{responder, cached[0], crypto.SHA1}, {responder, big.NewInt(9), crypto.SHA1}},
+			[]int{ocsp.Good, ocsp.Unknown}, nil,
+		},
+		{
+			"different issuers",
+			[]CacheHandlerOption{WithMaxCertIDs(2)},
+			[]testCertID{{responder, cached[0], crypto.SHA1}, {delegated, cached[1], crypto.SHA1}},
+			nil, ocsp.UnauthorizedErrorResponse,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			handler := NewCacheHandler(cacheStore.NewReadOnlyCacheStore(), responder, alice.New(), d.opts...)
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(testCreateMultiRequest(t, d.certIDs)))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if d.errRes != nil {
+				if !bytes.Equal(rec.Body.Bytes(), d.errRes) {
+					t.Fatalf("Expected error response %x but got: %x", d.errRes, rec.Body.Bytes())
+				}
+				return
+			}
+
+			singles := testSingleResponses(t, rec.Body.Bytes())
+			if len(singles) != len(d.statuses) {
+				t.Fatalf("Expected %d SingleResponses but got: %d", len(d.statuses), len(singles))
+			}
+
+			for idx, status := range d.statuses {
+				// The signature and the CertID of each SingleResponse are verified
+				cert := &x509.Certificate{SerialNumber: d.certIDs[idx].serial}
+				ocspRes, err := ocsp.ParseResponseForCert(rec.Body.Bytes(), cert, responder.rCert)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ocspRes.Status != status {
+					t.Errorf("Expected status of %d is %d but got: %d", idx, status, ocspRes.Status)
+				}
+				if ocspRes.IssuerHash != d.certIDs[idx].alg {
+					t.Errorf("Expected CertID hash of %d is %s but got: %s", idx, d.certIDs[idx].alg, ocspRes.IssuerHash)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheHandler_ServeHTTP_MultipleCertIDs_SigningLimit(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDelegatedResponder(t)
+	cacheStore := cache.NewResponseCacheStore()
+	cacheStore.Update([]cache.ResponseCache{
+		testCreateCacheForCert(t, responder, big.NewInt(1), 500),
+		testCreateCacheForCert(t, responder, big.NewInt(2), 500),
+	})
+
+	handler := CacheHandler{
+		authorities:  []authority{{cacheStore: cacheStore.NewReadOnlyCacheStore(), responder: responder}},
+		now:          date.NowGMT,
+		logger:       &log.Logger,
+		maxCertIDs:   2,
+		multiCertSem: make(chan struct{}, 1),
+	}
+	// All signers are busy
+	handler.multiCertSem <- struct{}{}
+
+	rawReq := testCreateMultiRequest(t, []testCertID{
+		{responder, big.NewInt(1), crypto.SHA1}, {responder, big.NewInt(2), crypto.SHA1},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(rawReq))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !bytes.Equal(rec.Body.Bytes(), ocsp.TryLaterErrorResponse) {
+		t.Fatal("Expected Try Later Error Response but got different bytes")
+	}
+}
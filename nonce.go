@@ -0,0 +1,67 @@
+package dyocsp
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// RFC 8954: 2.1. Nonce Extension.
+// (https://www.rfc-editor.org/rfc/rfc8954#section-2.1)
+var oidOCSPNonce = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+
+// The nonce length is limited to 1 to 32 octets by RFC 8954.
+const (
+	NonceMinLength = 1
+	NonceMaxLength = 32
+)
+
+// The ASN.1 structures of RFC 6960: 4.1.1. ASN.1 Specification of the OCSP Request.
+// (https://www.rfc-editor.org/rfc/rfc6960#section-4.1.1)
+// golang.org/x/crypto/ocsp does not parse the request extensions.
+
+type ocspRequestASN1 struct {
+	TBSRequest        tbsRequest
+	OptionalSignature asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type tbsRequest struct {
+	Version           int           `asn1:"explicit,tag:0,default:0,optional"`
+	RequestorName     asn1.RawValue `asn1:"explicit,tag:1,optional"`
+	RequestList       []asn1.RawValue
+	RequestExtensions []pkix.Extension `asn1:"explicit,tag:2,optional"`
+}
+
+var errNonceLength = errors.New("nonce length is out of range")
+
+// parseRequestNonce returns the nonce extension of the OCSP request. It returns
+// false when the request has no nonce extension, and returns an error when the
+// length of the nonce is not between 1 and maxLength octets.
+func parseRequestNonce(der []byte, maxLength int) (pkix.Extension, bool, error) {
+	var req ocspRequestASN1
+	if _, err := asn1.Unmarshal(der, &req); err != nil {
+		return pkix.Extension{}, false, err
+	}
+
+	for _, ext := range req.TBSRequest.RequestExtensions {
+		if !ext.Id.Equal(oidOCSPNonce) {
+			continue
+		}
+
+		// Nonce ::= OCTET STRING, but some clients send the raw nonce as the value
+		nonce := ext.Value
+		var octets []byte
+		if rest, err := asn1.Unmarshal(ext.Value, &octets); err == nil && len(rest) == 0 {
+			nonce = octets
+		}
+
+		if len(nonce) < NonceMinLength || len(nonce) > maxLength {
+			return ext, true, fmt.Errorf("%w: %d octets", errNonceLength, len(nonce))
+		}
+
+		return ext, true, nil
+	}
+
+	return pkix.Extension{}, false, nil
+}
@@ -0,0 +1,80 @@
+package dyocsp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestParseRequestNonce(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDelegatedResponder(t)
+	rawReq, err := ocsp.CreateRequest(responder.rCert, responder.issuerCert, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	octets := func(b []byte) []byte {
+		v, err := asn1.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	data := []struct {
+		testcase string
+		exts     []pkix.Extension
+		// want
+		found bool
+		err   error
+	}{
+		{"no extensions", nil, false, nil},
+		{
+			"other extension",
+			[]pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3}, Value: octets([]byte{0x01})}},
+			false, nil,
+		},
+		{"nonce", []pkix.Extension{{Id: oidOCSPNonce, Value: octets([]byte{0x01})}}, true, nil},
+		{"raw nonce", []pkix.Extension{{Id: oidOCSPNonce, Value: bytes.Repeat([]byte{0xff}, 32)}}, true, nil},
+		{"empty nonce", []pkix.Extension{{Id: oidOCSPNonce, Value: octets([]byte{})}}, true, errNonceLength},
+		{
+			"too long nonce",
+			[]pkix.Extension{{Id: oidOCSPNonce, Value: octets(bytes.Repeat([]byte{0x01}, 33))}},
+			true, errNonceLength,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			var req ocspRequestASN1
+			if _, err := asn1.Unmarshal(rawReq, &req); err != nil {
+				t.Fatal(err)
+			}
+			req.TBSRequest.RequestExtensions = d.exts
+			der, err := asn1.Marshal(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ext, found, err := parseRequestNonce(der, NonceMaxLength)
+			if !errors.Is(err, d.err) {
+				t.Fatalf("Expected error is %#v but got: %#v", d.err, err)
+			}
+			if found != d.found {
+				t.Fatalf("Expected found is %t but got: %t", d.found, found)
+			}
+			if found && !bytes.Equal(ext.Value, d.exts[0].Value) {
+				t.Errorf("Expected nonce %x but got: %x", d.exts[0].Value, ext.Value)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package dyocsp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// The ASN.1 structures of RFC 6960: 4.2.1. ASN.1 Specification of the OCSP Response.
+// (https://www.rfc-editor.org/rfc/rfc6960#section-4.2.1)
+// These are the same structures as golang.org/x/crypto/ocsp, which does not
+// export them.
+
+type certID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type responseASN1 struct {
+	Status   asn1.Enumerated
+	Response responseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponse struct {
+	TBSResponseData    responseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type responseData struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID     asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []singleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type singleResponse struct {
+	CertID           certID
+	Good             asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked          revokedInfo      `asn1:"tag:1,optional"`
+	Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate       time.Time        `asn1:"generalized"`
+	NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type revokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+var (
+	idPKIXOCSPBasic = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+	oidSignatureSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSignatureECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSignatureECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidSignatureECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	// RFC 8410: 3. Curve25519 and Curve448 Algorithm Identifiers.
+	oidSignatureEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+var hashOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA1:   {1, 3, 14, 3, 2, 26},
+	crypto.SHA256: {2, 16, 840, 1, 101, 3, 4, 2, 1},
+	crypto.SHA384: {2, 16, 840, 1, 101, 3, 4, 2, 2},
+	crypto.SHA512: {2, 16, 840, 1, 101, 3, 4, 2, 3},
+}
+
+var (
+	errUnsupportedSigningKey = errors.New("only RSA, ECDSA and Ed25519 keys are supported")
+	errNoResponseTemplate    = errors.New("no response template")
+)
+
+// signingParamsForPublicKey returns the hash function and the signature algorithm
+// for the public key. For Ed25519, the hash function is zero because Ed25519
+// signs the message itself.
+func signingParamsForPublicKey(pub crypto.PublicKey) (crypto.Hash, pkix.AlgorithmIdentifier, error) {
+	var sigAlgo pkix.AlgorithmIdentifier
+
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		sigAlgo.Algorithm = oidSignatureSHA256WithRSA
+		sigAlgo.Parameters = asn1.NullRawValue
+		return crypto.SHA256, sigAlgo, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P224(), elliptic.P256():
+			sigAlgo.Algorithm = oidSignatureECDSAWithSHA256
+			return crypto.SHA256, sigAlgo, nil
+		case elliptic.P384():
+			sigAlgo.Algorithm = oidSignatureECDSAWithSHA384
+			return crypto.SHA384, sigAlgo, nil
+		case elliptic.P521():
+			sigAlgo.Algorithm = oidSignatureECDSAWithSHA512
+			return crypto.SHA512, sigAlgo, nil
+		}
+		return 0, sigAlgo, errors.New("unknown elliptic curve")
+	case ed25519.PublicKey:
+		// RFC 8410: 3. The parameters MUST be absent.
+		sigAlgo.Algorithm = oidSignatureEd25519
+		return 0, sigAlgo, nil
+	}
+
+	return 0, sigAlgo, errUnsupportedSigningKey
+}
+
+func newSingleResponse(issuer *x509.Certificate, template ocsp.Response) (singleResponse, error) {
+	var publicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+		return singleResponse{}, err
+	}
+
+	if template.IssuerHash == 0 {
+		template.IssuerHash = crypto.SHA1
+	}
+	hashOID, ok := hashOIDs[template.IssuerHash]
+	if !ok {
+		return singleResponse{}, errors.New("unsupported issuer hash algorithm")
+	}
+
+	h := template.IssuerHash.New()
+	h.Write(publicKeyInfo.PublicKey.RightAlign())
+	issuerKeyHash := h.Sum(nil)
+
+	h.Reset()
+	h.Write(issuer.RawSubject)
+	issuerNameHash := h.Sum(nil)
+
+	single := singleResponse{
+		CertID: certID{
+			HashAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  hashOID,
+				Parameters: asn1.NullRawValue,
+			},
+			NameHash:      issuerNameHash,
+			IssuerKeyHash: issuerKeyHash,
+			SerialNumber:  template.SerialNumber,
+		},
+		ThisUpdate:       template.ThisUpdate.UTC(),
+		NextUpdate:       template.NextUpdate.UTC(),
+		SingleExtensions: template.ExtraExtensions,
+	}
+
+	switch template.Status {
+	case ocsp.Good:
+		single.Good = true
+	case ocsp.Unknown:
+		single.Unknown = true
+	case ocsp.Revoked:
+		single.Revoked = revokedInfo{
+			RevocationTime: template.RevokedAt.UTC(),
+			Reason:         asn1.Enumerated(template.RevocationReason),
+		}
+	}
+
+	return single, nil
+}
+
+// createOCSPResponse creates and signs an OCSP response in the same way as
+// ocsp.CreateResponse. Unlike ocsp.CreateResponse, it also supports Ed25519
+// signing keys, which produce the Ed25519 signature algorithm identifier, and
+// the response extensions (e.g. the nonce).
+func createOCSPResponse(
+	issuer, responderCert *x509.Certificate, template ocsp.Response, priv crypto.Signer,
+	responseExtensions []pkix.Extension,
+) ([]byte, error) {
+	return createOCSPResponses(issuer, responderCert, []ocsp.Response{template}, priv, responseExtensions)
+}
+
+// createOCSPResponses creates and signs an OCSP response that has a SingleResponse
+// for each template. The certificate of the first template is included in the
+// response.
+func createOCSPResponses(
+	issuer, responderCert *x509.Certificate, templates []ocsp.Response, priv crypto.Signer,
+	responseExtensions []pkix.Extension,
+) ([]byte, error) {
+	if len(templates) == 0 {
+		return nil, errNoResponseTemplate
+	}
+
+	singles := make([]singleResponse, 0, len(templates))
+	for idx := range templates {
+		single, err := newSingleResponse(issuer, templates[idx])
+		if err != nil {
+			return nil, err
+		}
+		singles = append(singles, single)
+	}
+
+	tbsResponseData := responseData{
+		Version: 0,
+		RawResponderID: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        1, // Name (explicit tag)
+			IsCompound: true,
+			Bytes:      responderCert.RawSubject,
+		},
+		ProducedAt:         time.Now().Truncate(time.Minute).UTC(),
+		Responses:          singles,
+		ResponseExtensions: responseExtensions,
+	}
+
+	tbsResponseDataDER, err := asn1.Marshal(tbsResponseData)
+	if err != nil {
+		return nil, err
+	}
+
+	hashFunc, sigAlgo, err := signingParamsForPublicKey(priv.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	// Ed25519 signs the message itself
+	digest := tbsResponseDataDER
+	if hashFunc != 0 {
+		h := hashFunc.New()
+		h.Write(tbsResponseDataDER)
+		digest = h.Sum(nil)
+	}
+
+	signature, err := priv.Sign(rand.Reader, digest, hashFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	response := basicResponse{
+		TBSResponseData:    tbsResponseData,
+		SignatureAlgorithm: sigAlgo,
+		Signature: asn1.BitString{
+			Bytes:     signature,
+			BitLength: bitsPerByte * len(signature),
+		},
+	}
+	if templates[0].Certificate != nil {
+		response.Certificates = []asn1.RawValue{
+			{FullBytes: templates[0].Certificate.Raw},
+		}
+	}
+
+	responseDER, err := asn1.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(responseASN1{
+		Status: asn1.Enumerated(ocsp.Success),
+		Response: responseBytes{
+			ResponseType: idPKIXOCSPBasic,
+			Response:     responseDER,
+		},
+	})
+}
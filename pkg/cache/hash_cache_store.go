@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"bytes"
+	"crypto"
+	"sync"
+	"time"
+)
+
+// hashCacheItem is the cache signed for the hash algorithm, and the SHA-1 hash
+// of the response of its source cache.
+type hashCacheItem struct {
+	cache      ResponseCache
+	sourceHash []byte
+}
+
+// HashCacheStore stores the signed responses of the caches of ResponseCacheStore
+// for the CertIDs hashed with the algorithms other than SHA-1. The caches of
+// ResponseCacheStore are signed for the SHA-1 CertIDs, but the CertID of the
+// response must match the request. Each response is stored with its source
+// cache, and it is stale when the source cache is updated. When the store is
+// full, the least recently used response is evicted.
+type HashCacheStore struct {
+	lru *lruCache[hashedCacheKey, hashCacheItem]
+	mu  sync.Mutex
+}
+
+// NewHashCacheStore creates and returns new instance of HashCacheStore, which
+// stores up to maxEntries caches.
+func NewHashCacheStore(maxEntries int) *HashCacheStore {
+	return &HashCacheStore{
+		lru: newLRUCache[hashedCacheKey, hashCacheItem](maxEntries),
+	}
+}
+
+// Get retrieves and returns the cache of the source cache signed for the hash
+// algorithm. If no cache is found, the source cache is updated, or the cache
+// is expired at nowT, it returns nil and false.
+func (h *HashCacheStore) Get(source *ResponseCache, hash crypto.Hash, nowT time.Time) (*ResponseCache, bool) {
+	key, ok := cacheMapKey(source.template.SerialNumber)
+	if !ok {
+		return nil, false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	item, ok := h.lru.get(hashedCacheKey{key, hash})
+	if !ok || !bytes.Equal(item.sourceHash, source.sha1Hash) || !item.cache.template.NextUpdate.After(nowT) {
+		return nil, false
+	}
+
+	return &item.cache, true
+}
+
+// Add stores the cache of the source cache signed for the hash algorithm.
+func (h *HashCacheStore) Add(source *ResponseCache, cache ResponseCache, hash crypto.Hash) bool {
+	key, ok := cacheMapKey(source.template.SerialNumber)
+	if !ok || cache.response == nil {
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lru.add(hashedCacheKey{key, hash}, hashCacheItem{cache: cache, sourceHash: source.SHA1Hash()})
+	return true
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"crypto"
+	"math/big"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func testHashCache(t *testing.T, serial int64, thisUpdate time.Time, response byte) ResponseCache {
+	t.Helper()
+
+	resCache := ResponseCache{template: ocsp.Response{
+		SerialNumber: big.NewInt(serial),
+		ThisUpdate:   thisUpdate,
+		NextUpdate:   thisUpdate.Add(time.Minute),
+	}}
+	if _, err := resCache.SetResponse([]byte{response}); err != nil {
+		t.Fatal(err)
+	}
+
+	return resCache
+}
+
+func TestHashCacheStore(t *testing.T) {
+	t.Parallel()
+
+	nowT := time.Date(2023, 9, 25, 0, 0, 0, 0, time.UTC)
+	store := NewHashCacheStore(2)
+
+	source1 := testHashCache(t, 1, nowT, 1)
+	source2 := testHashCache(t, 2, nowT, 2)
+	store.Add(&source1, testHashCache(t, 1, nowT, 11), crypto.SHA256)
+	store.Add(&source2, testHashCache(t, 2, nowT, 12), crypto.SHA256)
+
+	cache, ok := store.Get(&source1, crypto.SHA256, nowT)
+	if !ok || cache.Response()[0] != 11 {
+		t.Error("Cache is not found")
+	}
+	if _, ok := store.Get(&source1, crypto.SHA384, nowT); ok {
+		t.Error("Cache of the other hash algorithm is found")
+	}
+	if _, ok := store.Get(&source1, crypto.SHA256, nowT.Add(time.Minute)); ok {
+		t.Error("Expired cache is found")
+	}
+
+	// The cache is stale when the source cache is updated
+	updated := testHashCache(t, 1, nowT, 21)
+	if _, ok := store.Get(&updated, crypto.SHA256, nowT); ok {
+		t.Error("Cache of the updated source is found")
+	}
+
+	// The least recently used cache of 2 is evicted
+	source3 := testHashCache(t, 3, nowT, 3)
+	store.Add(&source3, testHashCache(t, 3, nowT, 13), crypto.SHA256)
+	if _, ok := store.Get(&source2, crypto.SHA256, nowT); ok {
+		t.Error("Least recently used cache is found")
+	}
+	for _, source := range []*ResponseCache{&source1, &source3} {
+		if _, ok := store.Get(source, crypto.SHA256, nowT); !ok {
+			t.Errorf("Cache is not found: %s", source.Template().SerialNumber)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package cache
+
+import "container/list"
+
+// lruCache is a map that keeps up to maxEntries values, and evicts the least
+// recently used value when a value is added to the full map. It is not safe for
+// concurrent use, so the stores lock it.
+type lruCache[K comparable, V any] struct {
+	maxEntries int
+	order      *list.List
+	items      map[K]*list.Element
+}
+
+type lruItem[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func newLRUCache[K comparable, V any](maxEntries int) *lruCache[K, V] {
+	return &lruCache[K, V]{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[K]*list.Element),
+	}
+}
+
+// get returns the value of the key, and marks it as the most recently used.
+func (l *lruCache[K, V]) get(key K) (V, bool) {
+	elem, ok := l.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	l.order.MoveToFront(elem)
+	return elem.Value.(*lruItem[K, V]).value, true
+}
+
+// add sets the value of the key as the most recently used. If the map is full,
+// the least recently used value is evicted.
+func (l *lruCache[K, V]) add(key K, value V) {
+	if elem, ok := l.items[key]; ok {
+		elem.Value.(*lruItem[K, V]).value = value
+		l.order.MoveToFront(elem)
+		return
+	}
+
+	if l.order.Len() >= l.maxEntries {
+		if oldest := l.order.Back(); oldest != nil {
+			l.order.Remove(oldest)
+			delete(l.items, oldest.Value.(*lruItem[K, V]).key)
+		}
+	}
+
+	l.items[key] = l.order.PushFront(&lruItem[K, V]{key, value})
+}
+
+// remove deletes the value of the key.
+func (l *lruCache[K, V]) remove(key K) {
+	if elem, ok := l.items[key]; ok {
+		l.order.Remove(elem)
+		delete(l.items, key)
+	}
+}
+
+// len returns the number of the values.
+func (l *lruCache[K, V]) len() int {
+	return l.order.Len()
+}
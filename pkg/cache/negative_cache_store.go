@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"crypto"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/yuxki/dyocsp/pkg/db"
+	"golang.org/x/crypto/ocsp"
+)
+
+// The revocation time of a non-issued certificate, RFC 6960: 2.2. Response.
+// (https://www.rfc-editor.org/rfc/rfc6960#section-2.2)
+var nonIssuedRevokedAt = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// CreateNegativeResponseCache creates a new instance of pre-signed ResponseCache
+// for the serial number that is not found in the database. The status must be
+// ocsp.Unknown or ocsp.Revoked. The revoked status follows the extended revoked
+// definition of RFC 6960, which has the certificateHold reason and the revocation
+// time of January 1, 1970. The CertID of the response is hashed with issuerHash.
+func CreateNegativeResponseCache(
+	serial *big.Int, issuerHash crypto.Hash, status int, thisUpdate time.Time, ttl time.Duration,
+) (ResponseCache, error) {
+	var resCache ResponseCache
+
+	if serial == nil {
+		return resCache, ResponseCacheNotCreatedError{"serial is nil."}
+	}
+
+	if len(serial.Text(db.SerialBase)) > db.SerialMaxOctetLength*2 {
+		return resCache, ResponseCacheNotCreatedError{"serial exceeds 20 octets."}
+	}
+
+	tmpl := ocsp.Response{
+		SerialNumber: serial,
+		IssuerHash:   issuerHash,
+		Status:       status,
+		ThisUpdate:   thisUpdate,
+		NextUpdate:   thisUpdate.Add(ttl),
+	}
+
+	switch status {
+	case ocsp.Unknown:
+	case ocsp.Revoked:
+		tmpl.RevokedAt = nonIssuedRevokedAt
+		tmpl.RevocationReason = ocsp.CertificateHold
+	default:
+		return resCache, ResponseCacheNotCreatedError{"status must be unknown or revoked."}
+	}
+
+	return ResponseCache{
+		entry:    db.CertificateEntry{Serial: serial},
+		template: tmpl,
+	}, nil
+}
+
+type hashedCacheKey struct {
+	serial string
+	hash   crypto.Hash
+}
+
+// NegativeCacheStore stores the signed responses for the serial numbers that
+// are not found in the ResponseCacheStore. Unlike ResponseCacheStore, the caches
+// are added one by one, and they are stored until their Next Update is past.
+// The responses are stored for each hash algorithm of the CertID, because the
+// CertID of the response must match the request. When the store is full, the
+// least recently used response is evicted.
+type NegativeCacheStore struct {
+	lru *lruCache[hashedCacheKey, ResponseCache]
+	mu  sync.Mutex
+}
+
+// NewNegativeCacheStore creates and returns new instance of NegativeCacheStore,
+// which stores up to maxEntries caches.
+func NewNegativeCacheStore(maxEntries int) *NegativeCacheStore {
+	return &NegativeCacheStore{
+		lru: newLRUCache[hashedCacheKey, ResponseCache](maxEntries),
+	}
+}
+
+// Get retrieves and returns the cache with the provided serial number and
+// hash algorithm. If no cache whose Next Update is after nowT is found, it
+// returns nil and false. The expired cache is deleted.
+func (n *NegativeCacheStore) Get(serialNumber *big.Int, hash crypto.Hash, nowT time.Time) (*ResponseCache, bool) {
+	key, ok := cacheMapKey(serialNumber)
+	if !ok {
+		return nil, false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	cache, ok := n.lru.get(hashedCacheKey{key, hash})
+	if !ok {
+		return nil, false
+	}
+	if !cache.template.NextUpdate.After(nowT) {
+		n.lru.remove(hashedCacheKey{key, hash})
+		return nil, false
+	}
+
+	return &cache, true
+}
+
+// Add stores the signed cache for the hash algorithm. When the store is full,
+// the least recently used cache is evicted. It returns false if the cache has
+// no serial number or response.
+func (n *NegativeCacheStore) Add(cache ResponseCache, hash crypto.Hash) bool {
+	key, ok := cacheMapKey(cache.template.SerialNumber)
+	if !ok || cache.response == nil {
+		return false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.lru.add(hashedCacheKey{key, hash}, cache)
+	return true
+}
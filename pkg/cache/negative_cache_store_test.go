@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"crypto"
+	"math/big"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func testNegativeCache(t *testing.T, serial int64, thisUpdate time.Time, ttl time.Duration) ResponseCache {
+	t.Helper()
+
+	resCache, err := CreateNegativeResponseCache(big.NewInt(serial), crypto.SHA1, ocsp.Unknown, thisUpdate, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resCache.SetResponse([]byte{byte(serial)})
+
+	return resCache
+}
+
+func TestCreateNegativeResponseCache(t *testing.T) {
+	t.Parallel()
+
+	thisUpdate := time.Date(2023, 9, 25, 0, 0, 0, 0, time.UTC)
+
+	data := []struct {
+		testcase string
+		serial   *big.Int
+		status   int
+		created  bool
+	}{
+		{"unknown", big.NewInt(1), ocsp.Unknown, true},
+		{"revoked", big.NewInt(1), ocsp.Revoked, true},
+		{"good is not negative", big.NewInt(1), ocsp.Good, false},
+		{"nil serial", nil, ocsp.Unknown, false},
+		{"serial over 20 octets", new(big.Int).Lsh(big.NewInt(1), 160), ocsp.Unknown, false},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			resCache, err := CreateNegativeResponseCache(d.serial, crypto.SHA256, d.status, thisUpdate, time.Minute)
+			if !d.created {
+				if err == nil {
+					t.Fatal("Expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tmpl := resCache.Template()
+			if tmpl.Status != d.status || tmpl.IssuerHash != crypto.SHA256 {
+				t.Errorf("Unexpected template: %v", tmpl)
+			}
+			if !tmpl.NextUpdate.Equal(thisUpdate.Add(time.Minute)) {
+				t.Errorf("Expected Next Update is %v but got: %v", thisUpdate.Add(time.Minute), tmpl.NextUpdate)
+			}
+			if d.status == ocsp.Revoked &&
+				(!tmpl.RevokedAt.Equal(time.Unix(0, 0)) || tmpl.RevocationReason != ocsp.CertificateHold) {
+				t.Errorf("Unexpected revoked info: %v %d", tmpl.RevokedAt, tmpl.RevocationReason)
+			}
+		})
+	}
+}
+
+func TestNegativeCacheStore(t *testing.T) {
+	t.Parallel()
+
+	nowT := time.Date(2023, 9, 25, 0, 0, 0, 0, time.UTC)
+	store := NewNegativeCacheStore(2)
+
+	if !store.Add(testNegativeCache(t, 1, nowT, time.Minute), crypto.SHA1) {
+		t.Fatal("Cache is not added")
+	}
+	if !store.Add(testNegativeCache(t, 2, nowT, 2*time.Minute), crypto.SHA1) {
+		t.Fatal("Cache is not added")
+	}
+
+	if _, ok := store.Get(big.NewInt(1), crypto.SHA1, nowT); !ok {
+		t.Error("Cache is not found")
+	}
+	if _, ok := store.Get(big.NewInt(1), crypto.SHA256, nowT); ok {
+		t.Error("Cache of the other hash algorithm is found")
+	}
+
+	// The least recently used cache of 2 is evicted from the full store
+	if !store.Add(testNegativeCache(t, 3, nowT, time.Minute), crypto.SHA1) {
+		t.Error("Cache is not added to the full store")
+	}
+	if _, ok := store.Get(big.NewInt(2), crypto.SHA1, nowT); ok {
+		t.Error("Least recently used cache is found")
+	}
+	for _, serial := range []int64{1, 3} {
+		if _, ok := store.Get(big.NewInt(serial), crypto.SHA1, nowT); !ok {
+			t.Errorf("Cache is not found: %d", serial)
+		}
+	}
+
+	// The expired cache is not found
+	later := nowT.Add(time.Minute)
+	if _, ok := store.Get(big.NewInt(1), crypto.SHA1, later); ok {
+		t.Error("Expired cache is found")
+	}
+	if store.Add(ResponseCache{}, crypto.SHA1) {
+		t.Error("Cache without serial is added")
+	}
+}
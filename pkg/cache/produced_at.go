@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"encoding/asn1"
+	"time"
+)
+
+// The ASN.1 structures of RFC 6960: 4.2.1. ASN.1 Specification of the OCSP Response,
+// reduced to reach producedAt. The remaining fields of the sequences are ignored.
+// (https://www.rfc-editor.org/rfc/rfc6960#section-4.2.1)
+
+type ocspResponseASN1 struct {
+	Status   asn1.Enumerated
+	Response struct {
+		ResponseType asn1.ObjectIdentifier
+		Response     []byte
+	} `asn1:"explicit,tag:0,optional"`
+}
+
+type basicOCSPResponseASN1 struct {
+	TBSResponseData struct {
+		Version        int `asn1:"optional,default:0,explicit,tag:0"`
+		RawResponderID asn1.RawValue
+		ProducedAt     time.Time `asn1:"generalized"`
+	}
+}
+
+// producedAtOf returns producedAt of the DER encoded OCSP response.
+// It returns false when the response is not a successful response.
+func producedAtOf(response []byte) (time.Time, bool) {
+	var res ocspResponseASN1
+	if _, err := asn1.Unmarshal(response, &res); err != nil || len(res.Response.Response) == 0 {
+		return time.Time{}, false
+	}
+
+	var basic basicOCSPResponseASN1
+	if _, err := asn1.Unmarshal(res.Response.Response, &basic); err != nil {
+		return time.Time{}, false
+	}
+
+	return basic.TBSResponseData.ProducedAt, true
+}
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"crypto"
 	"crypto/sha1"
 	"crypto/x509"
 	"fmt"
@@ -127,7 +128,15 @@ func (r *ResponseCache) SetCertToTemplate(cert *x509.Certificate) {
 	r.template.Certificate = cert
 }
 
+// SetIssuerHashToTemplate sets the hash algorithm of the CertID of the
+// ocsp.Response template. The CertID is hashed with SHA-1 if it is not set.
+func (r *ResponseCache) SetIssuerHashToTemplate(hash crypto.Hash) {
+	r.template.IssuerHash = hash
+}
+
 // SetResponse calculates and sets the SHA-1 hash of the provided signed OCSP.
+// ProducedAt of the template is set to the producedAt of the response,
+// which is used as Last-Modified by the handler.
 func (r *ResponseCache) SetResponse(response []byte) (*ResponseCache, error) {
 	tmp := make([]byte, len(response))
 	copy(tmp, response)
@@ -140,6 +149,9 @@ func (r *ResponseCache) SetResponse(response []byte) (*ResponseCache, error) {
 
 	r.response = response
 	r.sha1Hash = sha1.Sum(nil)
+	if producedAt, ok := producedAtOf(response); ok {
+		r.template.ProducedAt = producedAt
+	}
 
 	return r, nil
 }
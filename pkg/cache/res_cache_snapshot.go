@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yuxki/dyocsp/pkg/db"
+	"golang.org/x/crypto/ocsp"
+)
+
+// The version of the snapshot file format.
+const snapshotVersion = 1
+
+// ErrSnapshotVersion is returned when the snapshot file format is not supported.
+var ErrSnapshotVersion = errors.New("unsupported snapshot version")
+
+// snapshotFile is the content of a snapshot file. A snapshot is a generation of
+// signed response caches, which is stored as JSON.
+type snapshotFile struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Caches    []snapshotCache `json:"caches"`
+}
+
+type snapshotCache struct {
+	Serial           string    `json:"serial"`
+	CA               string    `json:"ca"`
+	RevType          string    `json:"revType"`
+	ExpDate          time.Time `json:"expDate"`
+	RevDate          time.Time `json:"revDate"`
+	CRLReason        int       `json:"crlReason"`
+	Subject          string    `json:"subject,omitempty"`
+	Status           int       `json:"status"`
+	RevokedAt        time.Time `json:"revokedAt"`
+	RevocationReason int       `json:"revocationReason"`
+	ThisUpdate       time.Time `json:"thisUpdate"`
+	NextUpdate       time.Time `json:"nextUpdate"`
+	Response         []byte    `json:"response"`
+	SHA1Hash         []byte    `json:"sha1Hash"`
+}
+
+func newSnapshotCache(cache ResponseCache) snapshotCache {
+	return snapshotCache{
+		Serial:           cache.template.SerialNumber.Text(db.SerialBase),
+		CA:               cache.entry.Ca,
+		RevType:          string(cache.entry.RevType),
+		ExpDate:          cache.entry.ExpDate,
+		RevDate:          cache.entry.RevDate,
+		CRLReason:        int(cache.entry.CRLReason),
+		Subject:          cache.entry.Subject,
+		Status:           cache.template.Status,
+		RevokedAt:        cache.template.RevokedAt,
+		RevocationReason: cache.template.RevocationReason,
+		ThisUpdate:       cache.template.ThisUpdate,
+		NextUpdate:       cache.template.NextUpdate,
+		Response:         cache.response,
+		SHA1Hash:         cache.sha1Hash,
+	}
+}
+
+func (s snapshotCache) responseCache() (ResponseCache, error) {
+	var resCache ResponseCache
+
+	serial, ok := new(big.Int).SetString(s.Serial, db.SerialBase)
+	if !ok {
+		return resCache, fmt.Errorf("invalid serial in snapshot: %s", s.Serial)
+	}
+
+	if len(s.Response) == 0 {
+		return resCache, fmt.Errorf("response is empty in snapshot: %s", s.Serial)
+	}
+
+	// The ETag must be the hash of the response
+	hash := sha1.Sum(s.Response)
+	if !bytes.Equal(hash[:], s.SHA1Hash) {
+		return resCache, fmt.Errorf("hash does not match the response in snapshot: %s", s.Serial)
+	}
+
+	resCache = ResponseCache{
+		entry: db.CertificateEntry{
+			Ca:        s.CA,
+			Serial:    serial,
+			RevType:   db.EntryRevType(s.RevType),
+			ExpDate:   s.ExpDate,
+			RevDate:   s.RevDate,
+			CRLReason: db.EntryCRLReason(s.CRLReason),
+			Subject:   s.Subject,
+		},
+		template: ocsp.Response{
+			SerialNumber:     serial,
+			Status:           s.Status,
+			RevokedAt:        s.RevokedAt,
+			RevocationReason: s.RevocationReason,
+			ThisUpdate:       s.ThisUpdate,
+			NextUpdate:       s.NextUpdate,
+		},
+		response: s.Response,
+		sha1Hash: s.SHA1Hash,
+	}
+	if producedAt, ok := producedAtOf(s.Response); ok {
+		resCache.template.ProducedAt = producedAt
+	}
+
+	return resCache, nil
+}
+
+// SaveSnapshot writes all caches in the store to the snapshot file.
+// The file is replaced atomically, so that a partially written snapshot
+// is never loaded.
+func (r *ResponseCacheStore) SaveSnapshot(file string) error {
+	caches := r.Caches()
+
+	snapshot := snapshotFile{
+		Version:   snapshotVersion,
+		CreatedAt: r.now(),
+		Caches:    make([]snapshotCache, 0, len(caches)),
+	}
+	for idx := range caches {
+		snapshot.Caches = append(snapshot.Caches, newSnapshotCache(caches[idx]))
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
+
+// LoadSnapshot updates the store with the caches in the snapshot file, and
+// returns the number of loaded caches. Caches whose Next Update is not in the
+// future are not loaded. A corrupt cache is skipped instead of failing the whole
+// snapshot, and the errors of the skipped caches are returned. The templates of
+// the loaded caches do not have the responder certificate, so they cannot be
+// signed again.
+func (r *ResponseCacheStore) LoadSnapshot(file string) (int, []error, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var snapshot snapshotFile
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return 0, nil, err
+	}
+
+	if snapshot.Version != snapshotVersion {
+		return 0, nil, fmt.Errorf("%w: %d", ErrSnapshotVersion, snapshot.Version)
+	}
+
+	now := r.now()
+	caches := make([]ResponseCache, 0, len(snapshot.Caches))
+	var skipped []error
+	for idx := range snapshot.Caches {
+		if !snapshot.Caches[idx].NextUpdate.After(now) {
+			continue
+		}
+
+		resCache, err := snapshot.Caches[idx].responseCache()
+		if err != nil {
+			skipped = append(skipped, err)
+			continue
+		}
+		caches = append(caches, resCache)
+	}
+
+	invs := r.Update(caches)
+
+	return len(caches) - len(invs), skipped, nil
+}
@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yuxki/dyocsp/pkg/db"
+	"golang.org/x/crypto/ocsp"
+)
+
+func testCreateSignedCache(t *testing.T, serial int64, nextUpdate time.Time) ResponseCache {
+	t.Helper()
+
+	resCache := ResponseCache{
+		entry: db.CertificateEntry{
+			Ca:        "sub-ca",
+			Serial:    big.NewInt(serial),
+			RevType:   db.Revoked,
+			ExpDate:   time.Date(2033, 8, 9, 12, 33, 17, 0, time.UTC),
+			RevDate:   time.Date(2023, 8, 9, 12, 33, 17, 0, time.UTC),
+			CRLReason: db.KeyCompromise,
+			Subject:   "/C=US/O=Example Organization/CN=revoked",
+		},
+		template: ocsp.Response{
+			SerialNumber:     big.NewInt(serial),
+			Status:           ocsp.Revoked,
+			RevokedAt:        time.Date(2023, 8, 9, 12, 33, 17, 0, time.UTC),
+			RevocationReason: ocsp.KeyCompromise,
+			ThisUpdate:       nextUpdate.Add(-time.Minute),
+			NextUpdate:       nextUpdate,
+		},
+	}
+	_, err := resCache.SetResponse([]byte{0x30, 0x03, 0x0a, 0x01, byte(serial)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resCache
+}
+
+func TestResponseCacheStore_SaveLoadSnapshot(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 8, 9, 12, 30, 0, 0, time.UTC)
+	valid := testCreateSignedCache(t, 1, now.Add(time.Minute))
+	expired := testCreateSignedCache(t, 2, now)
+
+	snapshotFile := filepath.Join(t.TempDir(), "sub-ca.snapshot.json")
+
+	saved := NewResponseCacheStore()
+	saved.now = func() time.Time { return now }
+	saved.Update([]ResponseCache{valid, expired})
+	if err := saved.SaveSnapshot(snapshotFile); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewResponseCacheStore()
+	loaded.now = func() time.Time { return now }
+	count, skipped, err := loaded.LoadSnapshot(snapshotFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 0 {
+		t.Fatalf("Expected no cache is skipped but got: %v", skipped)
+	}
+	if count != 1 {
+		t.Fatalf("Expected 1 cache is loaded but got: %d", count)
+	}
+
+	if _, ok := loaded.Get(expired.entry.Serial); ok {
+		t.Error("Expired cache is loaded from the snapshot.")
+	}
+
+	resCache, ok := loaded.Get(valid.entry.Serial)
+	if !ok {
+		t.Fatal("Valid cache is not loaded from the snapshot.")
+	}
+
+	if !reflect.DeepEqual(resCache.Response(), valid.Response()) {
+		t.Errorf("Expected response %x but got: %x", valid.Response(), resCache.Response())
+	}
+	if !reflect.DeepEqual(resCache.SHA1Hash(), valid.SHA1Hash()) {
+		t.Errorf("Expected hash %x but got: %x", valid.SHA1Hash(), resCache.SHA1Hash())
+	}
+	if !reflect.DeepEqual(resCache.Template(), valid.Template()) {
+		t.Errorf("Expected template %#v but got: %#v", valid.Template(), resCache.Template())
+	}
+	if !reflect.DeepEqual(resCache.Entry(), valid.Entry()) {
+		t.Errorf("Expected entry %#v but got: %#v", valid.Entry(), resCache.Entry())
+	}
+}
+
+func TestResponseCacheStore_LoadSnapshot_Errors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	data := []struct {
+		testCase string
+		// test data
+		content string
+		// want
+		err error
+	}{
+		{
+			"unsupported version",
+			`{"version":2,"caches":[]}`,
+			ErrSnapshotVersion,
+		},
+		{
+			"invalid JSON",
+			`{"version":1,"caches":[`,
+			nil,
+		},
+	}
+
+	for _, d := range data {
+		d := d
+		t.Run(d.testCase, func(t *testing.T) {
+			t.Parallel()
+
+			snapshotFile := filepath.Join(dir, d.testCase)
+			if err := os.WriteFile(snapshotFile, []byte(d.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			store := NewResponseCacheStore()
+			_, _, err := store.LoadSnapshot(snapshotFile)
+			if err == nil {
+				t.Fatal("Expected error but got nil.")
+			}
+			if d.err != nil && !errors.Is(err, d.err) {
+				t.Errorf("Expected %v but got: %v", d.err, err)
+			}
+		})
+	}
+}
+
+func TestResponseCacheStore_LoadSnapshot_CorruptCaches(t *testing.T) {
+	t.Parallel()
+
+	// The cache of 01 has the hash that does not match the response, the cache
+	// of 02 has the invalid serial, and the cache of 03 has no response
+	content := `{"version":1,"caches":[
+		{"serial":"01","nextUpdate":"2999-01-01T00:00:00Z","response":"MAMKAQE=","sha1Hash":"AAAA"},
+		{"serial":"xx","nextUpdate":"2999-01-01T00:00:00Z","response":"MAMKAQE="},
+		{"serial":"03","nextUpdate":"2999-01-01T00:00:00Z"},
+		{"serial":"04","nextUpdate":"2999-01-01T00:00:00Z","response":"MAMKAQQ=",
+			"sha1Hash":"U6dQoUqhkJli9xU8BByUA9nE3rU="}
+	]}`
+	snapshotFile := filepath.Join(t.TempDir(), "sub-ca.snapshot.json")
+	if err := os.WriteFile(snapshotFile, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewResponseCacheStore()
+	count, skipped, err := store.LoadSnapshot(snapshotFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 cache is loaded but got: %d", count)
+	}
+	if len(skipped) != 3 {
+		t.Errorf("Expected 3 caches are skipped but got: %v", skipped)
+	}
+	if _, ok := store.Get(big.NewInt(4)); !ok {
+		t.Error("Valid cache is not loaded from the snapshot.")
+	}
+}
@@ -19,7 +19,16 @@ type ResponseCacheStore struct {
 	cacheMap  map[string]ResponseCache
 	now       date.Now
 	UpdatedAt time.Time
-	mu        sync.RWMutex
+	// The earliest Next Update of the caches
+	oldestNextUpdate time.Time
+	mu               sync.RWMutex
+}
+
+// StoreStats is the summary of the caches in the store.
+type StoreStats struct {
+	UpdatedAt        time.Time
+	Entries          int
+	OldestNextUpdate time.Time
 }
 
 // NewResponseCacheStore creates and retruns new instance of ResponseCacheStore.
@@ -45,14 +54,10 @@ func cacheMapKey(s *big.Int) (string, bool) {
 // This method returns nil when there are no duplicated serial numbers in the
 // ocsp response and returns the duplicated serial numbers when they exist.
 func (r *ResponseCacheStore) Update(caches []ResponseCache) []ResponseCache {
-	defer func() {
-		r.UpdatedAt = r.now()
-	}()
-
 	invalids := make([]ResponseCache, 0, len(caches))
 
 	if caches == nil {
-		r.cacheMap = make(map[string]ResponseCache, 0)
+		r.replace(make(map[string]ResponseCache, 0), true)
 		return invalids
 	}
 
@@ -90,9 +95,65 @@ func (r *ResponseCacheStore) Update(caches []ResponseCache) []ResponseCache {
 		cacheMap[key] = caches[idx]
 	}
 
+	r.replace(cacheMap, true)
+
+	return invalids
+}
+
+// replace replaces the cache map, and computes the earliest Next Update of the
+// caches. If updated is true, the update date is also changed.
+func (r *ResponseCacheStore) replace(cacheMap map[string]ResponseCache, updated bool) {
+	var oldest time.Time
+	for _, cache := range cacheMap {
+		nextUpdate := cache.template.NextUpdate
+		if oldest.IsZero() || nextUpdate.Before(oldest) {
+			oldest = nextUpdate
+		}
+	}
+
 	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	r.cacheMap = cacheMap
-	r.mu.Unlock()
+	r.oldestNextUpdate = oldest
+	if updated {
+		r.UpdatedAt = r.now()
+	}
+}
+
+// Patch replaces the caches of the same serial numbers with the provided caches,
+// and deletes the caches of the removed serial numbers. The other caches are
+// kept. Unlike Update, the update date is not changed, because the caches are
+// not updated as a whole. This method returns the caches that have no serial
+// number or no ocsp response, and they are not stored.
+func (r *ResponseCacheStore) Patch(caches []ResponseCache, removed []*big.Int) []ResponseCache {
+	invalids := make([]ResponseCache, 0, len(caches))
+
+	r.mu.RLock()
+	cm := r.cacheMap
+	r.mu.RUnlock()
+
+	cacheMap := make(map[string]ResponseCache, len(cm)+len(caches))
+	for key, cache := range cm {
+		cacheMap[key] = cache
+	}
+
+	for idx := range removed {
+		if key, ok := cacheMapKey(removed[idx]); ok {
+			delete(cacheMap, key)
+		}
+	}
+
+	for idx := range caches {
+		key, ok := cacheMapKey(caches[idx].Template().SerialNumber)
+		if !ok || caches[idx].Response() == nil {
+			invalids = append(invalids, caches[idx])
+			continue
+		}
+		cacheMap[key] = caches[idx]
+	}
+
+	r.replace(cacheMap, false)
 
 	return invalids
 }
@@ -128,6 +189,34 @@ func (r *ResponseCacheStore) Get(serialNumber *big.Int) (*ResponseCache, bool) {
 	return &cache, true
 }
 
+// Caches returns a copy of all caches in the store. The order of the caches
+// is not specified.
+func (r *ResponseCacheStore) Caches() []ResponseCache {
+	r.mu.RLock()
+	cm := r.cacheMap
+	r.mu.RUnlock()
+
+	caches := make([]ResponseCache, 0, len(cm))
+	for _, cache := range cm {
+		caches = append(caches, cache)
+	}
+
+	return caches
+}
+
+// Stats returns the update date, the number of the caches, and the earliest
+// Next Update of the caches. If the store is empty, OldestNextUpdate is zero.
+func (r *ResponseCacheStore) Stats() StoreStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return StoreStats{
+		UpdatedAt:        r.UpdatedAt,
+		Entries:          len(r.cacheMap),
+		OldestNextUpdate: r.oldestNextUpdate,
+	}
+}
+
 // NewReadOnlyCacheStore creates and returns new ResponseCacheStoreRO instance.
 // ResponseCacheStoreRO is a wrapper around the ResponseCacheStore object,
 // providing only read APIs.
@@ -14,3 +14,8 @@ type ResponseCacheStoreRO struct {
 func (r *ResponseCacheStoreRO) Get(serialNumber *big.Int) (*ResponseCache, bool) {
 	return r.cacheStore.Get(serialNumber)
 }
+
+// Stats is a simple wrapper the Stats method of the ResponseCacheStore.
+func (r *ResponseCacheStoreRO) Stats() StoreStats {
+	return r.cacheStore.Stats()
+}
@@ -226,3 +226,126 @@ func TestResponseCacheStore_Update_Get_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestResponseCacheStore_Caches(t *testing.T) {
+	t.Parallel()
+
+	caches := make([]ResponseCache, 0, 3)
+	for _, serial := range []int64{1, 2, 3} {
+		resCache := ResponseCache{
+			entry:    db.CertificateEntry{Serial: big.NewInt(serial)},
+			template: ocsp.Response{SerialNumber: big.NewInt(serial)},
+		}
+		_, err := resCache.SetResponse([]byte("test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		caches = append(caches, resCache)
+	}
+
+	cacheStore := NewResponseCacheStore()
+	if len(cacheStore.Caches()) != 0 {
+		t.Fatal("Empty store returned caches.")
+	}
+
+	cacheStore.Update(caches)
+	stored := cacheStore.Caches()
+	if len(stored) != len(caches) {
+		t.Fatalf("Expected %d caches but got: %d", len(caches), len(stored))
+	}
+
+	// Updating the store does not change the returned caches
+	cacheStore.Update(nil)
+	if len(stored) != len(caches) {
+		t.Fatalf("Returned caches are changed by update: %d", len(stored))
+	}
+}
+
+func TestResponseCacheStore_Patch(t *testing.T) {
+	t.Parallel()
+
+	newCache := func(serial int64, response string) ResponseCache {
+		resCache := ResponseCache{
+			entry:    db.CertificateEntry{Serial: big.NewInt(serial)},
+			template: ocsp.Response{SerialNumber: big.NewInt(serial)},
+		}
+		_, err := resCache.SetResponse([]byte(response))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resCache
+	}
+
+	cacheStore := NewResponseCacheStore()
+	cacheStore.Update([]ResponseCache{newCache(1, "old"), newCache(2, "old"), newCache(3, "old")})
+
+	invs := cacheStore.Patch(
+		[]ResponseCache{newCache(2, "new"), newCache(4, "new"), {}},
+		[]*big.Int{big.NewInt(3), nil},
+	)
+	if len(invs) != 1 {
+		t.Errorf("Expected 1 invalid cache but got: %d", len(invs))
+	}
+
+	want := map[int64]string{1: "old", 2: "new", 4: "new"}
+	for serial, response := range want {
+		resCache, ok := cacheStore.Get(big.NewInt(serial))
+		if !ok {
+			t.Errorf("Cache is not found: %d", serial)
+			continue
+		}
+		if string(resCache.Response()) != response {
+			t.Errorf("Expected response of %d is %s but got: %s", serial, response, resCache.Response())
+		}
+	}
+
+	if _, ok := cacheStore.Get(big.NewInt(3)); ok {
+		t.Error("Removed cache is found: 3")
+	}
+}
+
+func TestResponseCacheStore_Stats(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	newCache := func(serial int64, nextUpdate time.Time) ResponseCache {
+		resCache := ResponseCache{
+			entry:    db.CertificateEntry{Serial: big.NewInt(serial)},
+			template: ocsp.Response{SerialNumber: big.NewInt(serial), NextUpdate: nextUpdate},
+		}
+		_, err := resCache.SetResponse([]byte("test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resCache
+	}
+
+	cacheStore := NewResponseCacheStore()
+	if stats := cacheStore.Stats(); stats.Entries != 0 || !stats.OldestNextUpdate.IsZero() {
+		t.Fatalf("Unexpected stats of the empty store: %#v", stats)
+	}
+
+	cacheStore.Update([]ResponseCache{
+		newCache(1, base.Add(time.Hour)), newCache(2, base), newCache(3, base.Add(time.Minute)),
+	})
+	stats := cacheStore.Stats()
+	if stats.Entries != 3 {
+		t.Errorf("Expected 3 entries but got: %d", stats.Entries)
+	}
+	if !stats.OldestNextUpdate.Equal(base) {
+		t.Errorf("Expected oldest next update is %s but got: %s", base, stats.OldestNextUpdate)
+	}
+
+	// Patching does not change the update date
+	cacheStore.Patch([]ResponseCache{newCache(4, base.Add(time.Hour))}, []*big.Int{big.NewInt(2)})
+	patched := cacheStore.NewReadOnlyCacheStore().Stats()
+	if patched.Entries != 3 {
+		t.Errorf("Expected 3 entries but got: %d", patched.Entries)
+	}
+	if !patched.OldestNextUpdate.Equal(base.Add(time.Minute)) {
+		t.Errorf("Expected oldest next update is %s but got: %s", base.Add(time.Minute), patched.OldestNextUpdate)
+	}
+	if !patched.UpdatedAt.Equal(stats.UpdatedAt) {
+		t.Error("Update date is changed by patch.")
+	}
+}
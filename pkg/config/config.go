@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/rs/zerolog"
 )
@@ -20,16 +22,55 @@ type DyOCSPConfig struct {
 	CA                       string
 	Certificate              string
 	Key                      string
+	KeyPassphraseFile        string
+	PKCS11Module             string
+	PKCS11TokenLabel         string
+	PKCS11KeyLabel           string
 	Issuer                   string
 	Interval                 int
 	Delay                    int
+	ScanFailure              string
+	DropThreshold            int
+	SnapshotDir              string
+	Workers                  int
 	DynamoDBRegion           string
 	DynamoDBTableName        string
 	DynamoDBCAGsi            string
 	DynamoDBEndpoint         string
 	DynamoDBRetryMaxAttempts int
 	DynamoDBTimeout          int
+	DynamoDBSegments         int
+	DynamoDBFullScan         bool
+	DynamoDBStreams          bool
+	DynamoDBStreamsPoll      int
 	FileDBFile               string
+	FileDBWatch              bool
+	FileDBWatchDebounce      int
+	SQLDriver                string
+	SQLDSN                   string
+	SQLTable                 string
+	SQLColumns               SQLColumnsYAML
+	SQLQuery                 string
+	SQLTimeout               int
+	CRLDBFiles               []string
+	CRLDBCertDir             string
+	CRLDBWatch               bool
+	CRLDBWatchDebounce       int
+	CertDirDBDir             string
+	CertDirDBRevocations     string
+	CertDirDBWatch           bool
+	CertDirDBWatchDebounce   int
+	HTTPDBURL                string
+	HTTPDBCAFile             string
+	HTTPDBTimeout            int
+	HTTPDBRetries            int
+	HTTPDBPagination         string
+	HTTPDBCursorParam        string
+	HTTPDBNextCursor         string
+	HTTPDBOffsetParam        string
+	HTTPDBLimitParam         string
+	HTTPDBLimit              int
+	HTTPDBFields             HTTPFields
 	Port                     string
 	Domain                   string
 	ReadTimeout              int
@@ -38,12 +79,193 @@ type DyOCSPConfig struct {
 	MaxHeaderBytes           int
 	MaxRequestBytes          int
 	CacheControlMaxAge       int
+	ShutdownTimeout          int
+	ShutdownDelay            int
+	NonceSigning             bool
+	NonceMaxConcurrency      int
+	NonceMaxLength           int
+	CacheMiss                string
+	CacheMissTTL             int
+	CacheMissMaxEntries      int
+	CacheMissConcurrency     int
+	HashCacheMaxEntries      int
+	HashCacheConcurrency     int
+	MultiCertIDs             bool
+	MaxCertIDs               int
+	MultiCertIDsConcurrency  int
+	HTTP2                    bool
+	TLS                      bool
+	TLSPort                  string
+	TLSCertificate           string
+	TLSKey                   string
+	TLSMinVersion            string
+	TLSClientCA              string
+	TLSReloadInterval        int
+	TLSDisablePlain          bool
+	AdminPort                string
+	HealthMaxUpdateAge       int
+	HealthMinEntries         int
+	Metrics                  bool
+	AdminAPI                 bool
+	AdminAPITokenFile        string
+	// Configurations of each responder in multi-CA mode.
+	// Each configuration inherits the global parameters.
+	Responders []DyOCSPConfig
 	// From this struct
 	ZerologLevel  zerolog.Level
 	ZerologFormat LogFormat
 	DBType        CADBType
 }
 
+// ResponderYAML is the responder section of the configuration file.
+type ResponderYAML struct {
+	CA                string      `yaml:"ca"`
+	Certificate       string      `yaml:"responder_certificate"`
+	Key               string      `yaml:"responder_key"`
+	KeyPassphraseFile string      `yaml:"responder_key_passphrase_file"`
+	PKCS11            *PKCS11YAML `yaml:"pkcs11"`
+	Issuer            string      `yaml:"issuer_certificate"`
+}
+
+// PKCS11YAML is the pkcs11 section of the responder section. It refers to
+// the responder private key stored in a PKCS #11 token.
+type PKCS11YAML struct {
+	Module     string `yaml:"module"`
+	TokenLabel string `yaml:"token_label"`
+	KeyLabel   string `yaml:"key_label"`
+}
+
+// DBYAML is the db section of the configuration file.
+type DBYAML struct {
+	DynamoDB *struct {
+		Region           string `yaml:"region"`
+		TableName        string `yaml:"table_name"`
+		CAGsi            string `yaml:"ca_gsi"`
+		Endpoint         string `yaml:"endpoint"`
+		RetryMaxAttempts *int   `yaml:"retry_max_attempts"`
+		Timeout          *int   `yaml:"timeout"`
+		Segments         *int   `yaml:"segments"`
+		FullScan         bool   `yaml:"full_scan"`
+		Streams          *struct {
+			PollInterval *int `yaml:"poll_interval"`
+		} `yaml:"streams"`
+	} `yaml:"dynamodb"`
+	FileDB *struct {
+		File  string `yaml:"file"`
+		Watch *struct {
+			Debounce *int `yaml:"debounce"`
+		} `yaml:"watch"`
+	} `yaml:"file"`
+	SQL *struct {
+		Driver  string         `yaml:"driver"`
+		DSN     string         `yaml:"dsn"`
+		Table   string         `yaml:"table"`
+		Columns SQLColumnsYAML `yaml:"columns"`
+		Query   string         `yaml:"query"`
+		Timeout *int           `yaml:"timeout"`
+	} `yaml:"sql"`
+	CRL *struct {
+		Files   []string `yaml:"files"`
+		CertDir string   `yaml:"cert_dir"`
+		Watch   *struct {
+			Debounce *int `yaml:"debounce"`
+		} `yaml:"watch"`
+	} `yaml:"crl"`
+	CertDir *struct {
+		Dir         string `yaml:"dir"`
+		Revocations string `yaml:"revocations"`
+		Watch       *struct {
+			Debounce *int `yaml:"debounce"`
+		} `yaml:"watch"`
+	} `yaml:"certificates"`
+	HTTP *struct {
+		URL        string `yaml:"url"`
+		CAFile     string `yaml:"ca_file"`
+		Timeout    *int   `yaml:"timeout"`
+		Retries    *int   `yaml:"retries"`
+		Pagination *struct {
+			Type        string `yaml:"type"`
+			CursorParam string `yaml:"cursor_param"`
+			NextCursor  string `yaml:"next_cursor"`
+			OffsetParam string `yaml:"offset_param"`
+			LimitParam  string `yaml:"limit_param"`
+			Limit       *int   `yaml:"limit"`
+		} `yaml:"pagination"`
+		Fields HTTPFieldsYAML `yaml:"fields"`
+	} `yaml:"http_json"`
+}
+
+// SQLColumnsYAML is the columns section of the sql section. It maps the columns
+// of the table to the attributes of the entries.
+type SQLColumnsYAML struct {
+	CA        string `yaml:"ca"`
+	Serial    string `yaml:"serial"`
+	RevType   string `yaml:"rev_type"`
+	ExpDate   string `yaml:"exp_date"`
+	RevDate   string `yaml:"rev_date"`
+	CRLReason string `yaml:"crl_reason"`
+}
+
+// HTTPFieldsYAML is the fields section of the http_json section.
+// It maps the JSON paths of the responses to the attributes of the entries.
+// CA is a pointer, because the empty CA means that the endpoint serves only
+// the items of the CA.
+type HTTPFieldsYAML struct {
+	Items     string  `yaml:"items"`
+	CA        *string `yaml:"ca"`
+	Serial    string  `yaml:"serial"`
+	RevType   string  `yaml:"rev_type"`
+	ExpDate   string  `yaml:"exp_date"`
+	RevDate   string  `yaml:"rev_date"`
+	CRLReason string  `yaml:"crl_reason"`
+	Subject   string  `yaml:"subject"`
+}
+
+// HTTPFields is the verified JSON paths of the http_json section. If CA is
+// empty, the items have no CA, and they are the items of the configured CA.
+type HTTPFields struct {
+	Items     string
+	CA        string
+	Serial    string
+	RevType   string
+	ExpDate   string
+	RevDate   string
+	CRLReason string
+	Subject   string
+}
+
+// TLSYAML is the tls section of the http section. It configures the TLS listener.
+type TLSYAML struct {
+	Port           string `yaml:"port"`
+	Certificate    string `yaml:"certificate"`
+	Key            string `yaml:"key"`
+	MinVersion     string `yaml:"min_version"`
+	ClientCA       string `yaml:"client_ca"`
+	ReloadInterval *int   `yaml:"reload_interval"`
+	DisablePlain   bool   `yaml:"disable_plain"`
+}
+
+// AdminYAML is the admin section of the configuration file. It configures the
+// endpoints for the operators, such as the health checks.
+type AdminYAML struct {
+	Port    string `yaml:"port"`
+	Metrics bool   `yaml:"metrics"`
+	Health  struct {
+		MaxUpdateAge *int `yaml:"max_update_age"`
+		MinEntries   *int `yaml:"min_entries"`
+	} `yaml:"health"`
+	API *struct {
+		TokenFile string `yaml:"token_file"`
+	} `yaml:"api"`
+}
+
+// MultiResponderYAML is an item of the responders section of the configuration
+// file. It has the responder parameters and its own db section.
+type MultiResponderYAML struct {
+	ResponderYAML `yaml:",inline"`
+	DB            DBYAML `yaml:"db"`
+}
+
 // The ConfigYAML is a configuration file in YAML format.
 // To indicate a non-specified status, the member of type int should be a pointer.
 // This struct instance verifies the instance's own members and creates a DyOCSPConfig
@@ -56,29 +278,17 @@ type ConfigYAML struct {
 		Level  string `yaml:"level"`
 		Format string `yaml:"format"`
 	} `yaml:"log"`
-	Responder struct {
-		CA          string `yaml:"ca"`
-		Certificate string `yaml:"responder_certificate"`
-		Key         string `yaml:"responder_key"`
-		Issuer      string `yaml:"issuer_certificate"`
-	} `yaml:"responder"`
-	Cache struct {
-		Interval *int `yaml:"interval"`
-		Delay    *int `yaml:"delay"`
+	Responder  ResponderYAML        `yaml:"responder"`
+	Responders []MultiResponderYAML `yaml:"responders"`
+	Cache      struct {
+		Interval      *int   `yaml:"interval"`
+		Delay         *int   `yaml:"delay"`
+		ScanFailure   string `yaml:"scan_failure"`
+		DropThreshold *int   `yaml:"drop_threshold"`
+		SnapshotDir   string `yaml:"snapshot_dir"`
+		Workers       *int   `yaml:"workers"`
 	} `yaml:"cache"`
-	DB struct {
-		DynamoDB *struct {
-			Region           string `yaml:"region"`
-			TableName        string `yaml:"table_name"`
-			CAGsi            string `yaml:"ca_gsi"`
-			Endpoint         string `yaml:"endpoint"`
-			RetryMaxAttempts *int   `yaml:"retry_max_attempts"`
-			Timeout          *int   `yaml:"timeout"`
-		} `yaml:"dynamodb"`
-		FileDB *struct {
-			File string `yaml:"file"`
-		} `yaml:"file"`
-	} `yaml:"db"`
+	DB   DBYAML `yaml:"db"`
 	HTTP struct {
 		Port               string `yaml:"port"`
 		Domain             string `yaml:"domain"`
@@ -88,7 +298,30 @@ type ConfigYAML struct {
 		MaxHeaderBytes     *int   `yaml:"max_header_bytes"`
 		MaxRequestBytes    *int   `yaml:"max_request_bytes"`
 		CacheControlMaxAge *int   `yaml:"cache_control_max_age"`
+		ShutdownTimeout    *int   `yaml:"shutdown_timeout"`
+		ShutdownDelay      *int   `yaml:"shutdown_delay"`
+		Nonce              *struct {
+			MaxConcurrency *int `yaml:"max_concurrency"`
+			MaxLength      *int `yaml:"max_length"`
+		} `yaml:"nonce"`
+		CacheMiss struct {
+			Response       string `yaml:"response"`
+			TTL            *int   `yaml:"ttl"`
+			MaxEntries     *int   `yaml:"max_entries"`
+			MaxConcurrency *int   `yaml:"max_concurrency"`
+		} `yaml:"cache_miss"`
+		HashCache struct {
+			MaxEntries     *int `yaml:"max_entries"`
+			MaxConcurrency *int `yaml:"max_concurrency"`
+		} `yaml:"hash_cache"`
+		MultiCertIDs *struct {
+			MaxCertIDs     *int `yaml:"max_cert_ids"`
+			MaxConcurrency *int `yaml:"max_concurrency"`
+		} `yaml:"multiple_cert_ids"`
+		HTTP2 bool     `yaml:"http2"`
+		TLS   *TLSYAML `yaml:"tls"`
 	} `yaml:"http"`
+	Admin AdminYAML `yaml:"admin"`
 }
 
 // Supported CA DB type.
@@ -99,6 +332,14 @@ const (
 	FileDBType CADBType = iota
 	// DynamoDB.
 	DynamoDBType
+	// SQL DB.
+	SQLDBType
+	// CRL files.
+	CRLDBType
+	// Directory of issued certificates.
+	CertDirDBType
+	// HTTP JSON endpoint.
+	HTTPDBType
 )
 
 // Supported log format.
@@ -113,17 +354,45 @@ const (
 
 // Default values.
 const (
-	ReadTimeOutDefault       = 30
-	WriteTimeOutDefault      = 0
-	ReadHeaderTimeoutDefault = 10
-	MaxHeaderBytesDefault    = 1048576
-	MaxRequestBytesDefault   = 256
-	DynamoDBTimeoutDefault   = 60
-	IntervalDefault          = 60
-	DelayDefault             = 5
-	LogLevelDefault          = "info"
-	LogFormtDefault          = "json"
-	ExpirationDefault        = "ignore"
+	ReadTimeOutDefault             = 30
+	WriteTimeOutDefault            = 0
+	ReadHeaderTimeoutDefault       = 10
+	MaxHeaderBytesDefault          = 1048576
+	MaxRequestBytesDefault         = 256
+	DynamoDBTimeoutDefault         = 60
+	DynamoDBSegmentsDefault        = 1
+	DynamoDBSegmentsMax            = 1000000
+	DynamoDBStreamsPollDefault     = 1
+	IntervalDefault                = 60
+	DelayDefault                   = 5
+	WorkersDefault                 = 1
+	LogLevelDefault                = "info"
+	LogFormtDefault                = "json"
+	ExpirationDefault              = "ignore"
+	ScanFailureDefault             = "keep"
+	NonceMaxConcurrencyDefault     = 8
+	NonceMaxLengthDefault          = 32
+	CacheMissDefault               = "unauthorized"
+	CacheMissTTLDefault            = 60
+	CacheMissMaxEntriesDefault     = 10000
+	CacheMissConcurrencyDefault    = 8
+	HashCacheMaxEntriesDefault     = 10000
+	HashCacheConcurrencyDefault    = 8
+	MaxCertIDsDefault              = 10
+	MultiCertIDsConcurrencyDefault = 8
+	TLSPortDefault                 = "443"
+	TLSMinVersionDefault           = "1.2"
+	TLSReloadIntervalDefault       = 60
+	ShutdownTimeoutDefault         = 30
+	ShutdownDelayDefault           = 0
+	HealthMinEntriesDefault        = 1
+	SQLTimeoutDefault              = 60
+	FileDBWatchDebounceDefault     = 1
+	CRLDBWatchDebounceDefault      = 1
+	CertDirDBWatchDebounceDefault  = 1
+	HTTPDBTimeoutDefault           = 60
+	HTTPDBRetriesDefault           = 3
+	HTTPDBLimitDefault             = 100
 )
 
 // MissingParameterError is used when configuration paramemter is missing.
@@ -218,10 +487,22 @@ func (y ConfigYAML) VerifyResponderConfig(cfg DyOCSPConfig) (DyOCSPConfig, []err
 
 	// Responder.CA              Required
 	nCfg.CA, errs = markMissRequiredStr(y.Responder.CA, "responder.ca", errs)
+	// The CA is the file name of the snapshot, which must be in cache.snapshot_dir
+	if y.Cache.SnapshotDir != "" && strings.ContainsAny(y.Responder.CA, `/\`) {
+		errs = append(errs, InvalidParameterError{
+			"responder.ca", "ca must not contain path separators if cache.snapshot_dir is set",
+		})
+	}
 	// Responder.Certificate     Required
 	nCfg.Certificate, errs = markMissRequiredStr(y.Responder.Certificate, "responder.responder_certificate", errs)
 	// Responder.Key             Required (file or envionment variable)
 	nCfg.Key = y.Responder.Key
+	// Responder.KeyPassphraseFile  Optional (file or envionment variable)
+	nCfg.KeyPassphraseFile = y.Responder.KeyPassphraseFile
+	// Responder.PKCS11          Optional (exclusive with Responder.Key)
+	if y.Responder.PKCS11 != nil {
+		nCfg, errs = y.verifyPKCS11Config(nCfg, errs)
+	}
 	// Responder.Issuer          Required
 	nCfg.Issuer, errs = markMissRequiredStr(y.Responder.Issuer, "responder.issuer_certificate", errs)
 
@@ -231,6 +512,27 @@ func (y ConfigYAML) VerifyResponderConfig(cfg DyOCSPConfig) (DyOCSPConfig, []err
 	return nCfg, nil
 }
 
+func (y ConfigYAML) verifyPKCS11Config(cfg DyOCSPConfig, errs []error) (DyOCSPConfig, []error) {
+	nCfg := cfg
+	p11 := y.Responder.PKCS11
+
+	if y.Responder.Key != "" || y.Responder.KeyPassphraseFile != "" {
+		errs = append(errs, InvalidParameterError{
+			"responder.pkcs11",
+			"pkcs11 is exclusive with responder_key and responder_key_passphrase_file",
+		})
+	}
+
+	// Responder.PKCS11.Module      Required
+	nCfg.PKCS11Module, errs = markMissRequiredStr(p11.Module, "responder.pkcs11.module", errs)
+	// Responder.PKCS11.TokenLabel  Required
+	nCfg.PKCS11TokenLabel, errs = markMissRequiredStr(p11.TokenLabel, "responder.pkcs11.token_label", errs)
+	// Responder.PKCS11.KeyLabel    Required
+	nCfg.PKCS11KeyLabel, errs = markMissRequiredStr(p11.KeyLabel, "responder.pkcs11.key_label", errs)
+
+	return nCfg, errs
+}
+
 // VerifyCacheConfig verifies .Caches.
 func (y ConfigYAML) VerifyCacheConfig(cfg DyOCSPConfig) (DyOCSPConfig, []error) {
 	nCfg := cfg
@@ -256,6 +558,35 @@ func (y ConfigYAML) VerifyCacheConfig(cfg DyOCSPConfig) (DyOCSPConfig, []error)
 		nCfg.Delay = *y.Cache.Delay
 	}
 
+	if y.Cache.ScanFailure == "" {
+		nCfg.ScanFailure = ScanFailureDefault
+	} else if matched, _ := regexp.MatchString(`\A(?:keep|resign)\z`, y.Cache.ScanFailure); !matched {
+		errs = append(errs, InvalidParameterError{"cache.scan_failure", "[keep|resign]"})
+	} else {
+		nCfg.ScanFailure = y.Cache.ScanFailure
+	}
+
+	switch {
+	case y.Cache.DropThreshold == nil:
+		nCfg.DropThreshold = 0
+	case *y.Cache.DropThreshold < 0 || *y.Cache.DropThreshold > 100:
+		errs = append(errs, InvalidParameterError{"cache.drop_threshold", "the percentage must be between 0 and 100"})
+	default:
+		nCfg.DropThreshold = *y.Cache.DropThreshold
+	}
+
+	// Cache.SnapshotDir  Optional
+	nCfg.SnapshotDir = y.Cache.SnapshotDir
+
+	switch {
+	case y.Cache.Workers == nil:
+		nCfg.Workers = WorkersDefault
+	case *y.Cache.Workers <= 0:
+		errs = append(errs, InvalidParameterError{"cache.workers", "the number of workers must be > 0"})
+	default:
+		nCfg.Workers = *y.Cache.Workers
+	}
+
 	if len(errs) != 0 {
 		return cfg, errs
 	}
@@ -298,7 +629,7 @@ func (y ConfigYAML) VerifyDynamoDBConfig(cfg DyOCSPConfig) (DyOCSPConfig, []erro
 		nCfg.DynamoDBRetryMaxAttempts = *y.DB.DynamoDB.RetryMaxAttempts
 	}
 
-	// DB.DynamoDB.RetryMaxAttempts  Optional (default: 0)
+	// DB.DynamoDB.Timeout           Optional (default: 60)
 	switch {
 	case y.DB.DynamoDB.Timeout == nil:
 		nCfg.DynamoDBTimeout = DynamoDBTimeoutDefault
@@ -311,6 +642,43 @@ func (y ConfigYAML) VerifyDynamoDBConfig(cfg DyOCSPConfig) (DyOCSPConfig, []erro
 		nCfg.DynamoDBTimeout = *y.DB.DynamoDB.Timeout
 	}
 
+	// DB.DynamoDB.Segments          Optional (default: 1)
+	switch {
+	case y.DB.DynamoDB.Segments == nil:
+		nCfg.DynamoDBSegments = DynamoDBSegmentsDefault
+	case *y.DB.DynamoDB.Segments <= 0 || *y.DB.DynamoDB.Segments > DynamoDBSegmentsMax:
+		errs = append(errs, InvalidParameterError{
+			"db.dynamodb.segments",
+			"the number of segments must be 1 to 1000000",
+		})
+	case *y.DB.DynamoDB.Segments > 1 && !y.DB.DynamoDB.FullScan:
+		errs = append(errs, InvalidParameterError{
+			"db.dynamodb.segments",
+			"segments > 1 scans every item of the index, full_scan must be true",
+		})
+	default:
+		nCfg.DynamoDBSegments = *y.DB.DynamoDB.Segments
+	}
+
+	// DB.DynamoDB.FullScan          Optional (default: false)
+	nCfg.DynamoDBFullScan = y.DB.DynamoDB.FullScan
+
+	// DB.DynamoDB.Streams           Optional (default: disabled)
+	if y.DB.DynamoDB.Streams != nil {
+		nCfg.DynamoDBStreams = true
+		switch {
+		case y.DB.DynamoDB.Streams.PollInterval == nil:
+			nCfg.DynamoDBStreamsPoll = DynamoDBStreamsPollDefault
+		case *y.DB.DynamoDB.Streams.PollInterval <= 0:
+			errs = append(errs, InvalidParameterError{
+				"db.dynamodb.streams.poll_interval",
+				"the number of seconds must be > 0",
+			})
+		default:
+			nCfg.DynamoDBStreamsPoll = *y.DB.DynamoDB.Streams.PollInterval
+		}
+	}
+
 	if len(errs) != 0 {
 		return cfg, errs
 	}
@@ -325,6 +693,298 @@ func (y ConfigYAML) VerifyFileDBConfig(cfg DyOCSPConfig) (DyOCSPConfig, []error)
 	// .DB.FileDB.File
 	nCfg.FileDBFile, errs = markMissRequiredStr(y.DB.FileDB.File, "db.file.file", errs)
 
+	// .DB.FileDB.Watch   Optional (default: disabled)
+	if y.DB.FileDB.Watch != nil {
+		nCfg.FileDBWatch = true
+		switch {
+		case y.DB.FileDB.Watch.Debounce == nil:
+			nCfg.FileDBWatchDebounce = FileDBWatchDebounceDefault
+		case *y.DB.FileDB.Watch.Debounce <= 0:
+			errs = append(errs, InvalidParameterError{"db.file.watch.debounce", "the number of seconds must be > 0"})
+		default:
+			nCfg.FileDBWatchDebounce = *y.DB.FileDB.Watch.Debounce
+		}
+	}
+
+	if len(errs) != 0 {
+		return cfg, errs
+	}
+	return nCfg, nil
+}
+
+// sqlIdentifierRegexp matches the names of the table and the columns, which
+// can be qualified with the schema.
+var sqlIdentifierRegexp = regexp.MustCompile(`\A[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?\z`)
+
+// VerifySQLDBConfig verifies .DB.SQL.
+func (y ConfigYAML) VerifySQLDBConfig(cfg DyOCSPConfig) (DyOCSPConfig, []error) {
+	nCfg := cfg
+	errs := make([]error, 0, errsCap8)
+
+	// DB.SQL.Driver     Required
+	switch y.DB.SQL.Driver {
+	case "":
+		errs = append(errs, MissingParameterError{"db.sql.driver"})
+	case "postgres", "sqlite3":
+		nCfg.SQLDriver = y.DB.SQL.Driver
+	default:
+		errs = append(errs, InvalidParameterError{"db.sql.driver", "[postgres|sqlite3]"})
+	}
+
+	// DB.SQL.DSN        Optional (DSN or envionment variable)
+	nCfg.SQLDSN = y.DB.SQL.DSN
+
+	// DB.SQL.Table      Required (or query)
+	// DB.SQL.Query      Required (or table)
+	switch {
+	case y.DB.SQL.Table == "" && y.DB.SQL.Query == "":
+		errs = append(errs, MissingParameterError{"db.sql.table"})
+	case y.DB.SQL.Table != "" && y.DB.SQL.Query != "":
+		errs = append(errs, InvalidParameterError{"db.sql.query", "query is exclusive with table"})
+	case y.DB.SQL.Query != "":
+		nCfg.SQLQuery = y.DB.SQL.Query
+	case !sqlIdentifierRegexp.MatchString(y.DB.SQL.Table):
+		errs = append(errs, InvalidParameterError{"db.sql.table", "must be the valid identifier"})
+	default:
+		nCfg.SQLTable = y.DB.SQL.Table
+	}
+
+	// DB.SQL.Columns.*  Optional (default: same as the attributes)
+	columns := []struct {
+		param string
+		value string
+		def   string
+		dest  *string
+	}{
+		{"db.sql.columns.ca", y.DB.SQL.Columns.CA, "ca", &nCfg.SQLColumns.CA},
+		{"db.sql.columns.serial", y.DB.SQL.Columns.Serial, "serial", &nCfg.SQLColumns.Serial},
+		{"db.sql.columns.rev_type", y.DB.SQL.Columns.RevType, "rev_type", &nCfg.SQLColumns.RevType},
+		{"db.sql.columns.exp_date", y.DB.SQL.Columns.ExpDate, "exp_date", &nCfg.SQLColumns.ExpDate},
+		{"db.sql.columns.rev_date", y.DB.SQL.Columns.RevDate, "rev_date", &nCfg.SQLColumns.RevDate},
+		{"db.sql.columns.crl_reason", y.DB.SQL.Columns.CRLReason, "crl_reason", &nCfg.SQLColumns.CRLReason},
+	}
+	for _, col := range columns {
+		switch {
+		case col.value == "":
+			*col.dest = col.def
+		case !sqlIdentifierRegexp.MatchString(col.value):
+			errs = append(errs, InvalidParameterError{col.param, "must be the valid identifier"})
+		default:
+			*col.dest = col.value
+		}
+	}
+
+	// DB.SQL.Timeout    Optional (default: 60)
+	switch {
+	case y.DB.SQL.Timeout == nil:
+		nCfg.SQLTimeout = SQLTimeoutDefault
+	case *y.DB.SQL.Timeout <= 0:
+		errs = append(errs, InvalidParameterError{
+			"db.sql.timeout",
+			"the number of seconds for timeout must be > 0",
+		})
+	default:
+		nCfg.SQLTimeout = *y.DB.SQL.Timeout
+	}
+
+	if len(errs) != 0 {
+		return cfg, errs
+	}
+	return nCfg, nil
+}
+
+// VerifyCRLDBConfig verifies .DB.CRL.
+func (y ConfigYAML) VerifyCRLDBConfig(cfg DyOCSPConfig) (DyOCSPConfig, []error) {
+	nCfg := cfg
+	errs := make([]error, 0, errsCap2)
+
+	// .DB.CRL.Files     Required
+	switch {
+	case len(y.DB.CRL.Files) == 0:
+		errs = append(errs, MissingParameterError{"db.crl.files"})
+	case slices.Contains(y.DB.CRL.Files, ""):
+		errs = append(errs, InvalidParameterError{"db.crl.files", "must not contain an empty path"})
+	default:
+		nCfg.CRLDBFiles = y.DB.CRL.Files
+	}
+
+	// .DB.CRL.CertDir   Optional
+	nCfg.CRLDBCertDir = y.DB.CRL.CertDir
+
+	// .DB.CRL.Watch     Optional (default: disabled)
+	if y.DB.CRL.Watch != nil {
+		nCfg.CRLDBWatch = true
+		switch {
+		case y.DB.CRL.Watch.Debounce == nil:
+			nCfg.CRLDBWatchDebounce = CRLDBWatchDebounceDefault
+		case *y.DB.CRL.Watch.Debounce <= 0:
+			errs = append(errs, InvalidParameterError{"db.crl.watch.debounce", "the number of seconds must be > 0"})
+		default:
+			nCfg.CRLDBWatchDebounce = *y.DB.CRL.Watch.Debounce
+		}
+	}
+
+	if len(errs) != 0 {
+		return cfg, errs
+	}
+	return nCfg, nil
+}
+
+// VerifyCertDirDBConfig verifies .DB.CertDir.
+func (y ConfigYAML) VerifyCertDirDBConfig(cfg DyOCSPConfig) (DyOCSPConfig, []error) {
+	nCfg := cfg
+	errs := make([]error, 0, errsCap2)
+
+	// .DB.CertDir.Dir          Required
+	nCfg.CertDirDBDir, errs = markMissRequiredStr(y.DB.CertDir.Dir, "db.certificates.dir", errs)
+
+	// .DB.CertDir.Revocations  Optional
+	nCfg.CertDirDBRevocations = y.DB.CertDir.Revocations
+
+	// .DB.CertDir.Watch        Optional (default: disabled)
+	if y.DB.CertDir.Watch != nil {
+		nCfg.CertDirDBWatch = true
+		switch {
+		case y.DB.CertDir.Watch.Debounce == nil:
+			nCfg.CertDirDBWatchDebounce = CertDirDBWatchDebounceDefault
+		case *y.DB.CertDir.Watch.Debounce <= 0:
+			errs = append(errs, InvalidParameterError{
+				"db.certificates.watch.debounce", "the number of seconds must be > 0",
+			})
+		default:
+			nCfg.CertDirDBWatchDebounce = *y.DB.CertDir.Watch.Debounce
+		}
+	}
+
+	if len(errs) != 0 {
+		return cfg, errs
+	}
+	return nCfg, nil
+}
+
+// jsonPathRegexp matches the JSON paths in the dot notation, which may start
+// with "$".
+var jsonPathRegexp = regexp.MustCompile(`\A(\$|(\$\.)?[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*)\z`)
+
+// verifyJSONPath verifies the JSON path of the parameter. If the path is empty,
+// the default path is set.
+func verifyJSONPath(param string, path string, def string, dest *string, errs []error) []error {
+	switch {
+	case path == "":
+		*dest = def
+	case !jsonPathRegexp.MatchString(path):
+		errs = append(errs, InvalidParameterError{param, "must be the JSON path in the dot notation"})
+	default:
+		*dest = path
+	}
+
+	return errs
+}
+
+// VerifyHTTPDBConfig verifies .DB.HTTP.
+func (y ConfigYAML) VerifyHTTPDBConfig(cfg DyOCSPConfig) (DyOCSPConfig, []error) {
+	nCfg := cfg
+	errs := make([]error, 0, errsCap8)
+
+	// .DB.HTTP.URL                      Required
+	nCfg.HTTPDBURL, errs = markMissRequiredStr(y.DB.HTTP.URL, "db.http_json.url", errs)
+	if y.DB.HTTP.URL != "" {
+		if matched, _ := regexp.MatchString(`\Ahttps?://`, y.DB.HTTP.URL); !matched {
+			errs = append(errs, InvalidParameterError{"db.http_json.url", "url must start from 'http://' or 'https://'"})
+		}
+	}
+
+	// .DB.HTTP.CAFile                   Optional (default: system CAs)
+	nCfg.HTTPDBCAFile = y.DB.HTTP.CAFile
+
+	// .DB.HTTP.Timeout                  Optional (default: 60)
+	switch {
+	case y.DB.HTTP.Timeout == nil:
+		nCfg.HTTPDBTimeout = HTTPDBTimeoutDefault
+	case *y.DB.HTTP.Timeout <= 0:
+		errs = append(errs, InvalidParameterError{"db.http_json.timeout", "the number of seconds for timeout must be > 0"})
+	default:
+		nCfg.HTTPDBTimeout = *y.DB.HTTP.Timeout
+	}
+
+	// .DB.HTTP.Retries                  Optional (default: 3)
+	switch {
+	case y.DB.HTTP.Retries == nil:
+		nCfg.HTTPDBRetries = HTTPDBRetriesDefault
+	case *y.DB.HTTP.Retries < 0:
+		errs = append(errs, InvalidParameterError{"db.http_json.retries", "the number of retries must be >= 0"})
+	default:
+		nCfg.HTTPDBRetries = *y.DB.HTTP.Retries
+	}
+
+	// .DB.HTTP.Pagination               Optional (default: disabled)
+	nCfg.HTTPDBPagination = "none"
+	if pagination := y.DB.HTTP.Pagination; pagination != nil {
+		switch pagination.Type {
+		case "":
+			errs = append(errs, MissingParameterError{"db.http_json.pagination.type"})
+		case "cursor":
+			nCfg.HTTPDBPagination = pagination.Type
+			nCfg.HTTPDBCursorParam = pagination.CursorParam
+			if nCfg.HTTPDBCursorParam == "" {
+				nCfg.HTTPDBCursorParam = "cursor"
+			}
+			errs = verifyJSONPath("db.http_json.pagination.next_cursor",
+				pagination.NextCursor, "next_cursor", &nCfg.HTTPDBNextCursor, errs)
+		case "offset":
+			nCfg.HTTPDBPagination = pagination.Type
+			nCfg.HTTPDBOffsetParam = pagination.OffsetParam
+			if nCfg.HTTPDBOffsetParam == "" {
+				nCfg.HTTPDBOffsetParam = "offset"
+			}
+			nCfg.HTTPDBLimitParam = pagination.LimitParam
+			if nCfg.HTTPDBLimitParam == "" {
+				nCfg.HTTPDBLimitParam = "limit"
+			}
+			switch {
+			case pagination.Limit == nil:
+				nCfg.HTTPDBLimit = HTTPDBLimitDefault
+			case *pagination.Limit <= 0:
+				errs = append(errs, InvalidParameterError{
+					"db.http_json.pagination.limit", "the number of items must be > 0",
+				})
+			default:
+				nCfg.HTTPDBLimit = *pagination.Limit
+			}
+		default:
+			errs = append(errs, InvalidParameterError{"db.http_json.pagination.type", "[cursor|offset]"})
+		}
+	}
+
+	// .DB.HTTP.Fields.*                 Optional (default: same as the attributes)
+	fields := []struct {
+		param string
+		value string
+		def   string
+		dest  *string
+	}{
+		{"db.http_json.fields.items", y.DB.HTTP.Fields.Items, "items", &nCfg.HTTPDBFields.Items},
+		{"db.http_json.fields.serial", y.DB.HTTP.Fields.Serial, "serial", &nCfg.HTTPDBFields.Serial},
+		{"db.http_json.fields.rev_type", y.DB.HTTP.Fields.RevType, "rev_type", &nCfg.HTTPDBFields.RevType},
+		{"db.http_json.fields.exp_date", y.DB.HTTP.Fields.ExpDate, "exp_date", &nCfg.HTTPDBFields.ExpDate},
+		{"db.http_json.fields.rev_date", y.DB.HTTP.Fields.RevDate, "rev_date", &nCfg.HTTPDBFields.RevDate},
+		{"db.http_json.fields.crl_reason", y.DB.HTTP.Fields.CRLReason, "crl_reason", &nCfg.HTTPDBFields.CRLReason},
+		{"db.http_json.fields.subject", y.DB.HTTP.Fields.Subject, "subject", &nCfg.HTTPDBFields.Subject},
+	}
+	for _, field := range fields {
+		errs = verifyJSONPath(field.param, field.value, field.def, field.dest, errs)
+	}
+
+	// .DB.HTTP.Fields.CA                Optional (default: ca, empty: the configured CA)
+	switch {
+	case y.DB.HTTP.Fields.CA == nil:
+		nCfg.HTTPDBFields.CA = "ca"
+	case *y.DB.HTTP.Fields.CA == "":
+		nCfg.HTTPDBFields.CA = ""
+	default:
+		errs = verifyJSONPath("db.http_json.fields.ca", *y.DB.HTTP.Fields.CA, "ca", &nCfg.HTTPDBFields.CA, errs)
+	}
+
 	if len(errs) != 0 {
 		return cfg, errs
 	}
@@ -352,6 +1012,34 @@ func (y ConfigYAML) VerifyDBConfig(cfg DyOCSPConfig) (DyOCSPConfig, []error) {
 		dupN++
 	}
 
+	// .DB.SQL
+	if y.DB.SQL != nil {
+		nCfg, errs = y.VerifySQLDBConfig(nCfg)
+		nCfg.DBType = SQLDBType
+		dupN++
+	}
+
+	// .DB.CRL
+	if y.DB.CRL != nil {
+		nCfg, errs = y.VerifyCRLDBConfig(nCfg)
+		nCfg.DBType = CRLDBType
+		dupN++
+	}
+
+	// .DB.CertDir
+	if y.DB.CertDir != nil {
+		nCfg, errs = y.VerifyCertDirDBConfig(nCfg)
+		nCfg.DBType = CertDirDBType
+		dupN++
+	}
+
+	// .DB.HTTP
+	if y.DB.HTTP != nil {
+		nCfg, errs = y.VerifyHTTPDBConfig(nCfg)
+		nCfg.DBType = HTTPDBType
+		dupN++
+	}
+
 	if dupN == 0 {
 		errs = []error{MissingParameterError{"db.<db-type>"}}
 		return cfg, errs
@@ -368,6 +1056,72 @@ func (y ConfigYAML) VerifyDBConfig(cfg DyOCSPConfig) (DyOCSPConfig, []error) {
 	return nCfg, nil
 }
 
+// VerifyRespondersConfig verifies .Responders. Each item of .Responders is
+// verified as .Responder and .DB, and the result is set to DyOCSPConfig.Responders.
+func (y ConfigYAML) VerifyRespondersConfig(cfg DyOCSPConfig) (DyOCSPConfig, []error) {
+	nCfg := cfg
+	errs := make([]error, 0, errsCap8)
+
+	if len(y.Responders) == 0 {
+		return cfg, nil
+	}
+
+	if y.Responder != (ResponderYAML{}) || y.DB.FileDB != nil || y.DB.DynamoDB != nil || y.DB.SQL != nil ||
+		y.DB.CRL != nil || y.DB.CertDir != nil || y.DB.HTTP != nil {
+		errs = append(errs, InvalidParameterError{
+			"responders", "responders is exclusive with responder and db",
+		})
+		return cfg, errs
+	}
+
+	cas := make(map[string]struct{}, len(y.Responders))
+	nCfg.Responders = make([]DyOCSPConfig, 0, len(y.Responders))
+
+	for idx := range y.Responders {
+		item := y
+		item.Responder = y.Responders[idx].ResponderYAML
+		item.DB = y.Responders[idx].DB
+
+		rCfg := cfg
+		rCfg.Responders = nil
+
+		rCfg, rErrs := item.VerifyResponderConfig(rCfg)
+		for _, err := range rErrs {
+			errs = append(errs, fmt.Errorf("responders[%d]: %w", idx, err))
+		}
+
+		rCfg, dbErrs := item.VerifyDBConfig(rCfg)
+		for _, err := range dbErrs {
+			errs = append(errs, fmt.Errorf("responders[%d]: %w", idx, err))
+		}
+
+		ca := item.Responder.CA
+		if _, ok := cas[ca]; ok && ca != "" {
+			errs = append(errs, InvalidParameterError{
+				fmt.Sprintf("responders[%d].ca", idx), "ca must be unique",
+			})
+		}
+		cas[ca] = struct{}{}
+
+		nCfg.Responders = append(nCfg.Responders, rCfg)
+	}
+
+	if len(errs) != 0 {
+		return cfg, errs
+	}
+	return nCfg, nil
+}
+
+// ResponderConfigs returns the configurations of each responder.
+// In multi-CA mode, it returns DyOCSPConfig.Responders. Otherwise, it returns
+// a slice that contains only this configuration.
+func (c DyOCSPConfig) ResponderConfigs() []DyOCSPConfig {
+	if len(c.Responders) == 0 {
+		return []DyOCSPConfig{c}
+	}
+	return c.Responders
+}
+
 func specOrDefInt(i *int, def int) int {
 	if i == nil {
 		return def
@@ -418,6 +1172,280 @@ func (y ConfigYAML) VerifyHTTPConfig(cfg DyOCSPConfig) (DyOCSPConfig, []error) {
 		nCfg.CacheControlMaxAge = *y.HTTP.CacheControlMaxAge
 	}
 
+	// HTTP.ShutdownTimeout      Optional
+	switch {
+	case y.HTTP.ShutdownTimeout == nil:
+		nCfg.ShutdownTimeout = ShutdownTimeoutDefault
+	case *y.HTTP.ShutdownTimeout <= 0:
+		errs = append(errs, InvalidParameterError{"http.shutdown_timeout", "the number of seconds must be > 0"})
+	default:
+		nCfg.ShutdownTimeout = *y.HTTP.ShutdownTimeout
+	}
+
+	// HTTP.ShutdownDelay        Optional
+	switch {
+	case y.HTTP.ShutdownDelay == nil:
+		nCfg.ShutdownDelay = ShutdownDelayDefault
+	case *y.HTTP.ShutdownDelay < 0:
+		errs = append(errs, InvalidParameterError{"http.shutdown_delay", "the number of seconds must be >= 0"})
+	default:
+		nCfg.ShutdownDelay = *y.HTTP.ShutdownDelay
+	}
+
+	// HTTP.Nonce                Optional (default: disabled)
+	if y.HTTP.Nonce != nil {
+		nCfg.NonceSigning = true
+		switch {
+		case y.HTTP.Nonce.MaxConcurrency == nil:
+			nCfg.NonceMaxConcurrency = NonceMaxConcurrencyDefault
+		case *y.HTTP.Nonce.MaxConcurrency <= 0:
+			errs = append(errs, InvalidParameterError{
+				"http.nonce.max_concurrency",
+				"the number of concurrent signings must be > 0",
+			})
+		default:
+			nCfg.NonceMaxConcurrency = *y.HTTP.Nonce.MaxConcurrency
+		}
+
+		switch {
+		case y.HTTP.Nonce.MaxLength == nil:
+			nCfg.NonceMaxLength = NonceMaxLengthDefault
+		case *y.HTTP.Nonce.MaxLength < 1 || *y.HTTP.Nonce.MaxLength > NonceMaxLengthDefault:
+			errs = append(errs, InvalidParameterError{
+				"http.nonce.max_length",
+				"the number of octets must be between 1 and 32",
+			})
+		default:
+			nCfg.NonceMaxLength = *y.HTTP.Nonce.MaxLength
+		}
+	}
+
+	// HTTP.CacheMiss.Response   Optional
+	if y.HTTP.CacheMiss.Response == "" {
+		nCfg.CacheMiss = CacheMissDefault
+	} else if matched, _ := regexp.MatchString(
+		`\A(?:unauthorized|unknown|revoked)\z`, y.HTTP.CacheMiss.Response,
+	); !matched {
+		errs = append(errs, InvalidParameterError{"http.cache_miss.response", "[unauthorized|unknown|revoked]"})
+	} else {
+		nCfg.CacheMiss = y.HTTP.CacheMiss.Response
+	}
+
+	// HTTP.CacheMiss.TTL        Optional
+	switch {
+	case y.HTTP.CacheMiss.TTL == nil:
+		nCfg.CacheMissTTL = CacheMissTTLDefault
+	case *y.HTTP.CacheMiss.TTL <= 0:
+		errs = append(errs, InvalidParameterError{"http.cache_miss.ttl", "the number of seconds must be > 0"})
+	default:
+		nCfg.CacheMissTTL = *y.HTTP.CacheMiss.TTL
+	}
+
+	// HTTP.CacheMiss.MaxEntries Optional
+	switch {
+	case y.HTTP.CacheMiss.MaxEntries == nil:
+		nCfg.CacheMissMaxEntries = CacheMissMaxEntriesDefault
+	case *y.HTTP.CacheMiss.MaxEntries <= 0:
+		errs = append(errs, InvalidParameterError{
+			"http.cache_miss.max_entries",
+			"the number of entries must be > 0",
+		})
+	default:
+		nCfg.CacheMissMaxEntries = *y.HTTP.CacheMiss.MaxEntries
+	}
+
+	// HTTP.CacheMiss.MaxConcurrency Optional
+	switch {
+	case y.HTTP.CacheMiss.MaxConcurrency == nil:
+		nCfg.CacheMissConcurrency = CacheMissConcurrencyDefault
+	case *y.HTTP.CacheMiss.MaxConcurrency <= 0:
+		errs = append(errs, InvalidParameterError{
+			"http.cache_miss.max_concurrency",
+			"the number of concurrent signings must be > 0",
+		})
+	default:
+		nCfg.CacheMissConcurrency = *y.HTTP.CacheMiss.MaxConcurrency
+	}
+
+	// HTTP.HashCache.MaxEntries Optional
+	switch {
+	case y.HTTP.HashCache.MaxEntries == nil:
+		nCfg.HashCacheMaxEntries = HashCacheMaxEntriesDefault
+	case *y.HTTP.HashCache.MaxEntries <= 0:
+		errs = append(errs, InvalidParameterError{
+			"http.hash_cache.max_entries",
+			"the number of entries must be > 0",
+		})
+	default:
+		nCfg.HashCacheMaxEntries = *y.HTTP.HashCache.MaxEntries
+	}
+
+	// HTTP.HashCache.MaxConcurrency Optional
+	switch {
+	case y.HTTP.HashCache.MaxConcurrency == nil:
+		nCfg.HashCacheConcurrency = HashCacheConcurrencyDefault
+	case *y.HTTP.HashCache.MaxConcurrency <= 0:
+		errs = append(errs, InvalidParameterError{
+			"http.hash_cache.max_concurrency",
+			"the number of concurrent signings must be > 0",
+		})
+	default:
+		nCfg.HashCacheConcurrency = *y.HTTP.HashCache.MaxConcurrency
+	}
+
+	// HTTP.MultiCertIDs         Optional (default: disabled)
+	if y.HTTP.MultiCertIDs != nil {
+		nCfg.MultiCertIDs = true
+		switch {
+		case y.HTTP.MultiCertIDs.MaxCertIDs == nil:
+			nCfg.MaxCertIDs = MaxCertIDsDefault
+		case *y.HTTP.MultiCertIDs.MaxCertIDs <= 1:
+			errs = append(errs, InvalidParameterError{
+				"http.multiple_cert_ids.max_cert_ids",
+				"the number of CertIDs must be > 1",
+			})
+		default:
+			nCfg.MaxCertIDs = *y.HTTP.MultiCertIDs.MaxCertIDs
+		}
+
+		switch {
+		case y.HTTP.MultiCertIDs.MaxConcurrency == nil:
+			nCfg.MultiCertIDsConcurrency = MultiCertIDsConcurrencyDefault
+		case *y.HTTP.MultiCertIDs.MaxConcurrency <= 0:
+			errs = append(errs, InvalidParameterError{
+				"http.multiple_cert_ids.max_concurrency",
+				"the number of concurrent signings must be > 0",
+			})
+		default:
+			nCfg.MultiCertIDsConcurrency = *y.HTTP.MultiCertIDs.MaxConcurrency
+		}
+	}
+
+	// HTTP.HTTP2                Optional
+	nCfg.HTTP2 = y.HTTP.HTTP2
+
+	// HTTP.TLS                  Optional (default: disabled)
+	if y.HTTP.TLS != nil {
+		var tlsErrs []error
+		nCfg, tlsErrs = y.HTTP.TLS.verify(nCfg)
+		errs = append(errs, tlsErrs...)
+	}
+
+	if len(errs) != 0 {
+		return cfg, errs
+	}
+	return nCfg, nil
+}
+
+func (t TLSYAML) verify(cfg DyOCSPConfig) (DyOCSPConfig, []error) {
+	nCfg := cfg
+	errs := make([]error, 0, errsCap4)
+
+	nCfg.TLS = true
+
+	// HTTP.TLS.Port             Optional
+	if t.Port == "" {
+		nCfg.TLSPort = TLSPortDefault
+	} else if matched, _ := regexp.MatchString(`\A[1-9][0-9]*\z`, t.Port); !matched {
+		errs = append(errs, InvalidParameterError{"http.tls.port", "must be the valid port number"})
+	} else {
+		nCfg.TLSPort = t.Port
+	}
+
+	// HTTP.TLS.Certificate      Required
+	nCfg.TLSCertificate, errs = markMissRequiredStr(t.Certificate, "http.tls.certificate", errs)
+	// HTTP.TLS.Key              Required
+	nCfg.TLSKey, errs = markMissRequiredStr(t.Key, "http.tls.key", errs)
+
+	// HTTP.TLS.MinVersion       Optional
+	if t.MinVersion == "" {
+		nCfg.TLSMinVersion = TLSMinVersionDefault
+	} else if matched, _ := regexp.MatchString(`\A(?:1\.2|1\.3)\z`, t.MinVersion); !matched {
+		errs = append(errs, InvalidParameterError{"http.tls.min_version", "[1.2|1.3]"})
+	} else {
+		nCfg.TLSMinVersion = t.MinVersion
+	}
+
+	// HTTP.TLS.ClientCA         Optional
+	nCfg.TLSClientCA = t.ClientCA
+
+	// HTTP.TLS.ReloadInterval   Optional
+	switch {
+	case t.ReloadInterval == nil:
+		nCfg.TLSReloadInterval = TLSReloadIntervalDefault
+	case *t.ReloadInterval <= 0:
+		errs = append(errs, InvalidParameterError{"http.tls.reload_interval", "the number of seconds must be > 0"})
+	default:
+		nCfg.TLSReloadInterval = *t.ReloadInterval
+	}
+
+	// HTTP.TLS.DisablePlain     Optional
+	nCfg.TLSDisablePlain = t.DisablePlain
+
+	if len(errs) != 0 {
+		return cfg, errs
+	}
+	return nCfg, nil
+}
+
+// VerifyAdminConfig verifies .Admin.
+func (y ConfigYAML) VerifyAdminConfig(cfg DyOCSPConfig) (DyOCSPConfig, []error) {
+	nCfg := cfg
+	errs := make([]error, 0, errsCap4)
+
+	// Admin.Port                Optional (default: served on the http listeners)
+	if y.Admin.Port != "" {
+		if matched, _ := regexp.MatchString(`\A[1-9][0-9]*\z`, y.Admin.Port); !matched {
+			errs = append(errs, InvalidParameterError{"admin.port", "must be the valid port number"})
+		} else {
+			nCfg.AdminPort = y.Admin.Port
+		}
+	}
+
+	// Admin.Metrics             Optional
+	nCfg.Metrics = y.Admin.Metrics
+	// The metrics are not served on the http listeners
+	if nCfg.Metrics && nCfg.AdminPort == "" {
+		errs = append(errs, InvalidParameterError{"admin.metrics", "admin.port must be set to serve the metrics"})
+	}
+
+	// Admin.Health.MaxUpdateAge Optional (default: twice cache.interval)
+	switch {
+	case y.Admin.Health.MaxUpdateAge == nil:
+		nCfg.HealthMaxUpdateAge = nCfg.Interval * 2
+	case *y.Admin.Health.MaxUpdateAge <= 0:
+		errs = append(errs, InvalidParameterError{
+			"admin.health.max_update_age",
+			"the number of seconds must be > 0",
+		})
+	default:
+		nCfg.HealthMaxUpdateAge = *y.Admin.Health.MaxUpdateAge
+	}
+
+	// Admin.Health.MinEntries   Optional
+	switch {
+	case y.Admin.Health.MinEntries == nil:
+		nCfg.HealthMinEntries = HealthMinEntriesDefault
+	case *y.Admin.Health.MinEntries < 0:
+		errs = append(errs, InvalidParameterError{
+			"admin.health.min_entries",
+			"the number of entries must be >= 0",
+		})
+	default:
+		nCfg.HealthMinEntries = *y.Admin.Health.MinEntries
+	}
+
+	// Admin.API                 Optional (default: disabled)
+	if y.Admin.API != nil {
+		nCfg.AdminAPI = true
+		// The admin API is not served on the http listeners
+		if nCfg.AdminPort == "" {
+			errs = append(errs, InvalidParameterError{"admin.api", "admin.port must be set to serve the admin API"})
+		}
+		// Admin.API.TokenFile       Optional (file or envionment variable)
+		nCfg.AdminAPITokenFile = y.Admin.API.TokenFile
+	}
+
 	if len(errs) != 0 {
 		return cfg, errs
 	}
@@ -457,10 +1485,13 @@ func (y ConfigYAML) Verify(cfg DyOCSPConfig) (DyOCSPConfig, []error) {
 		errs = append(errs, logErrs...)
 	}
 
-	// .Responder
-	nCfg, responderErrs := y.VerifyResponderConfig(nCfg)
-	if len(responderErrs) != 0 {
-		errs = append(errs, responderErrs...)
+	// .Responder (verified with .Responders if multi-CA mode)
+	if len(y.Responders) == 0 {
+		var responderErrs []error
+		nCfg, responderErrs = y.VerifyResponderConfig(nCfg)
+		if len(responderErrs) != 0 {
+			errs = append(errs, responderErrs...)
+		}
 	}
 
 	// .Cache
@@ -469,10 +1500,13 @@ func (y ConfigYAML) Verify(cfg DyOCSPConfig) (DyOCSPConfig, []error) {
 		errs = append(errs, cacheErrs...)
 	}
 
-	// .DB
-	nCfg, dbErrs := y.VerifyDBConfig(nCfg)
-	if len(dbErrs) != 0 {
-		errs = append(errs, dbErrs...)
+	// .DB (verified with .Responders if multi-CA mode)
+	if len(y.Responders) == 0 {
+		var dbErrs []error
+		nCfg, dbErrs = y.VerifyDBConfig(nCfg)
+		if len(dbErrs) != 0 {
+			errs = append(errs, dbErrs...)
+		}
 	}
 
 	// .HTTP
@@ -481,6 +1515,19 @@ func (y ConfigYAML) Verify(cfg DyOCSPConfig) (DyOCSPConfig, []error) {
 		errs = append(errs, httpErrs...)
 	}
 
+	// .Admin
+	nCfg, adminErrs := y.VerifyAdminConfig(nCfg)
+	if len(adminErrs) != 0 {
+		errs = append(errs, adminErrs...)
+	}
+
+	// .Responders  Optional (multi-CA mode)
+	// This must be verified at the last to inherit the global parameters.
+	nCfg, respondersErrs := y.VerifyRespondersConfig(nCfg)
+	if len(respondersErrs) != 0 {
+		errs = append(errs, respondersErrs...)
+	}
+
 	if len(errs) != 0 {
 		return cfg, errs
 	}
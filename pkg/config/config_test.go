@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"reflect"
 	"testing"
@@ -53,10 +54,17 @@ func convConfigYAMLToDyOCSPConfig(cfgYml ConfigYAML) DyOCSPConfig {
 	cfg.CA = cfgYml.Responder.CA
 	cfg.Certificate = cfgYml.Responder.Certificate
 	cfg.Key = cfgYml.Responder.Key
+	cfg.KeyPassphraseFile = cfgYml.Responder.KeyPassphraseFile
 	cfg.Issuer = cfgYml.Responder.Issuer
 
 	cfg.Interval = *cfgYml.Cache.Interval
 	cfg.Delay = *cfgYml.Cache.Delay
+	cfg.ScanFailure = cfgYml.Cache.ScanFailure
+	cfg.SnapshotDir = cfgYml.Cache.SnapshotDir
+	cfg.Workers = *cfgYml.Cache.Workers
+	if cfgYml.Cache.DropThreshold != nil {
+		cfg.DropThreshold = *cfgYml.Cache.DropThreshold
+	}
 
 	if cfgYml.DB.DynamoDB != nil {
 		cfg.DynamoDBRegion = cfgYml.DB.DynamoDB.Region
@@ -65,6 +73,12 @@ func convConfigYAMLToDyOCSPConfig(cfgYml ConfigYAML) DyOCSPConfig {
 		cfg.DynamoDBEndpoint = cfgYml.DB.DynamoDB.Endpoint
 		cfg.DynamoDBRetryMaxAttempts = *cfgYml.DB.DynamoDB.RetryMaxAttempts
 		cfg.DynamoDBTimeout = *cfgYml.DB.DynamoDB.Timeout
+		cfg.DynamoDBSegments = *cfgYml.DB.DynamoDB.Segments
+		cfg.DynamoDBFullScan = cfgYml.DB.DynamoDB.FullScan
+		if cfgYml.DB.DynamoDB.Streams != nil {
+			cfg.DynamoDBStreams = true
+			cfg.DynamoDBStreamsPoll = *cfgYml.DB.DynamoDB.Streams.PollInterval
+		}
 		cfg.DBType = DynamoDBType
 	}
 
@@ -85,6 +99,43 @@ func convConfigYAMLToDyOCSPConfig(cfgYml ConfigYAML) DyOCSPConfig {
 	} else {
 		cfg.CacheControlMaxAge = *cfgYml.HTTP.CacheControlMaxAge
 	}
+	cfg.ShutdownTimeout = *cfgYml.HTTP.ShutdownTimeout
+	cfg.ShutdownDelay = *cfgYml.HTTP.ShutdownDelay
+	if cfgYml.HTTP.Nonce != nil {
+		cfg.NonceSigning = true
+		cfg.NonceMaxConcurrency = *cfgYml.HTTP.Nonce.MaxConcurrency
+		cfg.NonceMaxLength = *cfgYml.HTTP.Nonce.MaxLength
+	}
+	cfg.CacheMiss = cfgYml.HTTP.CacheMiss.Response
+	cfg.CacheMissTTL = *cfgYml.HTTP.CacheMiss.TTL
+	cfg.CacheMissMaxEntries = *cfgYml.HTTP.CacheMiss.MaxEntries
+	cfg.CacheMissConcurrency = *cfgYml.HTTP.CacheMiss.MaxConcurrency
+	cfg.HashCacheMaxEntries = *cfgYml.HTTP.HashCache.MaxEntries
+	cfg.HashCacheConcurrency = *cfgYml.HTTP.HashCache.MaxConcurrency
+	if cfgYml.HTTP.MultiCertIDs != nil {
+		cfg.MultiCertIDs = true
+		cfg.MaxCertIDs = *cfgYml.HTTP.MultiCertIDs.MaxCertIDs
+		cfg.MultiCertIDsConcurrency = *cfgYml.HTTP.MultiCertIDs.MaxConcurrency
+	}
+	cfg.HTTP2 = cfgYml.HTTP.HTTP2
+	if cfgYml.HTTP.TLS != nil {
+		cfg.TLS = true
+		cfg.TLSPort = cfgYml.HTTP.TLS.Port
+		cfg.TLSCertificate = cfgYml.HTTP.TLS.Certificate
+		cfg.TLSKey = cfgYml.HTTP.TLS.Key
+		cfg.TLSMinVersion = cfgYml.HTTP.TLS.MinVersion
+		cfg.TLSClientCA = cfgYml.HTTP.TLS.ClientCA
+		cfg.TLSReloadInterval = *cfgYml.HTTP.TLS.ReloadInterval
+		cfg.TLSDisablePlain = cfgYml.HTTP.TLS.DisablePlain
+	}
+	cfg.AdminPort = cfgYml.Admin.Port
+	cfg.Metrics = cfgYml.Admin.Metrics
+	cfg.HealthMaxUpdateAge = *cfgYml.Admin.Health.MaxUpdateAge
+	cfg.HealthMinEntries = *cfgYml.Admin.Health.MinEntries
+	if cfgYml.Admin.API != nil {
+		cfg.AdminAPI = true
+		cfg.AdminAPITokenFile = cfgYml.Admin.API.TokenFile
+	}
 
 	return cfg
 }
@@ -152,11 +203,42 @@ func TestConfigYAML_Verify_Errors(t *testing.T) {
 				InvalidParameterError{"log.format", "[json|pretty]"},
 				InvalidParameterError{"cache.interval", "the number of seconds must be > 0"},
 				InvalidParameterError{"cache.delay", "the number of seconds must be >= 0"},
+				InvalidParameterError{"cache.scan_failure", "[keep|resign]"},
+				InvalidParameterError{"cache.drop_threshold", "the percentage must be between 0 and 100"},
+				InvalidParameterError{"cache.workers", "the number of workers must be > 0"},
 				InvalidParameterError{"db.dynamodb.endpoint", "url must start from 'http://' or 'https://'"},
 				InvalidParameterError{"db.dynamodb.retry_max_attempts", "the number of retries must be >= 0"},
 				InvalidParameterError{"db.dynamodb.timeout", "the number of seconds for timeout must be > 0"},
+				InvalidParameterError{"db.dynamodb.segments", "the number of segments must be 1 to 1000000"},
+				InvalidParameterError{"db.dynamodb.streams.poll_interval", "the number of seconds must be > 0"},
 				InvalidParameterError{"http.port", "must be the valid port number"},
 				InvalidParameterError{"http.cache_control_max_age", "cache-control max-age must be > 0"},
+				InvalidParameterError{"http.shutdown_timeout", "the number of seconds must be > 0"},
+				InvalidParameterError{"http.shutdown_delay", "the number of seconds must be >= 0"},
+				InvalidParameterError{"http.nonce.max_concurrency", "the number of concurrent signings must be > 0"},
+				InvalidParameterError{"http.nonce.max_length", "the number of octets must be between 1 and 32"},
+				InvalidParameterError{"http.cache_miss.response", "[unauthorized|unknown|revoked]"},
+				InvalidParameterError{"http.cache_miss.ttl", "the number of seconds must be > 0"},
+				InvalidParameterError{"http.cache_miss.max_entries", "the number of entries must be > 0"},
+				InvalidParameterError{
+					"http.cache_miss.max_concurrency", "the number of concurrent signings must be > 0",
+				},
+				InvalidParameterError{"http.hash_cache.max_entries", "the number of entries must be > 0"},
+				InvalidParameterError{
+					"http.hash_cache.max_concurrency", "the number of concurrent signings must be > 0",
+				},
+				InvalidParameterError{"http.multiple_cert_ids.max_cert_ids", "the number of CertIDs must be > 1"},
+				InvalidParameterError{
+					"http.multiple_cert_ids.max_concurrency", "the number of concurrent signings must be > 0",
+				},
+				InvalidParameterError{"http.tls.port", "must be the valid port number"},
+				MissingParameterError{"http.tls.certificate"},
+				MissingParameterError{"http.tls.key"},
+				InvalidParameterError{"http.tls.min_version", "[1.2|1.3]"},
+				InvalidParameterError{"http.tls.reload_interval", "the number of seconds must be > 0"},
+				InvalidParameterError{"admin.port", "must be the valid port number"},
+				InvalidParameterError{"admin.health.max_update_age", "the number of seconds must be > 0"},
+				InvalidParameterError{"admin.health.min_entries", "the number of entries must be >= 0"},
 			},
 		},
 		{
@@ -177,6 +259,66 @@ func TestConfigYAML_Verify_Errors(t *testing.T) {
 				},
 			},
 		},
+		{
+			"check invalid value with file DB",
+			"testdata/bad-filedb-watch.yml",
+			[]error{
+				InvalidParameterError{"db.file.watch.debounce", "the number of seconds must be > 0"},
+			},
+		},
+		{
+			"check invalid value with CRL DB",
+			"testdata/bad-crl.yml",
+			[]error{
+				InvalidParameterError{"db.crl.files", "must not contain an empty path"},
+				InvalidParameterError{"db.crl.watch.debounce", "the number of seconds must be > 0"},
+			},
+		},
+		{
+			"check invalid value with certificate directory DB",
+			"testdata/bad-certificates.yml",
+			[]error{
+				MissingParameterError{"db.certificates.dir"},
+				InvalidParameterError{"db.certificates.watch.debounce", "the number of seconds must be > 0"},
+			},
+		},
+		{
+			"check invalid value with HTTP DB",
+			"testdata/bad-http.yml",
+			[]error{
+				InvalidParameterError{"db.http_json.url", "url must start from 'http://' or 'https://'"},
+				InvalidParameterError{"db.http_json.timeout", "the number of seconds for timeout must be > 0"},
+				InvalidParameterError{"db.http_json.retries", "the number of retries must be >= 0"},
+				InvalidParameterError{"db.http_json.pagination.limit", "the number of items must be > 0"},
+				InvalidParameterError{"db.http_json.fields.serial", "must be the JSON path in the dot notation"},
+			},
+		},
+		{
+			"check invalid value with SQL DB",
+			"testdata/bad-sql.yml",
+			[]error{
+				InvalidParameterError{"db.sql.driver", "[postgres|sqlite3]"},
+				InvalidParameterError{"db.sql.table", "must be the valid identifier"},
+				InvalidParameterError{"db.sql.columns.serial", "must be the valid identifier"},
+				InvalidParameterError{"db.sql.timeout", "the number of seconds for timeout must be > 0"},
+			},
+		},
+		{
+			"check required param with SQL DB",
+			"testdata/exclusive-sql.yml",
+			[]error{
+				MissingParameterError{"db.sql.driver"},
+				InvalidParameterError{"db.sql.query", "query is exclusive with table"},
+			},
+		},
+		{
+			"check invalid value with admin API",
+			"testdata/bad-admin-api.yml",
+			[]error{
+				InvalidParameterError{"admin.metrics", "admin.port must be set to serve the metrics"},
+				InvalidParameterError{"admin.api", "admin.port must be set to serve the admin API"},
+			},
+		},
 		{
 			"check required param with empty config",
 			"",
@@ -211,6 +353,55 @@ func TestConfigYAML_Verify_Errors(t *testing.T) {
 				InvalidParameterError{"db.<db-type>", "DB type is exclusive"},
 			},
 		},
+		{
+			"check required and duplicated params with multiple responders",
+			"testdata/bad-multi-responders.yml",
+			[]error{
+				fmt.Errorf("responders[%d]: %w", 1, MissingParameterError{"responder.responder_certificate"}),
+				fmt.Errorf("responders[%d]: %w", 1, MissingParameterError{"db.<db-type>"}),
+				InvalidParameterError{"responders[1].ca", "ca must be unique"},
+				fmt.Errorf("responders[%d]: %w", 2, MissingParameterError{"responder.responder_certificate"}),
+				fmt.Errorf("responders[%d]: %w", 2, MissingParameterError{"responder.issuer_certificate"}),
+				fmt.Errorf("responders[%d]: %w", 2, MissingParameterError{"db.<db-type>"}),
+			},
+		},
+		{
+			"check required and exclusive params with PKCS #11",
+			"testdata/bad-pkcs11.yml",
+			[]error{
+				InvalidParameterError{
+					"responder.pkcs11",
+					"pkcs11 is exclusive with responder_key and responder_key_passphrase_file",
+				},
+				MissingParameterError{"responder.pkcs11.token_label"},
+				MissingParameterError{"responder.pkcs11.key_label"},
+			},
+		},
+		{
+			"check segments without full scan",
+			"testdata/bad-dynamodb-segments.yml",
+			[]error{
+				InvalidParameterError{
+					"db.dynamodb.segments", "segments > 1 scans every item of the index, full_scan must be true",
+				},
+			},
+		},
+		{
+			"check invalid CA name with snapshot directory",
+			"testdata/bad-snapshot-ca.yml",
+			[]error{
+				InvalidParameterError{
+					"responder.ca", "ca must not contain path separators if cache.snapshot_dir is set",
+				},
+			},
+		},
+		{
+			"check invalid value with both responder and responders",
+			"testdata/exclusive-multi-responders.yml",
+			[]error{
+				InvalidParameterError{"responders", "responders is exclusive with responder and db"},
+			},
+		},
 	}
 
 	for _, d := range data {
@@ -235,3 +426,211 @@ func TestConfigYAML_Verify_Errors(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigYAML_Verify_MultiResponders(t *testing.T) {
+	t.Parallel()
+
+	yml := testUnmarshalConfigFIle(t, "testdata/multi-responders.yml")
+
+	var cfg DyOCSPConfig
+	cfg, errs := yml.Verify(cfg)
+	if errs != nil {
+		t.Fatalf("unexpected Error: %#v", errs)
+	}
+
+	rCfgs := cfg.ResponderConfigs()
+	if len(rCfgs) != 2 {
+		t.Fatalf("Expected 2 responder configs but got: %d", len(rCfgs))
+	}
+
+	data := []struct {
+		ca          string
+		certificate string
+		issuer      string
+		dbType      CADBType
+	}{
+		{"sub-ca", "dyocsp/testdata/sub-ocsp-rsa.crt", "dyocsp/testdata/sub-ca-rsa.crt", FileDBType},
+		{"root-ca", "dyocsp/testdata/sub-ca-rsa.crt", "dyocsp/testdata/root-ca-rsa.crt", DynamoDBType},
+	}
+
+	for idx, d := range data {
+		rCfg := rCfgs[idx]
+		if rCfg.CA != d.ca || rCfg.Certificate != d.certificate || rCfg.Issuer != d.issuer {
+			t.Errorf("Expected responder %s but got: %#v", d.ca, rCfg)
+		}
+		if rCfg.DBType != d.dbType {
+			t.Errorf("Expected DB type %d but got: %d", d.dbType, rCfg.DBType)
+		}
+		// Global parameters are inherited
+		if rCfg.Interval != 120 || rCfg.Port != "8080" || rCfg.ZerologLevel != zerolog.DebugLevel {
+			t.Errorf("Global parameters are not inherited: %#v", rCfg)
+		}
+	}
+
+	if rCfgs[0].FileDBFile != "sub-filedb" {
+		t.Errorf("Expected file DB is sub-filedb but got: %s", rCfgs[0].FileDBFile)
+	}
+	if rCfgs[1].DynamoDBTimeout != DynamoDBTimeoutDefault {
+		t.Errorf("Expected DynamoDB timeout is default but got: %d", rCfgs[1].DynamoDBTimeout)
+	}
+}
+
+func TestConfigYAML_Verify_PKCS11(t *testing.T) {
+	t.Parallel()
+
+	yml := testUnmarshalConfigFIle(t, "testdata/pkcs11-filedb.yml")
+
+	var cfg DyOCSPConfig
+	cfg, errs := yml.Verify(cfg)
+	if errs != nil {
+		t.Fatalf("unexpected Error: %#v", errs)
+	}
+
+	if cfg.Key != "" {
+		t.Errorf("Expected responder key is empty but got: %s", cfg.Key)
+	}
+	if cfg.PKCS11Module != "/usr/lib/softhsm/libsofthsm2.so" ||
+		cfg.PKCS11TokenLabel != "dyocsp" ||
+		cfg.PKCS11KeyLabel != "sub-ocsp-rsa" {
+		t.Errorf("Expected PKCS #11 key reference but got: %#v", cfg)
+	}
+}
+
+func TestConfigYAML_Verify_SQL(t *testing.T) {
+	t.Parallel()
+
+	yml := testUnmarshalConfigFIle(t, "testdata/sql.yml")
+
+	var cfg DyOCSPConfig
+	cfg, errs := yml.Verify(cfg)
+	if errs != nil {
+		t.Fatalf("unexpected Error: %#v", errs)
+	}
+
+	if cfg.DBType != SQLDBType || cfg.SQLDriver != "postgres" || cfg.SQLTable != "pki.certificates" {
+		t.Errorf("Expected SQL DB but got: %#v", cfg)
+	}
+	if cfg.SQLTimeout != SQLTimeoutDefault {
+		t.Errorf("Expected SQL timeout is default but got: %d", cfg.SQLTimeout)
+	}
+
+	want := SQLColumnsYAML{
+		CA:        "issuer",
+		Serial:    "serial_number",
+		RevType:   "rev_type",
+		ExpDate:   "not_after",
+		RevDate:   "rev_date",
+		CRLReason: "crl_reason",
+	}
+	if cfg.SQLColumns != want {
+		t.Errorf("Expected columns are %#v but got: %#v", want, cfg.SQLColumns)
+	}
+}
+
+func TestConfigYAML_Verify_FileDBWatch(t *testing.T) {
+	t.Parallel()
+
+	yml := testUnmarshalConfigFIle(t, "testdata/watch-filedb.yml")
+
+	var cfg DyOCSPConfig
+	cfg, errs := yml.Verify(cfg)
+	if errs != nil {
+		t.Fatalf("unexpected Error: %#v", errs)
+	}
+
+	if !cfg.FileDBWatch || cfg.FileDBWatchDebounce != FileDBWatchDebounceDefault {
+		t.Errorf("Expected file DB watch with default debounce but got: %#v", cfg)
+	}
+}
+
+func TestConfigYAML_Verify_CRLDB(t *testing.T) {
+	t.Parallel()
+
+	yml := testUnmarshalConfigFIle(t, "testdata/crl.yml")
+
+	var cfg DyOCSPConfig
+	cfg, errs := yml.Verify(cfg)
+	if errs != nil {
+		t.Fatalf("unexpected Error: %#v", errs)
+	}
+
+	if cfg.DBType != CRLDBType || len(cfg.CRLDBFiles) != 2 || cfg.CRLDBCertDir != "certs" {
+		t.Errorf("Expected CRL DB but got: %#v", cfg)
+	}
+	if !cfg.CRLDBWatch || cfg.CRLDBWatchDebounce != 3 {
+		t.Errorf("Expected CRL DB watch with debounce 3 but got: %#v", cfg)
+	}
+}
+
+func TestConfigYAML_Verify_CertDirDB(t *testing.T) {
+	t.Parallel()
+
+	yml := testUnmarshalConfigFIle(t, "testdata/certificates.yml")
+
+	var cfg DyOCSPConfig
+	cfg, errs := yml.Verify(cfg)
+	if errs != nil {
+		t.Fatalf("unexpected Error: %#v", errs)
+	}
+
+	if cfg.DBType != CertDirDBType || cfg.CertDirDBDir != "certs" || cfg.CertDirDBRevocations != "revocations" {
+		t.Errorf("Expected certificate directory DB but got: %#v", cfg)
+	}
+	if !cfg.CertDirDBWatch || cfg.CertDirDBWatchDebounce != CertDirDBWatchDebounceDefault {
+		t.Errorf("Expected certificate directory DB watch with default debounce but got: %#v", cfg)
+	}
+}
+
+func TestConfigYAML_Verify_HTTPDB(t *testing.T) {
+	t.Parallel()
+
+	yml := testUnmarshalConfigFIle(t, "testdata/http.yml")
+
+	var cfg DyOCSPConfig
+	cfg, errs := yml.Verify(cfg)
+	if errs != nil {
+		t.Fatalf("unexpected Error: %#v", errs)
+	}
+
+	if cfg.DBType != HTTPDBType || cfg.HTTPDBURL != "https://ca.example.com/api/v1/certificates" ||
+		cfg.HTTPDBCAFile != "inventory-ca.crt" {
+		t.Errorf("Expected HTTP DB but got: %#v", cfg)
+	}
+	if cfg.HTTPDBTimeout != HTTPDBTimeoutDefault || cfg.HTTPDBRetries != HTTPDBRetriesDefault {
+		t.Errorf("Expected HTTP timeout and retries are default but got: %d, %d", cfg.HTTPDBTimeout, cfg.HTTPDBRetries)
+	}
+	if cfg.HTTPDBPagination != "cursor" || cfg.HTTPDBCursorParam != "page_token" || cfg.HTTPDBNextCursor != "$.meta.next" {
+		t.Errorf("Expected cursor pagination but got: %#v", cfg)
+	}
+
+	want := HTTPFields{
+		Items:     "data",
+		CA:        "ca",
+		Serial:    "serial_number",
+		RevType:   "rev_type",
+		ExpDate:   "exp_date",
+		RevDate:   "rev_date",
+		CRLReason: "revocation.reason",
+		Subject:   "subject",
+	}
+	if cfg.HTTPDBFields != want {
+		t.Errorf("Expected fields are %#v but got: %#v", want, cfg.HTTPDBFields)
+	}
+}
+
+func TestConfigYAML_Verify_HTTPDB_EmptyCA(t *testing.T) {
+	t.Parallel()
+
+	yml := testUnmarshalConfigFIle(t, "testdata/http-empty-ca.yml")
+
+	var cfg DyOCSPConfig
+	cfg, errs := yml.Verify(cfg)
+	if errs != nil {
+		t.Fatalf("unexpected Error: %#v", errs)
+	}
+
+	// The items of the endpoint are the items of the configured CA
+	if cfg.HTTPDBFields.CA != "" || cfg.HTTPDBFields.Serial != "serial" {
+		t.Errorf("Expected the empty CA field but got: %#v", cfg.HTTPDBFields)
+	}
+}
@@ -10,4 +10,7 @@ type IntermidiateEntry struct {
 	ExpDate   string
 	RevDate   string
 	CRLReason string
+	// Subject DN of the certificate, which is optional and used only for logging
+	// and lookups.
+	Subject string
 }
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Indexes of white space delimited columns in the revocation file.
+const (
+	// Serial Number.
+	RevocationColSerialIdx int = 0
+	// Comma delimited Revocation Date and CRL Reason.
+	RevocationColRevDateAndCRLReasonIdx int = 1
+	// Number of columns.
+	RevocationColNum int = 2
+)
+
+// RevocationFileParseError is used when a line of the revocation file does not
+// follow the format.
+type RevocationFileParseError struct {
+	file string
+	line int
+	msg  string
+}
+
+func (e RevocationFileParseError) Error() string {
+	return fmt.Sprintf("invalid revocation file %s: line %d: %s", e.file, e.line, e.msg)
+}
+
+// CertDirDBClient is an implementation of the CADBClient interface. It derives
+// the certificate revocation status from the certificates in the directory,
+// which are issued by the issuer. The serial number and the expiration date of
+// each entry are read from the certificate, and the revocation is read from the
+// revocation file, if it is set.
+type CertDirDBClient struct {
+	caName string
+	dir    string
+	issuer *x509.Certificate
+	// Options
+	revocationFile string
+	debounce       time.Duration
+}
+
+// WithCertDirRevocations sets the revocation file. Each line of the file has
+// the serial number in hex and the revocation, which is in the format of the
+// "Revoked Date,CRL Reason" column of the file DB, delimited by white spaces.
+// The lines starting with '#' and the blank lines are skipped.
+//
+//	# serial  revocation
+//	0A        230826234911Z,keyCompromise
+func WithCertDirRevocations(file string) func(*CertDirDBClient) {
+	return func(c *CertDirDBClient) {
+		c.revocationFile = file
+	}
+}
+
+// WithCertDirWatchDebounce sets the debounce duration of WatchUpdates. The
+// changes within the duration of each other are notified once. If 0 or less
+// than 0 is set, DefaultWatchDebounce is used.
+func WithCertDirWatchDebounce(debounce time.Duration) func(*CertDirDBClient) {
+	return func(c *CertDirDBClient) {
+		c.debounce = debounce
+	}
+}
+
+// NewCertDirDBClient creates and returns a new instance of CertDirDBClient.
+// The certificates in the directory are DER or PEM encoded.
+func NewCertDirDBClient(
+	caName string, dir string, issuer *x509.Certificate, opts ...func(*CertDirDBClient),
+) CertDirDBClient {
+	c := CertDirDBClient{
+		caName: caName,
+		dir:    dir,
+		issuer: issuer,
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if c.debounce <= 0 {
+		c.debounce = DefaultWatchDebounce
+	}
+
+	return c
+}
+
+// revocation is a line of the revocation file.
+type revocation struct {
+	serial    string
+	revDate   string
+	crlReason string
+}
+
+// readRevocations reads the revocations from the revocation file in the order
+// of the lines.
+func (c CertDirDBClient) readRevocations() ([]revocation, error) {
+	revocations := make([]revocation, 0)
+	if c.revocationFile == "" {
+		return revocations, nil
+	}
+
+	data, err := os.ReadFile(c.revocationFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read revocation file %s: %w", c.revocationFile, err)
+	}
+
+	// The line numbers of the serial numbers
+	serials := make(map[string]int)
+
+	for idx, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parseErr := RevocationFileParseError{file: c.revocationFile, line: idx + 1}
+		cols := strings.Fields(line)
+		if len(cols) != RevocationColNum {
+			parseErr.msg = fmt.Sprintf("%d columns, %d required", len(cols), RevocationColNum)
+			return nil, parseErr
+		}
+
+		serial, ok := SerialStrToBigInt(cols[RevocationColSerialIdx])
+		if !ok {
+			parseErr.msg = fmt.Sprintf("invalid serial number: %q", cols[RevocationColSerialIdx])
+			return nil, parseErr
+		}
+
+		revDate, crlReason, err := parseRevocation(cols[RevocationColRevDateAndCRLReasonIdx])
+		if err != nil {
+			parseErr.msg = err.Error()
+			return nil, parseErr
+		}
+
+		key := serialString(serial)
+		if dup, ok := serials[key]; ok {
+			parseErr.msg = fmt.Sprintf("serial number is duplicated with line %d", dup)
+			return nil, parseErr
+		}
+		serials[key] = idx + 1
+		revocations = append(revocations, revocation{serial: key, revDate: revDate, crlReason: crlReason})
+	}
+
+	return revocations, nil
+}
+
+// Scan reads the certificates and the revocation file, and converts them into
+// IntermediateEntries. If certificates are not issued by the issuer, it returns
+// the joined CertificateIssuerErrors of all of them. The revoked serial number
+// whose certificate is not found is scanned with the expiration date of the
+// issuer, which the certificate cannot exceed.
+func (c CertDirDBClient) Scan(ctx context.Context) ([]IntermidiateEntry, error) {
+	certs, err := readIssuedCertificates(ctx, c.dir, c.issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	revocations, err := c.readRevocations()
+	if err != nil {
+		return nil, err
+	}
+
+	revoked := make(map[string]revocation, len(revocations))
+	for _, rev := range revocations {
+		revoked[rev.serial] = rev
+	}
+
+	entries := make([]IntermidiateEntry, 0, len(certs)+len(revocations))
+	issued := make(map[string]*x509.Certificate, len(certs))
+
+	for _, cert := range certs {
+		serial := serialString(cert.SerialNumber)
+		if dup, ok := issued[serial]; ok {
+			// The copies of the same certificate are scanned once
+			if bytes.Equal(dup.Raw, cert.Raw) {
+				continue
+			}
+			return nil, fmt.Errorf("certificate serial number %s is duplicated in %s", serial, c.dir)
+		}
+		issued[serial] = cert
+
+		entry := IntermidiateEntry{
+			Ca:      c.caName,
+			Serial:  serial,
+			RevType: string(Valid),
+			ExpDate: cert.NotAfter.UTC().Format(ASN1GeneralizedTime),
+			Subject: cert.Subject.String(),
+		}
+		if rev, ok := revoked[serial]; ok {
+			entry.RevType = string(Revoked)
+			entry.RevDate = rev.revDate
+			entry.CRLReason = rev.crlReason
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, rev := range revocations {
+		if _, ok := issued[rev.serial]; ok {
+			continue
+		}
+		entries = append(entries, IntermidiateEntry{
+			Ca:        c.caName,
+			Serial:    rev.serial,
+			RevType:   string(Revoked),
+			ExpDate:   c.issuer.NotAfter.UTC().Format(ASN1GeneralizedTime),
+			RevDate:   rev.revDate,
+			CRLReason: rev.crlReason,
+		})
+	}
+
+	return entries, nil
+}
+
+// WatchUpdates watches the certificate directory and the revocation file until
+// the context is done or an error occurs, and sends to the channel when the
+// files are written, or replaced by rename.
+func (c CertDirDBClient) WatchUpdates(ctx context.Context, updated chan<- struct{}) error {
+	var files []string
+	if c.revocationFile != "" {
+		files = append(files, c.revocationFile)
+	}
+
+	return watchFiles(ctx, files, []string{c.dir}, c.debounce, updated)
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCertDirDBClient_Scan(t *testing.T) {
+	t.Parallel()
+
+	ca := testCreateCA(t, "Test CA")
+	dir := t.TempDir()
+	certDir := filepath.Join(dir, "certs")
+	if err := os.Mkdir(certDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	revoked := testIssueCertPEM(t, ca, 0x0A, "revoked")
+	testWriteFile(t, filepath.Join(certDir, "0A.pem"), revoked)
+	testWriteFile(t, filepath.Join(certDir, "0A-copy.pem"), revoked)
+	testWriteFile(t, filepath.Join(certDir, "chain.pem"),
+		testIssueCertPEM(t, ca, 0x1F, "good"), testIssueCertPEM(t, ca, 0x2F, "hold"))
+	testWriteFile(t, filepath.Join(certDir, ".tmp.pem"), []byte("partially written"))
+
+	revocations := testWriteFile(t, filepath.Join(dir, "revocations"), []byte(strings.Join([]string{
+		"# serial  revocation",
+		"0a        230826234911Z,keyTime,20230825000000Z",
+		"",
+		"FF        230826234911Z",
+		"2F        230826234911Z,holdInstruction,holdInstructionNone",
+	}, "\n")))
+
+	data := []struct {
+		testcase string
+		opts     []func(*CertDirDBClient)
+		// want
+		entries []IntermidiateEntry
+	}{
+		{
+			"certificates only",
+			nil,
+			[]IntermidiateEntry{
+				{"test-ca", "A", "V", "20330101000000Z", "", "", "CN=revoked"},
+				{"test-ca", "1F", "V", "20330101000000Z", "", "", "CN=good"},
+				{"test-ca", "2F", "V", "20330101000000Z", "", "", "CN=hold"},
+			},
+		},
+		{
+			"certificates with revocations",
+			[]func(*CertDirDBClient){WithCertDirRevocations(revocations)},
+			[]IntermidiateEntry{
+				{"test-ca", "A", "R", "20330101000000Z", "230826234911Z", "keyCompromise", "CN=revoked"},
+				{"test-ca", "1F", "V", "20330101000000Z", "", "", "CN=good"},
+				{"test-ca", "2F", "R", "20330101000000Z", "230826234911Z", "certificateHold", "CN=hold"},
+				{"test-ca", "FF", "R", "20430101000000Z", "230826234911Z", "", ""},
+			},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			client := NewCertDirDBClient("test-ca", certDir, ca.cert, d.opts...)
+			entries, err := client.Scan(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(d.entries, entries); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestCertDirDBClient_Scan_Errors(t *testing.T) {
+	t.Parallel()
+
+	ca := testCreateCA(t, "Test CA")
+	other := testCreateCA(t, "Other CA")
+
+	data := []struct {
+		testcase    string
+		certs       map[string][]byte
+		revocations string
+		// want
+		errMsg string
+	}{
+		{
+			"certificate is not parsed",
+			map[string][]byte{"0A.pem": []byte("not certificate")},
+			"", "could not parse certificate",
+		},
+		{
+			"serial number is duplicated",
+			map[string][]byte{
+				"0A.pem":       testIssueCertPEM(t, ca, 0x0A, "first"),
+				"0A-other.pem": testIssueCertPEM(t, ca, 0x0A, "second"),
+			},
+			"", "certificate serial number A is duplicated",
+		},
+		{
+			"revocation without date",
+			map[string][]byte{"0A.pem": testIssueCertPEM(t, ca, 0x0A, "revoked")},
+			"# serial  revocation\n0A\n", "line 2: 1 columns, 2 required",
+		},
+		{
+			"revocation of invalid serial",
+			map[string][]byte{"0A.pem": testIssueCertPEM(t, ca, 0x0A, "revoked")},
+			"XY 230826234911Z\n", `line 1: invalid serial number: "XY"`,
+		},
+		{
+			"revocation of undefined reason",
+			map[string][]byte{"0A.pem": testIssueCertPEM(t, ca, 0x0A, "revoked")},
+			"0A 230826234911Z,stolen\n", `line 1: undefined CRL reason: "stolen"`,
+		},
+		{
+			"revocation is duplicated",
+			map[string][]byte{"0A.pem": testIssueCertPEM(t, ca, 0x0A, "revoked")},
+			"0A 230826234911Z\n0a 230826234911Z,superseded\n", "line 2: serial number is duplicated with line 1",
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			certDir := filepath.Join(dir, "certs")
+			if err := os.Mkdir(certDir, 0o700); err != nil {
+				t.Fatal(err)
+			}
+			for name, cert := range d.certs {
+				testWriteFile(t, filepath.Join(certDir, name), cert)
+			}
+
+			var opts []func(*CertDirDBClient)
+			if d.revocations != "" {
+				revocations := testWriteFile(t, filepath.Join(dir, "revocations"), []byte(d.revocations))
+				opts = append(opts, WithCertDirRevocations(revocations))
+			}
+
+			client := NewCertDirDBClient("test-ca", certDir, ca.cert, opts...)
+			if _, err := client.Scan(context.Background()); err == nil || !strings.Contains(err.Error(), d.errMsg) {
+				t.Errorf("Expected error message contains '%s' but got: %v", d.errMsg, err)
+			}
+		})
+	}
+
+	t.Run("certificates of other issuer", func(t *testing.T) {
+		t.Parallel()
+
+		certDir := t.TempDir()
+		testWriteFile(t, filepath.Join(certDir, "0A.pem"), testIssueCertPEM(t, ca, 0x0A, "good"))
+		testWriteFile(t, filepath.Join(certDir, "1F.pem"), testIssueCertPEM(t, other, 0x1F, "other"))
+		testWriteFile(t, filepath.Join(certDir, "2F.pem"), testIssueCertPEM(t, other, 0x2F, "other"))
+
+		client := NewCertDirDBClient("test-ca", certDir, ca.cert)
+		_, err := client.Scan(context.Background())
+
+		// All of the certificates of the other issuer are reported
+		var issuerErr CertificateIssuerError
+		if !errors.As(err, &issuerErr) || issuerErr.serial != "1F" || !strings.Contains(err.Error(), "certificate 2F") {
+			t.Errorf("Expected CertificateIssuerErrors of 1F and 2F but got: %v", err)
+		}
+	})
+}
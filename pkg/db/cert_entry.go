@@ -93,5 +93,6 @@ type CertificateEntry struct {
 	ExpDate   time.Time
 	RevDate   time.Time
 	CRLReason EntryCRLReason
+	Subject   string
 	Errors    map[InvalidWith]error
 }
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PEM block types of the certificate and the CRL.
+const (
+	PEMCertificateType = "CERTIFICATE"
+	PEMCRLType         = "X509 CRL"
+)
+
+// ErrNoPEMBlock is returned when the PEM data has no block of the expected type.
+var ErrNoPEMBlock = errors.New("no PEM block of the expected type")
+
+// CertificateIssuerError is used when a certificate is not issued by the
+// issuer of the CA.
+type CertificateIssuerError struct {
+	file   string
+	serial string
+	err    error
+}
+
+func (e CertificateIssuerError) Error() string {
+	return fmt.Sprintf("certificate %s in %s is not issued by the issuer: %v", e.serial, e.file, e.err)
+}
+
+func (e CertificateIssuerError) Unwrap() error {
+	return e.err
+}
+
+// serialString returns the serial number in the format of the DB file.
+func serialString(serial *big.Int) string {
+	return strings.ToUpper(serial.Text(SerialBase))
+}
+
+// decodePEMOrDER returns the DER of the PEM blocks of the type, if the data is
+// PEM encoded. Otherwise, the data is returned as a DER.
+func decodePEMOrDER(data []byte, blockType string) ([][]byte, error) {
+	if !bytes.Contains(data, []byte("-----BEGIN ")) {
+		return [][]byte{data}, nil
+	}
+
+	ders := make([][]byte, 0, 1)
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == blockType {
+			ders = append(ders, block.Bytes)
+		}
+	}
+
+	if len(ders) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoPEMBlock, blockType)
+	}
+
+	return ders, nil
+}
+
+// ParseCertificates parses the certificates of the PEM blocks, or a DER
+// encoded certificate.
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	ders, err := decodePEMOrDER(data, PEMCertificateType)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := make([]*x509.Certificate, 0, len(ders))
+	for _, der := range ders {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
+
+// parseRevocationLists parses the CRLs of the PEM blocks, or a DER encoded CRL.
+func parseRevocationLists(data []byte) ([]*x509.RevocationList, error) {
+	ders, err := decodePEMOrDER(data, PEMCRLType)
+	if err != nil {
+		return nil, err
+	}
+
+	crls := make([]*x509.RevocationList, 0, len(ders))
+	for _, der := range ders {
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			return nil, err
+		}
+		crls = append(crls, crl)
+	}
+
+	return crls, nil
+}
+
+// readIssuedCertificates reads the certificates in the files of the directory,
+// which are verified to be issued by the issuer. The hidden files, such as the
+// temporary files of atomic writes, and the subdirectories are skipped. If
+// certificates are not issued by the issuer, it returns the joined
+// CertificateIssuerErrors of all of them. The files are not read after the
+// context is done.
+func readIssuedCertificates(ctx context.Context, dir string, issuer *x509.Certificate) ([]*x509.Certificate, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read certificate directory %s: %w", dir, err)
+	}
+
+	certs := make([]*x509.Certificate, 0, len(files))
+	var issuerErrs []error
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(dir, file.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read certificate %s: %w", path, err)
+		}
+
+		fileCerts, err := ParseCertificates(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse certificate %s: %w", path, err)
+		}
+
+		for _, cert := range fileCerts {
+			if err := cert.CheckSignatureFrom(issuer); err != nil {
+				issuerErrs = append(issuerErrs, CertificateIssuerError{
+					file: path, serial: serialString(cert.SerialNumber), err: err,
+				})
+				continue
+			}
+			certs = append(certs, cert)
+		}
+	}
+
+	if len(issuerErrs) != 0 {
+		return nil, errors.Join(issuerErrs...)
+	}
+
+	return certs, nil
+}
@@ -0,0 +1,240 @@
+package db
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/yuxki/dyocsp/pkg/date"
+)
+
+// Values of the reason codes of the CRL entries (RFC 5280: 5.3.1.).
+var crlReasonValues = map[int]string{
+	int(Unspecified):          UnspecifieValue,
+	int(KeyCompromise):        KeyCompromisValue,
+	int(CACompromise):         CACompromisValue,
+	int(AffiliationChanged):   AffiliationChangeValue,
+	int(Superseded):           SupersedeValue,
+	int(CessationOfOperation): CessationOfOperatioValue,
+	int(CertificateHold):      CertificateHolValue,
+	int(RemoveFromCRL):        RemoveFromCRValue,
+	int(PrivilegeWithdrawn):   PrivilegeWithdrawValue,
+	int(AACompromise):         AACompromisValue,
+}
+
+// CRLDBClient is an implementation of the CADBClient interface. It scans the
+// certificate revocation status from the CRL files issued by the issuer.
+// Each revoked certificate of the CRLs is an entry of the revoked status. The
+// entries of the valid status are read from the issued certificates in the
+// certificate directory, if it is set.
+type CRLDBClient struct {
+	caName   string
+	crlFiles []string
+	issuer   *x509.Certificate
+	// Options
+	certDir  string
+	debounce time.Duration
+	now      date.Now
+}
+
+// WithCRLCertDir sets the directory of the certificates issued by the issuer.
+// The certificates that are not revoked are scanned as the valid entries, and
+// the expiration dates and the subjects of the revoked entries are also read
+// from the certificates.
+func WithCRLCertDir(dir string) func(*CRLDBClient) {
+	return func(c *CRLDBClient) {
+		c.certDir = dir
+	}
+}
+
+// WithCRLWatchDebounce sets the debounce duration of WatchUpdates. The changes
+// within the duration of each other are notified once. If 0 or less than 0 is
+// set, DefaultWatchDebounce is used.
+func WithCRLWatchDebounce(debounce time.Duration) func(*CRLDBClient) {
+	return func(c *CRLDBClient) {
+		c.debounce = debounce
+	}
+}
+
+// WithCRLNow sets the function that returns the current time, which the Next
+// Update of the CRLs is compared with. If not set, date.NowGMT is used.
+func WithCRLNow(now date.Now) func(*CRLDBClient) {
+	return func(c *CRLDBClient) {
+		c.now = now
+	}
+}
+
+// NewCRLDBClient creates and returns a new instance of CRLDBClient. The CRL
+// files are DER or PEM encoded, and their signatures are verified with the
+// issuer certificate.
+func NewCRLDBClient(
+	caName string, crlFiles []string, issuer *x509.Certificate, opts ...func(*CRLDBClient),
+) CRLDBClient {
+	c := CRLDBClient{
+		caName:   caName,
+		crlFiles: crlFiles,
+		issuer:   issuer,
+		now:      date.NowGMT,
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if c.debounce <= 0 {
+		c.debounce = DefaultWatchDebounce
+	}
+
+	return c
+}
+
+// readCRLs reads and verifies the CRLs of the CRL files. The CRL whose Next
+// Update has passed is rejected as stale, since the revocations after it are
+// not published in the CRL. The files are not read after the context is done.
+func (c CRLDBClient) readCRLs(ctx context.Context) ([]*x509.RevocationList, error) {
+	now := c.now()
+	crls := make([]*x509.RevocationList, 0, len(c.crlFiles))
+	for _, file := range c.crlFiles {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CRL %s: %w", file, err)
+		}
+
+		fileCRLs, err := parseRevocationLists(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse CRL %s: %w", file, err)
+		}
+
+		for _, crl := range fileCRLs {
+			if err := crl.CheckSignatureFrom(c.issuer); err != nil {
+				return nil, fmt.Errorf("could not verify CRL %s: %w", file, err)
+			}
+			if !crl.NextUpdate.IsZero() && now.After(crl.NextUpdate) {
+				return nil, fmt.Errorf("CRL %s is stale: next update %s has passed",
+					file, crl.NextUpdate.UTC().Format(time.RFC3339))
+			}
+		}
+		crls = append(crls, fileCRLs...)
+	}
+
+	return crls, nil
+}
+
+// revokedEntry converts a revoked certificate of the CRL into an IntermediateEntry.
+// If the certificate is not found, the expiration date is the one of the issuer,
+// which the certificate cannot exceed.
+func (c CRLDBClient) revokedEntry(revoked x509.RevocationListEntry, cert *x509.Certificate) IntermidiateEntry {
+	entry := IntermidiateEntry{
+		Ca:      c.caName,
+		Serial:  serialString(revoked.SerialNumber),
+		RevType: string(Revoked),
+		ExpDate: c.issuer.NotAfter.UTC().Format(ASN1GeneralizedTime),
+		RevDate: revoked.RevocationTime.UTC().Format(ASN1GeneralizedTime),
+	}
+
+	// The undefined reason code is rejected by the verification
+	entry.CRLReason = strconv.Itoa(revoked.ReasonCode)
+	if value, ok := crlReasonValues[revoked.ReasonCode]; ok {
+		entry.CRLReason = value
+	}
+
+	if cert != nil {
+		entry.ExpDate = cert.NotAfter.UTC().Format(ASN1GeneralizedTime)
+		entry.Subject = cert.Subject.String()
+	}
+
+	return entry
+}
+
+// Scan reads the CRLs and the certificates, and converts them into
+// IntermediateEntries. If a serial number is revoked in multiple CRLs, the
+// entry of the latest CRL is used. The serial number whose latest entry has
+// the reason removeFromCRL is not revoked, and is dropped from the entries.
+func (c CRLDBClient) Scan(ctx context.Context) ([]IntermidiateEntry, error) {
+	crls, err := c.readCRLs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	if c.certDir != "" {
+		certs, err = readIssuedCertificates(ctx, c.certDir, c.issuer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	issued := make(map[string]*x509.Certificate, len(certs))
+	for _, cert := range certs {
+		issued[serialString(cert.SerialNumber)] = cert
+	}
+
+	entries := make([]IntermidiateEntry, 0, len(certs))
+	// The indexes of the entries and the Update Time of their CRLs
+	revokedIdx := make(map[string]int)
+	revokedAt := make(map[string]time.Time)
+
+	for _, crl := range crls {
+		for _, revoked := range crl.RevokedCertificateEntries {
+			entry := c.revokedEntry(revoked, issued[serialString(revoked.SerialNumber)])
+
+			idx, ok := revokedIdx[entry.Serial]
+			if !ok {
+				revokedIdx[entry.Serial] = len(entries)
+				revokedAt[entry.Serial] = crl.ThisUpdate
+				entries = append(entries, entry)
+				continue
+			}
+			if crl.ThisUpdate.After(revokedAt[entry.Serial]) {
+				revokedAt[entry.Serial] = crl.ThisUpdate
+				entries[idx] = entry
+			}
+		}
+	}
+
+	// The certificates removed from the CRL are valid if they are issued
+	revoked := entries
+	entries = make([]IntermidiateEntry, 0, len(revoked)+len(certs))
+	for _, entry := range revoked {
+		if entry.CRLReason == RemoveFromCRValue {
+			delete(revokedIdx, entry.Serial)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, cert := range certs {
+		serial := serialString(cert.SerialNumber)
+		if _, ok := revokedIdx[serial]; ok {
+			continue
+		}
+		entries = append(entries, IntermidiateEntry{
+			Ca:      c.caName,
+			Serial:  serial,
+			RevType: string(Valid),
+			ExpDate: cert.NotAfter.UTC().Format(ASN1GeneralizedTime),
+			Subject: cert.Subject.String(),
+		})
+	}
+
+	return entries, nil
+}
+
+// WatchUpdates watches the CRL files and the certificate directory until the
+// context is done or an error occurs, and sends to the channel when the files
+// are written, or replaced by rename.
+func (c CRLDBClient) WatchUpdates(ctx context.Context, updated chan<- struct{}) error {
+	var dirs []string
+	if c.certDir != "" {
+		dirs = append(dirs, c.certDir)
+	}
+
+	return watchFiles(ctx, c.crlFiles, dirs, c.debounce, updated)
+}
@@ -0,0 +1,304 @@
+package db
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func testCreateCA(t *testing.T, cn string) testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2043, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testCA{cert, key}
+}
+
+// testIssueCertPEM issues a certificate of the serial number, and returns it in PEM.
+func testIssueCertPEM(t *testing.T, ca testCA, serial int64, cn string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2033, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: PEMCertificateType, Bytes: der})
+}
+
+// testCreateCRL creates a DER encoded CRL with the revoked entries.
+func testCreateCRL(t *testing.T, ca testCA, number int64, revoked ...x509.RevocationListEntry) []byte {
+	t.Helper()
+
+	tmpl := &x509.RevocationList{
+		Number:                    big.NewInt(number),
+		ThisUpdate:                time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(number)),
+		NextUpdate:                time.Date(2043, 1, 1, 0, 0, 0, 0, time.UTC),
+		RevokedCertificateEntries: revoked,
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, tmpl, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return der
+}
+
+func testWriteFile(t *testing.T, path string, data ...[]byte) string {
+	t.Helper()
+
+	var content []byte
+	for _, d := range data {
+		content = append(content, d...)
+	}
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestCRLDBClient_Scan(t *testing.T) {
+	t.Parallel()
+
+	ca := testCreateCA(t, "Test CA")
+	revokedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+
+	// The CRL of the later update supersedes the revocation of 0x0A
+	pemCRL := testWriteFile(t, filepath.Join(dir, "ca.crl.pem"),
+		pem.EncodeToMemory(&pem.Block{Type: PEMCRLType, Bytes: testCreateCRL(t, ca, 1,
+			x509.RevocationListEntry{SerialNumber: big.NewInt(0x0A), RevocationTime: revokedAt, ReasonCode: 6},
+		)}),
+		pem.EncodeToMemory(&pem.Block{Type: PEMCRLType, Bytes: testCreateCRL(t, ca, 2,
+			x509.RevocationListEntry{SerialNumber: big.NewInt(0x0A), RevocationTime: revokedAt, ReasonCode: 1},
+		)}),
+	)
+	derCRL := testWriteFile(t, filepath.Join(dir, "ca.crl"), testCreateCRL(t, ca, 1,
+		x509.RevocationListEntry{SerialNumber: big.NewInt(0x0B), RevocationTime: revokedAt},
+		x509.RevocationListEntry{SerialNumber: big.NewInt(0x0C), RevocationTime: revokedAt, ReasonCode: 7},
+		x509.RevocationListEntry{SerialNumber: big.NewInt(0x0D), RevocationTime: revokedAt, ReasonCode: 6},
+	))
+	// The CRL of the later update removes 0x0D from the CRL
+	deltaCRL := testWriteFile(t, filepath.Join(dir, "delta.crl"), testCreateCRL(t, ca, 2,
+		x509.RevocationListEntry{SerialNumber: big.NewInt(0x0D), RevocationTime: revokedAt, ReasonCode: 8},
+	))
+
+	certDir := filepath.Join(dir, "certs")
+	if err := os.Mkdir(certDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	testWriteFile(t, filepath.Join(certDir, "0A.pem"), testIssueCertPEM(t, ca, 0x0A, "revoked"))
+	testWriteFile(t, filepath.Join(certDir, "0D.pem"), testIssueCertPEM(t, ca, 0x0D, "removed"))
+	testWriteFile(t, filepath.Join(certDir, "1F.pem"), testIssueCertPEM(t, ca, 0x1F, "good"))
+	testWriteFile(t, filepath.Join(certDir, ".tmp.pem"), []byte("partially written"))
+
+	revokedEntries := []IntermidiateEntry{
+		{"test-ca", "A", "R", "20430101000000Z", "20240101000000Z", "keyCompromise", ""},
+		{"test-ca", "B", "R", "20430101000000Z", "20240101000000Z", "unspecified", ""},
+		{"test-ca", "C", "R", "20430101000000Z", "20240101000000Z", "7", ""},
+	}
+
+	data := []struct {
+		testcase string
+		opts     []func(*CRLDBClient)
+		// want
+		entries []IntermidiateEntry
+	}{
+		{"CRLs only", nil, revokedEntries},
+		{
+			"CRLs with certificates",
+			[]func(*CRLDBClient){WithCRLCertDir(certDir)},
+			[]IntermidiateEntry{
+				{"test-ca", "A", "R", "20330101000000Z", "20240101000000Z", "keyCompromise", "CN=revoked"},
+				revokedEntries[1],
+				revokedEntries[2],
+				{"test-ca", "D", "V", "20330101000000Z", "", "", "CN=removed"},
+				{"test-ca", "1F", "V", "20330101000000Z", "", "", "CN=good"},
+			},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			client := NewCRLDBClient("test-ca", []string{pemCRL, derCRL, deltaCRL}, ca.cert, d.opts...)
+			entries, err := client.Scan(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(d.entries, entries); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestCRLDBClient_Scan_Errors(t *testing.T) {
+	t.Parallel()
+
+	ca := testCreateCA(t, "Test CA")
+	other := testCreateCA(t, "Other CA")
+	dir := t.TempDir()
+
+	validCRL := testWriteFile(t, filepath.Join(dir, "valid.crl"), testCreateCRL(t, ca, 1))
+	otherCRL := testWriteFile(t, filepath.Join(dir, "other.crl"), testCreateCRL(t, other, 1))
+	truncatedCRL := testWriteFile(t, filepath.Join(dir, "truncated.crl"), testCreateCRL(t, ca, 1)[:10])
+	certCRL := testWriteFile(t, filepath.Join(dir, "cert.crl"), testIssueCertPEM(t, ca, 0x0A, "not CRL"))
+
+	otherDir := filepath.Join(dir, "other")
+	if err := os.Mkdir(otherDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	testWriteFile(t, filepath.Join(otherDir, "1F.pem"), testIssueCertPEM(t, other, 0x1F, "other"))
+
+	data := []struct {
+		testcase string
+		crlFile  string
+		opts     []func(*CRLDBClient)
+		// want
+		errMsg string
+	}{
+		{"CRL not found", filepath.Join(dir, "notfound.crl"), nil, "could not read CRL"},
+		{"CRL of other issuer", otherCRL, nil, "could not verify CRL"},
+		{"truncated CRL", truncatedCRL, nil, "could not parse CRL"},
+		{"no CRL PEM block", certCRL, nil, "could not parse CRL"},
+		{
+			"stale CRL", validCRL,
+			[]func(*CRLDBClient){WithCRLNow(func() time.Time { return time.Date(2043, 1, 2, 0, 0, 0, 0, time.UTC) })},
+			"is stale",
+		},
+		{
+			"certificate directory not found", validCRL,
+			[]func(*CRLDBClient){WithCRLCertDir(filepath.Join(dir, "notfound"))},
+			"could not read certificate directory",
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			client := NewCRLDBClient("test-ca", []string{d.crlFile}, ca.cert, d.opts...)
+			if _, err := client.Scan(context.Background()); err == nil || !strings.Contains(err.Error(), d.errMsg) {
+				t.Errorf("Expected error message contains '%s' but got: %v", d.errMsg, err)
+			}
+		})
+	}
+
+	t.Run("canceled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		client := NewCRLDBClient("test-ca", []string{validCRL}, ca.cert)
+		if _, err := client.Scan(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled but got: %v", err)
+		}
+	})
+
+	t.Run("certificate of other issuer", func(t *testing.T) {
+		t.Parallel()
+
+		client := NewCRLDBClient("test-ca", []string{validCRL}, ca.cert, WithCRLCertDir(otherDir))
+		_, err := client.Scan(context.Background())
+
+		var issuerErr CertificateIssuerError
+		if !errors.As(err, &issuerErr) || issuerErr.serial != "1F" {
+			t.Errorf("Expected CertificateIssuerError of 1F but got: %v", err)
+		}
+	})
+}
+
+func TestCRLDBClient_WatchUpdates(t *testing.T) {
+	t.Parallel()
+
+	ca := testCreateCA(t, "Test CA")
+	dir := t.TempDir()
+	crlFile := testWriteFile(t, filepath.Join(dir, "ca.crl"), testCreateCRL(t, ca, 1))
+	certDir := filepath.Join(dir, "certs")
+	if err := os.Mkdir(certDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	debounce := 100 * time.Millisecond
+	client := NewCRLDBClient("test-ca", []string{crlFile}, ca.cert,
+		WithCRLCertDir(certDir), WithCRLWatchDebounce(debounce))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updated := make(chan struct{}, 1)
+	go func() {
+		if err := client.WatchUpdates(ctx, updated); err != nil {
+			t.Error(err)
+		}
+	}()
+	time.Sleep(debounce)
+
+	updates := []func(){
+		func() { testWriteFile(t, crlFile, testCreateCRL(t, ca, 2)) },
+		func() { testWriteFile(t, filepath.Join(certDir, "1F.pem"), testIssueCertPEM(t, ca, 0x1F, "good")) },
+	}
+	for _, update := range updates {
+		update()
+		select {
+		case <-updated:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Update is not notified.")
+		}
+	}
+}
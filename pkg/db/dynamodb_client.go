@@ -2,22 +2,63 @@ package db
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+// dynamoDBAPI is the subset of the DynamoDB API used by DynamoDBClient.
+type dynamoDBAPI interface {
+	Query(
+		ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options),
+	) (*dynamodb.QueryOutput, error)
+	Scan(
+		ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options),
+	) (*dynamodb.ScanOutput, error)
+}
+
 // The DynamoDBClient is an implementation of the CADBClient interface. It is used
 // to scan the certificate revocation status from the DynamoDB. Please refer to the
 // documentation for specifications on the table and index.
 type DynamoDBClient struct {
-	client    *dynamodb.Client
+	client    dynamoDBAPI
 	caName    *string
 	tableName *string
 	indexName *string
 	timeout   int
+	// Options
+	segments int
+	// Statistics of the last scan
+	stats *scanStatsStore
+}
+
+// DefaultDynamoDBSegments is the default number of segments. When the number
+// of segments is 1, the items are read by Query.
+const DefaultDynamoDBSegments = 1
+
+// MaxDynamoDBSegments is the maximum number of segments that DynamoDB accepts.
+const MaxDynamoDBSegments = 1000000
+
+// ErrDynamoDBSegmentsRange is returned when the number of segments is out of range.
+var ErrDynamoDBSegmentsRange = errors.New("the number of segments must be 1 to 1000000")
+
+// WithSegments sets the number of segments. When the number is greater than 1,
+// the items are read by the parallel Scan that is split into the segments,
+// instead of Query. Query cannot be split into segments. Default value is 1.
+//
+// The parallel Scan reads every item of the index, including the items of the
+// other CAs, which are dropped by the filter expression after they are read.
+// So each scan consumes the read capacity of the whole index. Use it only when
+// the index holds mostly the items of the CA.
+func WithSegments(segments int) func(*DynamoDBClient) {
+	return func(d *DynamoDBClient) {
+		d.segments = segments
+	}
 }
 
 // NewDynamoDBClient creates and returns new DynamoDBClient instance.
@@ -27,14 +68,27 @@ func NewDynamoDBClient(
 	tableName *string,
 	indexName *string,
 	timeout int,
-) DynamoDBClient {
-	return DynamoDBClient{
+	opts ...func(*DynamoDBClient),
+) (DynamoDBClient, error) {
+	d := DynamoDBClient{
 		client:    client,
 		caName:    caName,
 		tableName: tableName,
 		indexName: indexName,
 		timeout:   timeout,
+		segments:  DefaultDynamoDBSegments,
+		stats:     &scanStatsStore{},
+	}
+
+	for _, opt := range opts {
+		opt(&d)
 	}
+
+	if d.segments < 1 || d.segments > MaxDynamoDBSegments {
+		return d, ErrDynamoDBSegmentsRange
+	}
+
+	return d, nil
 }
 
 type unmarshalFailedError struct {
@@ -108,46 +162,37 @@ func UnmarshalDynamoDBItem(item map[string]types.AttributeValue) (IntermidiateEn
 	}, nil
 }
 
-// Scan read sthe items from the table.
-// Set the filter expression to the secondary global index with the "ca" hash key.
+const (
+	dynamoDBKeyCondition = "ca = :ca"
+	dynamoDBProjection   = "ca,serial,rev_type,exp_date,rev_date,crl_reason"
+)
+
+// Scan reads the items of the CA from the table.
+// By default, Query with the key condition expression on the "ca" hash key of
+// the global secondary index is used, so that only the items of the CA are read.
+// When the number of segments is greater than 1, the segments of the index are
+// read by the parallel Scan with the filter expression on the "ca" attribute,
+// which reads every item of the index.
 // Retrieve the items and unmarshal them into IntermediateEntry.
 func (d DynamoDBClient) Scan(ctx context.Context) ([]IntermidiateEntry, error) {
-	var input dynamodb.ScanInput
-
-	fex := "ca = :ca"
-	pje := "ca,serial,rev_type,exp_date,rev_date,crl_reason"
 	eav, err := attributevalue.MarshalMap(map[string]string{":ca": *d.caName})
 	if err != nil {
 		return nil, err
 	}
 
-	input.TableName = d.tableName
-	input.IndexName = d.indexName
-	input.Select = "SPECIFIC_ATTRIBUTES"
-	input.FilterExpression = &fex
-	input.ExpressionAttributeValues = eav
-	input.ProjectionExpression = &pje
-
-	items := make([]map[string]types.AttributeValue, 0)
-	var lastEvaluatedKey map[string]types.AttributeValue
-
 	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(d.timeout))
 	defer cancel()
-	for {
-		input.ExclusiveStartKey = lastEvaluatedKey
-
-		out, err := d.client.Scan(ctx, &input)
-		if err != nil {
-			return nil, err
-		}
 
-		items = append(items, out.Items...)
-
-		if out.LastEvaluatedKey != nil {
-			lastEvaluatedKey = out.LastEvaluatedKey
-			continue
-		}
-		break
+	var items []map[string]types.AttributeValue
+	var stats ScanStats
+	if d.segments > 1 {
+		items, stats, err = d.parallelScan(ctx, eav)
+	} else {
+		items, stats, err = d.query(ctx, eav)
+	}
+	d.stats.set(stats)
+	if err != nil {
+		return nil, err
 	}
 
 	entries := make([]IntermidiateEntry, 0, len(items))
@@ -161,3 +206,120 @@ func (d DynamoDBClient) Scan(ctx context.Context) ([]IntermidiateEntry, error) {
 
 	return entries, nil
 }
+
+// LastScanStats returns the statistics of the last scan.
+func (d DynamoDBClient) LastScanStats() ScanStats {
+	return d.stats.get()
+}
+
+func (d DynamoDBClient) query(
+	ctx context.Context, eav map[string]types.AttributeValue,
+) ([]map[string]types.AttributeValue, ScanStats, error) {
+	var stats ScanStats
+
+	kce := dynamoDBKeyCondition
+	pje := dynamoDBProjection
+	input := dynamodb.QueryInput{
+		TableName:                 d.tableName,
+		IndexName:                 d.indexName,
+		Select:                    types.SelectSpecificAttributes,
+		KeyConditionExpression:    &kce,
+		ExpressionAttributeValues: eav,
+		ProjectionExpression:      &pje,
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+	}
+
+	items := make([]map[string]types.AttributeValue, 0)
+	for {
+		out, err := d.client.Query(ctx, &input)
+		if err != nil {
+			return nil, stats, err
+		}
+
+		items = append(items, out.Items...)
+		stats.add(out.ConsumedCapacity, out.ScannedCount)
+
+		if out.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	return items, stats, nil
+}
+
+func (d DynamoDBClient) parallelScan(
+	ctx context.Context, eav map[string]types.AttributeValue,
+) ([]map[string]types.AttributeValue, ScanStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	segItems := make([][]map[string]types.AttributeValue, d.segments)
+	segStats := make([]ScanStats, d.segments)
+	segErrs := make([]error, d.segments)
+
+	var wg sync.WaitGroup
+	for seg := range d.segments {
+		wg.Go(func() {
+			segItems[seg], segStats[seg], segErrs[seg] = d.scanSegment(ctx, eav, seg)
+			if segErrs[seg] != nil {
+				// Stop the other segments
+				cancel()
+			}
+		})
+	}
+	wg.Wait()
+
+	var stats ScanStats
+	items := make([]map[string]types.AttributeValue, 0)
+	for seg := range d.segments {
+		stats.ConsumedCapacity += segStats[seg].ConsumedCapacity
+		stats.ScannedCount += segStats[seg].ScannedCount
+		stats.Requests += segStats[seg].Requests
+		items = append(items, segItems[seg]...)
+	}
+
+	if err := errors.Join(segErrs...); err != nil {
+		return nil, stats, err
+	}
+
+	return items, stats, nil
+}
+
+func (d DynamoDBClient) scanSegment(
+	ctx context.Context, eav map[string]types.AttributeValue, segment int,
+) ([]map[string]types.AttributeValue, ScanStats, error) {
+	var stats ScanStats
+
+	fex := dynamoDBKeyCondition
+	pje := dynamoDBProjection
+	input := dynamodb.ScanInput{
+		TableName:                 d.tableName,
+		IndexName:                 d.indexName,
+		Select:                    types.SelectSpecificAttributes,
+		FilterExpression:          &fex,
+		ExpressionAttributeValues: eav,
+		ProjectionExpression:      &pje,
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+		Segment:                   aws.Int32(int32(segment)),
+		TotalSegments:             aws.Int32(int32(d.segments)),
+	}
+
+	items := make([]map[string]types.AttributeValue, 0)
+	for {
+		out, err := d.client.Scan(ctx, &input)
+		if err != nil {
+			return nil, stats, err
+		}
+
+		items = append(items, out.Items...)
+		stats.add(out.ConsumedCapacity, out.ScannedCount)
+
+		if out.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	return items, stats, nil
+}
@@ -0,0 +1,266 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/go-cmp/cmp"
+)
+
+func testDynamoDBItem(ca, serial string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"ca":         &types.AttributeValueMemberS{Value: ca},
+		"serial":     &types.AttributeValueMemberS{Value: serial},
+		"rev_type":   &types.AttributeValueMemberS{Value: "V"},
+		"exp_date":   &types.AttributeValueMemberS{Value: "330823234911Z"},
+		"rev_date":   &types.AttributeValueMemberS{Value: ""},
+		"crl_reason": &types.AttributeValueMemberS{Value: ""},
+	}
+}
+
+func testIntermidiateEntry(ca, serial string) IntermidiateEntry {
+	return IntermidiateEntry{ca, serial, "V", "330823234911Z", "", "", ""}
+}
+
+// stubDynamoDBAPI returns the pages in order. The pages of Scan are
+// returned per segment.
+type stubDynamoDBAPI struct {
+	queryPages  [][]map[string]types.AttributeValue
+	scanPages   map[int32][][]map[string]types.AttributeValue
+	err         error
+	queryInputs []dynamodb.QueryInput
+	scanInputs  []dynamodb.ScanInput
+	mu          sync.Mutex
+}
+
+func pageOutput(
+	pages [][]map[string]types.AttributeValue, startKey map[string]types.AttributeValue,
+) ([]map[string]types.AttributeValue, map[string]types.AttributeValue) {
+	page := 0
+	if startKey != nil {
+		page = int(startKey["page"].(*types.AttributeValueMemberN).Value[0] - '0')
+	}
+
+	var lastKey map[string]types.AttributeValue
+	if page+1 < len(pages) {
+		lastKey = map[string]types.AttributeValue{
+			"page": &types.AttributeValueMemberN{Value: string(rune('0' + page + 1))},
+		}
+	}
+
+	return pages[page], lastKey
+}
+
+func (s *stubDynamoDBAPI) Query(
+	_ context.Context, params *dynamodb.QueryInput, _ ...func(*dynamodb.Options),
+) (*dynamodb.QueryOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queryInputs = append(s.queryInputs, *params)
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	items, lastKey := pageOutput(s.queryPages, params.ExclusiveStartKey)
+	return &dynamodb.QueryOutput{
+		Items:            items,
+		LastEvaluatedKey: lastKey,
+		ScannedCount:     int32(len(items)),
+		ConsumedCapacity: &types.ConsumedCapacity{CapacityUnits: aws.Float64(0.5)},
+	}, nil
+}
+
+func (s *stubDynamoDBAPI) Scan(
+	_ context.Context, params *dynamodb.ScanInput, _ ...func(*dynamodb.Options),
+) (*dynamodb.ScanOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scanInputs = append(s.scanInputs, *params)
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	items, lastKey := pageOutput(s.scanPages[*params.Segment], params.ExclusiveStartKey)
+	return &dynamodb.ScanOutput{
+		Items:            items,
+		LastEvaluatedKey: lastKey,
+		ScannedCount:     int32(len(items)),
+		ConsumedCapacity: &types.ConsumedCapacity{CapacityUnits: aws.Float64(1.5)},
+	}, nil
+}
+
+func testDynamoDBClient(api dynamoDBAPI, segments int) DynamoDBClient {
+	ca := "test-ca"
+	table := "test-table"
+	index := "test-index"
+	return DynamoDBClient{
+		client:    api,
+		caName:    &ca,
+		tableName: &table,
+		indexName: &index,
+		timeout:   60,
+		segments:  segments,
+		stats:     &scanStatsStore{},
+	}
+}
+
+func TestDynamoDBClient_Scan_Query(t *testing.T) {
+	t.Parallel()
+
+	api := &stubDynamoDBAPI{
+		queryPages: [][]map[string]types.AttributeValue{
+			{testDynamoDBItem("test-ca", "01"), testDynamoDBItem("test-ca", "02")},
+			{testDynamoDBItem("test-ca", "03")},
+		},
+	}
+	client := testDynamoDBClient(api, 1)
+
+	entries, err := client.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []IntermidiateEntry{
+		testIntermidiateEntry("test-ca", "01"),
+		testIntermidiateEntry("test-ca", "02"),
+		testIntermidiateEntry("test-ca", "03"),
+	}
+	if diff := cmp.Diff(entries, want); diff != "" {
+		t.Error(diff)
+	}
+
+	if len(api.scanInputs) != 0 {
+		t.Errorf("Scan is called %d times", len(api.scanInputs))
+	}
+	if len(api.queryInputs) != 2 {
+		t.Fatalf("Expected Query is called 2 times but got: %d", len(api.queryInputs))
+	}
+	input := api.queryInputs[0]
+	if *input.KeyConditionExpression != "ca = :ca" {
+		t.Errorf("Unexpected key condition expression: %s", *input.KeyConditionExpression)
+	}
+	if *input.IndexName != "test-index" {
+		t.Errorf("Unexpected index name: %s", *input.IndexName)
+	}
+	if input.ReturnConsumedCapacity != types.ReturnConsumedCapacityTotal {
+		t.Errorf("Unexpected return consumed capacity: %s", input.ReturnConsumedCapacity)
+	}
+	if api.queryInputs[1].ExclusiveStartKey == nil {
+		t.Error("The second page is requested without exclusive start key")
+	}
+
+	wantStats := ScanStats{ConsumedCapacity: 1.0, ScannedCount: 3, Requests: 2}
+	if stats := client.LastScanStats(); stats != wantStats {
+		t.Errorf("Expected stats is %#v but got: %#v", wantStats, stats)
+	}
+}
+
+func TestDynamoDBClient_Scan_Segments(t *testing.T) {
+	t.Parallel()
+
+	api := &stubDynamoDBAPI{
+		scanPages: map[int32][][]map[string]types.AttributeValue{
+			0: {{testDynamoDBItem("test-ca", "01")}, {testDynamoDBItem("test-ca", "02")}},
+			1: {{}},
+			2: {{testDynamoDBItem("test-ca", "03")}},
+		},
+	}
+	client := testDynamoDBClient(api, 3)
+
+	entries, err := client.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []IntermidiateEntry{
+		testIntermidiateEntry("test-ca", "01"),
+		testIntermidiateEntry("test-ca", "02"),
+		testIntermidiateEntry("test-ca", "03"),
+	}
+	if diff := cmp.Diff(entries, want); diff != "" {
+		t.Error(diff)
+	}
+
+	if len(api.queryInputs) != 0 {
+		t.Errorf("Query is called %d times", len(api.queryInputs))
+	}
+	segments := make([]int, 0, len(api.scanInputs))
+	for _, input := range api.scanInputs {
+		if *input.TotalSegments != 3 {
+			t.Errorf("Unexpected total segments: %d", *input.TotalSegments)
+		}
+		if *input.FilterExpression != "ca = :ca" {
+			t.Errorf("Unexpected filter expression: %s", *input.FilterExpression)
+		}
+		segments = append(segments, int(*input.Segment))
+	}
+	sort.Ints(segments)
+	if diff := cmp.Diff(segments, []int{0, 0, 1, 2}); diff != "" {
+		t.Error(diff)
+	}
+
+	wantStats := ScanStats{ConsumedCapacity: 6.0, ScannedCount: 3, Requests: 4}
+	if stats := client.LastScanStats(); stats != wantStats {
+		t.Errorf("Expected stats is %#v but got: %#v", wantStats, stats)
+	}
+}
+
+func TestDynamoDBClient_Scan_Error(t *testing.T) {
+	t.Parallel()
+
+	errAPI := errors.New("api error")
+	data := []struct {
+		testcase string
+		segments int
+	}{
+		{"Query", 1},
+		{"Segmented Scan", 3},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			client := testDynamoDBClient(&stubDynamoDBAPI{err: errAPI}, d.segments)
+			_, err := client.Scan(context.Background())
+			if !errors.Is(err, errAPI) {
+				t.Errorf("Expected error is %#v but got: %#v", errAPI, err)
+			}
+		})
+	}
+}
+
+func TestNewDynamoDBClient_ErrDynamoDBSegmentsRange(t *testing.T) {
+	t.Parallel()
+
+	data := []struct {
+		testcase string
+		segments int
+		err      error
+	}{
+		{"default", DefaultDynamoDBSegments, nil},
+		{"max", MaxDynamoDBSegments, nil},
+		{"zero", 0, ErrDynamoDBSegmentsRange},
+		{"over max", MaxDynamoDBSegments + 1, ErrDynamoDBSegmentsRange},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			ca := "test-ca"
+			_, err := NewDynamoDBClient(nil, &ca, &ca, &ca, 60, WithSegments(d.segments))
+			if !errors.Is(err, d.err) {
+				t.Errorf("Expected error is %#v but got: %#v", d.err, err)
+			}
+		})
+	}
+}
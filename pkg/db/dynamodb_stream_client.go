@@ -0,0 +1,328 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+// EntryChange is a change of an entry in the database.
+type EntryChange struct {
+	Entry IntermidiateEntry
+	// Removed is true when the entry was removed from the database.
+	// Only Ca and Serial of the Entry are set for a removed entry.
+	Removed bool
+}
+
+// dynamoDBTableAPI is the subset of the DynamoDB API used by DynamoDBStreamClient.
+type dynamoDBTableAPI interface {
+	DescribeTable(
+		ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options),
+	) (*dynamodb.DescribeTableOutput, error)
+}
+
+// dynamoDBStreamsAPI is the subset of the DynamoDB Streams API used by DynamoDBStreamClient.
+type dynamoDBStreamsAPI interface {
+	DescribeStream(
+		ctx context.Context, params *dynamodbstreams.DescribeStreamInput, optFns ...func(*dynamodbstreams.Options),
+	) (*dynamodbstreams.DescribeStreamOutput, error)
+	GetShardIterator(
+		ctx context.Context, params *dynamodbstreams.GetShardIteratorInput, optFns ...func(*dynamodbstreams.Options),
+	) (*dynamodbstreams.GetShardIteratorOutput, error)
+	GetRecords(
+		ctx context.Context, params *dynamodbstreams.GetRecordsInput, optFns ...func(*dynamodbstreams.Options),
+	) (*dynamodbstreams.GetRecordsOutput, error)
+}
+
+// The DynamoDBStreamClient reads the changes of the CA items from the
+// DynamoDB Streams of the table. The stream of the table must be enabled with the
+// NEW_IMAGE or NEW_AND_OLD_IMAGES view type.
+type DynamoDBStreamClient struct {
+	table        dynamoDBTableAPI
+	streams      dynamoDBStreamsAPI
+	caName       *string
+	tableName    *string
+	pollInterval time.Duration
+	shardRefresh time.Duration
+}
+
+// Default values.
+const (
+	DefaultDynamoDBStreamPollInterval = time.Second
+	dynamoDBShardRefreshInterval      = time.Minute
+)
+
+var (
+	// ErrDynamoDBStreamDisabled is returned when the stream of the table is not enabled.
+	ErrDynamoDBStreamDisabled = errors.New("the stream of the table is not enabled")
+	// ErrDynamoDBStreamViewType is returned when the records of the stream do not have
+	// the new image of the items.
+	ErrDynamoDBStreamViewType = errors.New("the stream view type must be NEW_IMAGE or NEW_AND_OLD_IMAGES")
+)
+
+// WithPollInterval sets the interval of reading the records from the shards.
+// Default value is 1 second.
+func WithPollInterval(interval time.Duration) func(*DynamoDBStreamClient) {
+	return func(s *DynamoDBStreamClient) {
+		s.pollInterval = interval
+	}
+}
+
+// NewDynamoDBStreamClient creates and returns new DynamoDBStreamClient instance.
+func NewDynamoDBStreamClient(
+	table *dynamodb.Client,
+	streams *dynamodbstreams.Client,
+	caName *string,
+	tableName *string,
+	opts ...func(*DynamoDBStreamClient),
+) DynamoDBStreamClient {
+	s := DynamoDBStreamClient{
+		table:        table,
+		streams:      streams,
+		caName:       caName,
+		tableName:    tableName,
+		pollInterval: DefaultDynamoDBStreamPollInterval,
+		shardRefresh: dynamoDBShardRefreshInterval,
+	}
+
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	if s.pollInterval <= 0 {
+		s.pollInterval = DefaultDynamoDBStreamPollInterval
+	}
+
+	return s
+}
+
+// Watch reads the records of the stream and sends the changes of the CA items to
+// the changes channel, until the context is done or an error occurs.
+// The records written before Watch is called are not read, so the caller
+// should scan the table to reconcile them.
+func (s DynamoDBStreamClient) Watch(ctx context.Context, changes chan<- []EntryChange) error {
+	arn, err := s.streamARN(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := shardWatcher{
+		client:    s,
+		arn:       arn,
+		iterators: make(map[string]*string),
+		parents:   make(map[string]string),
+		seen:      make(map[string]struct{}),
+	}
+	if err := w.refreshShards(ctx, true); err != nil {
+		return err
+	}
+	lastRefresh := time.Now()
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		// New shards are created when the shards are closed or split
+		if w.shardClosed || time.Since(lastRefresh) >= s.shardRefresh {
+			if err := w.refreshShards(ctx, false); err != nil {
+				return err
+			}
+			w.shardClosed = false
+			lastRefresh = time.Now()
+		}
+
+		batch, err := w.poll(ctx)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		select {
+		case changes <- batch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s DynamoDBStreamClient) streamARN(ctx context.Context) (*string, error) {
+	out, err := s.table.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: s.tableName})
+	if err != nil {
+		return nil, err
+	}
+
+	spec := out.Table.StreamSpecification
+	if spec == nil || spec.StreamEnabled == nil || !*spec.StreamEnabled || out.Table.LatestStreamArn == nil {
+		return nil, ErrDynamoDBStreamDisabled
+	}
+
+	switch spec.StreamViewType {
+	case dynamodbtypes.StreamViewTypeNewImage, dynamodbtypes.StreamViewTypeNewAndOldImages:
+	default:
+		return nil, ErrDynamoDBStreamViewType
+	}
+
+	return out.Table.LatestStreamArn, nil
+}
+
+// shardWatcher holds the shard iterators of a stream.
+type shardWatcher struct {
+	client DynamoDBStreamClient
+	arn    *string
+	// Shard ID --> the next iterator of the shard that is being read
+	iterators map[string]*string
+	// Shard ID --> the parent shard ID
+	parents map[string]string
+	// Shard IDs that have been found
+	seen        map[string]struct{}
+	shardClosed bool
+}
+
+// refreshShards starts reading the shards that have not been found.
+// At the first time, only the open shards are read from the latest records.
+// After that, the new shards are read from the oldest records, because they
+// are created after the first time.
+func (w *shardWatcher) refreshShards(ctx context.Context, initial bool) error {
+	input := dynamodbstreams.DescribeStreamInput{StreamArn: w.arn}
+	for {
+		out, err := w.client.streams.DescribeStream(ctx, &input)
+		if err != nil {
+			return err
+		}
+
+		for _, shard := range out.StreamDescription.Shards {
+			if shard.ShardId == nil {
+				continue
+			}
+			if _, ok := w.seen[*shard.ShardId]; ok {
+				continue
+			}
+			w.seen[*shard.ShardId] = struct{}{}
+
+			iterType := types.ShardIteratorTypeTrimHorizon
+			if initial {
+				// The closed shards only have the records written before
+				if shard.SequenceNumberRange != nil && shard.SequenceNumberRange.EndingSequenceNumber != nil {
+					continue
+				}
+				iterType = types.ShardIteratorTypeLatest
+			}
+
+			iterOut, err := w.client.streams.GetShardIterator(ctx, &dynamodbstreams.GetShardIteratorInput{
+				StreamArn:         w.arn,
+				ShardId:           shard.ShardId,
+				ShardIteratorType: iterType,
+			})
+			if err != nil {
+				return err
+			}
+			if iterOut.ShardIterator == nil {
+				continue
+			}
+
+			w.iterators[*shard.ShardId] = iterOut.ShardIterator
+			if shard.ParentShardId != nil {
+				w.parents[*shard.ShardId] = *shard.ParentShardId
+			}
+		}
+
+		if out.StreamDescription.LastEvaluatedShardId == nil {
+			break
+		}
+		input.ExclusiveStartShardId = out.StreamDescription.LastEvaluatedShardId
+	}
+
+	return nil
+}
+
+// poll reads the records from the shards once, and returns the changes of the
+// CA items.
+func (w *shardWatcher) poll(ctx context.Context) ([]EntryChange, error) {
+	changes := make([]EntryChange, 0)
+	for shardID, iterator := range w.iterators {
+		// Read the child shard after the parent shard is read to the end
+		if parent, ok := w.parents[shardID]; ok {
+			if _, reading := w.iterators[parent]; reading {
+				continue
+			}
+		}
+
+		out, err := w.client.streams.GetRecords(ctx, &dynamodbstreams.GetRecordsInput{
+			ShardIterator: iterator,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for idx := range out.Records {
+			change, ok := w.client.recordChange(out.Records[idx])
+			if ok {
+				changes = append(changes, change)
+			}
+		}
+
+		if out.NextShardIterator == nil {
+			// The shard is closed and read to the end
+			delete(w.iterators, shardID)
+			delete(w.parents, shardID)
+			w.shardClosed = true
+			continue
+		}
+		w.iterators[shardID] = out.NextShardIterator
+	}
+
+	return changes, nil
+}
+
+// recordChange converts the stream record to the change of the CA item.
+// It returns false when the record is not for the CA or cannot be unmarshaled.
+func (s DynamoDBStreamClient) recordChange(record types.Record) (EntryChange, bool) {
+	var change EntryChange
+	if record.Dynamodb == nil {
+		return change, false
+	}
+
+	if record.EventName == types.OperationTypeRemove {
+		keys, err := attributevalue.FromDynamoDBStreamsMap(record.Dynamodb.Keys)
+		if err != nil {
+			return change, false
+		}
+		ca, err := unmarshalItem(keys, "ca")
+		if err != nil || ca != *s.caName {
+			return change, false
+		}
+		serial, err := unmarshalItem(keys, "serial")
+		if err != nil {
+			return change, false
+		}
+
+		change.Entry = IntermidiateEntry{Ca: ca, Serial: serial}
+		change.Removed = true
+		return change, true
+	}
+
+	item, err := attributevalue.FromDynamoDBStreamsMap(record.Dynamodb.NewImage)
+	if err != nil {
+		return change, false
+	}
+	entry, err := UnmarshalDynamoDBItem(item)
+	if err != nil || entry.Ca != *s.caName {
+		return change, false
+	}
+
+	change.Entry = entry
+	return change, true
+}
@@ -0,0 +1,269 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+	"github.com/google/go-cmp/cmp"
+)
+
+type stubDynamoDBTableAPI struct {
+	enabled  bool
+	viewType dynamodbtypes.StreamViewType
+}
+
+func (s stubDynamoDBTableAPI) DescribeTable(
+	_ context.Context, _ *dynamodb.DescribeTableInput, _ ...func(*dynamodb.Options),
+) (*dynamodb.DescribeTableOutput, error) {
+	return &dynamodb.DescribeTableOutput{
+		Table: &dynamodbtypes.TableDescription{
+			LatestStreamArn: aws.String("test-stream"),
+			StreamSpecification: &dynamodbtypes.StreamSpecification{
+				StreamEnabled:  aws.Bool(s.enabled),
+				StreamViewType: s.viewType,
+			},
+		},
+	}, nil
+}
+
+// stubDynamoDBStreamsAPI returns the pages of the records of the shards in order.
+// The closing shards are closed after their last pages are read, and the child
+// shards are described after their parent shards are closed.
+type stubDynamoDBStreamsAPI struct {
+	shards    []types.Shard
+	records   map[string][][]types.Record
+	closing   map[string]bool
+	closed    map[string]bool
+	iterTypes map[string]types.ShardIteratorType
+	mu        sync.Mutex
+}
+
+func (s *stubDynamoDBStreamsAPI) DescribeStream(
+	_ context.Context, _ *dynamodbstreams.DescribeStreamInput, _ ...func(*dynamodbstreams.Options),
+) (*dynamodbstreams.DescribeStreamOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shards := make([]types.Shard, 0, len(s.shards))
+	for _, shard := range s.shards {
+		if shard.ParentShardId != nil && !s.closed[*shard.ParentShardId] {
+			continue
+		}
+		shards = append(shards, shard)
+	}
+
+	return &dynamodbstreams.DescribeStreamOutput{
+		StreamDescription: &types.StreamDescription{Shards: shards},
+	}, nil
+}
+
+func (s *stubDynamoDBStreamsAPI) GetShardIterator(
+	_ context.Context, params *dynamodbstreams.GetShardIteratorInput, _ ...func(*dynamodbstreams.Options),
+) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.iterTypes[*params.ShardId] = params.ShardIteratorType
+	return &dynamodbstreams.GetShardIteratorOutput{
+		ShardIterator: aws.String(*params.ShardId + "#0"),
+	}, nil
+}
+
+func (s *stubDynamoDBStreamsAPI) GetRecords(
+	_ context.Context, params *dynamodbstreams.GetRecordsInput, _ ...func(*dynamodbstreams.Options),
+) (*dynamodbstreams.GetRecordsOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shardID, pageStr, _ := strings.Cut(*params.ShardIterator, "#")
+	page, err := strconv.Atoi(pageStr)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := s.records[shardID]
+	if page >= len(pages) {
+		// The open shard has no new records
+		return &dynamodbstreams.GetRecordsOutput{NextShardIterator: params.ShardIterator}, nil
+	}
+
+	out := dynamodbstreams.GetRecordsOutput{Records: pages[page]}
+	if page+1 == len(pages) && s.closing[shardID] {
+		s.closed[shardID] = true
+		return &out, nil
+	}
+	out.NextShardIterator = aws.String(fmt.Sprintf("%s#%d", shardID, page+1))
+
+	return &out, nil
+}
+
+func testStreamShard(id string, parent *string, closed bool) types.Shard {
+	shard := types.Shard{
+		ShardId:             aws.String(id),
+		ParentShardId:       parent,
+		SequenceNumberRange: &types.SequenceNumberRange{StartingSequenceNumber: aws.String("1")},
+	}
+	if closed {
+		shard.SequenceNumberRange.EndingSequenceNumber = aws.String("9")
+	}
+	return shard
+}
+
+func testStreamItem(ca, serial string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"ca":         &types.AttributeValueMemberS{Value: ca},
+		"serial":     &types.AttributeValueMemberS{Value: serial},
+		"rev_type":   &types.AttributeValueMemberS{Value: "V"},
+		"exp_date":   &types.AttributeValueMemberS{Value: "330823234911Z"},
+		"rev_date":   &types.AttributeValueMemberS{Value: ""},
+		"crl_reason": &types.AttributeValueMemberS{Value: ""},
+	}
+}
+
+func testStreamRecord(event types.OperationType, ca, serial string) types.Record {
+	item := testStreamItem(ca, serial)
+	record := types.Record{
+		EventName: event,
+		Dynamodb: &types.StreamRecord{
+			Keys: map[string]types.AttributeValue{"ca": item["ca"], "serial": item["serial"]},
+		},
+	}
+	if event != types.OperationTypeRemove {
+		record.Dynamodb.NewImage = item
+	}
+	return record
+}
+
+func testDynamoDBStreamClient(table dynamoDBTableAPI, streams dynamoDBStreamsAPI) DynamoDBStreamClient {
+	ca := "test-ca"
+	tableName := "test-table"
+	return DynamoDBStreamClient{
+		table:        table,
+		streams:      streams,
+		caName:       &ca,
+		tableName:    &tableName,
+		pollInterval: time.Millisecond,
+		shardRefresh: time.Minute,
+	}
+}
+
+func TestDynamoDBStreamClient_Watch(t *testing.T) {
+	t.Parallel()
+
+	invalid := testStreamRecord(types.OperationTypeModify, "test-ca", "03")
+	delete(invalid.Dynamodb.NewImage, "rev_type")
+
+	streams := &stubDynamoDBStreamsAPI{
+		shards: []types.Shard{
+			testStreamShard("closed-0", nil, true),
+			testStreamShard("parent-1", nil, false),
+			testStreamShard("child-2", aws.String("parent-1"), false),
+		},
+		records: map[string][][]types.Record{
+			"closed-0": {{testStreamRecord(types.OperationTypeInsert, "test-ca", "00")}},
+			"parent-1": {
+				{
+					testStreamRecord(types.OperationTypeInsert, "test-ca", "01"),
+					testStreamRecord(types.OperationTypeInsert, "other-ca", "02"),
+					invalid,
+				},
+				{testStreamRecord(types.OperationTypeRemove, "test-ca", "04")},
+			},
+			"child-2": {{testStreamRecord(types.OperationTypeModify, "test-ca", "05")}},
+		},
+		closing:   map[string]bool{"parent-1": true},
+		closed:    make(map[string]bool),
+		iterTypes: make(map[string]types.ShardIteratorType),
+	}
+
+	client := testDynamoDBStreamClient(
+		stubDynamoDBTableAPI{true, dynamodbtypes.StreamViewTypeNewAndOldImages}, streams,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changesCh := make(chan []EntryChange)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.Watch(ctx, changesCh)
+	}()
+
+	got := make([]EntryChange, 0, 3)
+	timeout := time.After(5 * time.Second)
+	for len(got) < 3 {
+		select {
+		case batch := <-changesCh:
+			got = append(got, batch...)
+		case <-timeout:
+			t.Fatalf("Changes are not received: %v", got)
+		}
+	}
+	cancel()
+
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected error is %#v but got: %#v", context.Canceled, err)
+	}
+
+	removed := IntermidiateEntry{Ca: "test-ca", Serial: "04"}
+	want := []EntryChange{
+		{Entry: testIntermidiateEntry("test-ca", "01")},
+		{Entry: removed, Removed: true},
+		{Entry: testIntermidiateEntry("test-ca", "05")},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Error(diff)
+	}
+
+	wantIterTypes := map[string]types.ShardIteratorType{
+		"parent-1": types.ShardIteratorTypeLatest,
+		"child-2":  types.ShardIteratorTypeTrimHorizon,
+	}
+	if diff := cmp.Diff(streams.iterTypes, wantIterTypes); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDynamoDBStreamClient_Watch_Error(t *testing.T) {
+	t.Parallel()
+
+	data := []struct {
+		testcase string
+		table    stubDynamoDBTableAPI
+		err      error
+	}{
+		{
+			"stream is disabled",
+			stubDynamoDBTableAPI{false, ""},
+			ErrDynamoDBStreamDisabled,
+		},
+		{
+			"stream has no new image",
+			stubDynamoDBTableAPI{true, dynamodbtypes.StreamViewTypeKeysOnly},
+			ErrDynamoDBStreamViewType,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			client := testDynamoDBStreamClient(d.table, &stubDynamoDBStreamsAPI{})
+			err := client.Watch(context.Background(), make(chan []EntryChange))
+			if !errors.Is(err, d.err) {
+				t.Errorf("Expected error is %#v but got: %#v", d.err, err)
+			}
+		})
+	}
+}
@@ -245,6 +245,7 @@ func (e *EntryExchange) ParseCertificateEntry(
 		ExpDate:   expDate,
 		RevDate:   revDate,
 		CRLReason: crlReason,
+		Subject:   itmdEntry.Subject,
 		Errors:    verifyErros,
 	}
 }
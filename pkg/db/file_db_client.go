@@ -1,29 +1,91 @@
 package db
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"regexp"
 	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
 )
 
 // FileDBClient is an implementation of the CADBClient interface. It scans the
 // certificate revocation status from a DB file.
-// The DB file format is based on the index file of 'https://github.com/openssl/openssl'.
+// The DB file format is the index file of 'https://github.com/openssl/openssl',
+// which has six tab delimited columns. The lines end with LF or CRLF, and the
+// lines starting with '#' and the blank lines are skipped. The lines of the expired status "E" are verified, but they
+// are not returned, because no response is served for the expired certificates.
+// If the attribute file, which has the name of the DB file with FileDBAttrSuffix,
+// exists, its attributes are also applied.
 type FileDBClient struct {
 	caName string
 	dbFile string
+	// Options
+	watch    bool
+	debounce time.Duration
+}
+
+// WithFileWatch enables the watch of the DB file with WatchUpdates. The changes
+// within the debounce duration of each other are notified once. If 0 or less
+// than 0 is set, DefaultWatchDebounce is used. While the watch is enabled, Scan
+// rejects the DB file that is partially written.
+func WithFileWatch(debounce time.Duration) func(*FileDBClient) {
+	return func(h *FileDBClient) {
+		h.watch = true
+		h.debounce = debounce
+	}
 }
 
 // NewFileDBClient creates and returns a new instance of FileDBClient.
-func NewFileDBClient(caName string, dbFile string) FileDBClient {
-	return FileDBClient{
+func NewFileDBClient(caName string, dbFile string, opts ...func(*FileDBClient)) FileDBClient {
+	h := FileDBClient{
 		caName: caName,
 		dbFile: dbFile,
 	}
+
+	for _, opt := range opts {
+		opt(&h)
+	}
+
+	if h.debounce <= 0 {
+		h.debounce = DefaultWatchDebounce
+	}
+
+	return h
+}
+
+// FileDBIncompleteError is used when the DB file seems to be partially written.
+type FileDBIncompleteError struct {
+	file string
+	msg  string
+}
+
+func (e FileDBIncompleteError) Error() string {
+	return fmt.Sprintf("incomplete file DB %s: %s", e.file, e.msg)
 }
 
+// FileDBParseError is used when a line of the DB file or the attribute file
+// does not follow the format.
+type FileDBParseError struct {
+	file string
+	line int
+	msg  string
+}
+
+func (e FileDBParseError) Error() string {
+	return fmt.Sprintf("invalid file DB %s: line %d: %s", e.file, e.line, e.msg)
+}
+
+// FileDBAttrSuffix is the suffix of the attribute file of the DB file.
+const FileDBAttrSuffix = ".attr"
+
+// fileDBExpired is the status of the expired certificates in the DB file.
+const fileDBExpired = "E"
+
 // Indexes of tab delimited columns in DB file.
 const (
 	// Revocation Type.
@@ -34,6 +96,12 @@ const (
 	FileDBColRevDateAndCRLReasonIdx int = 2
 	// Serial Number.
 	FileDBColSerialIdx int = 3
+	// File Name, which is "unknown" unless the certificate is written to a file.
+	FileDBColFileNameIdx int = 4
+	// Subject DN.
+	FileDBColSubjectIdx int = 5
+	// Number of columns.
+	FileDBColNum int = 6
 )
 
 // Indexes of comma delimited RevDate and CRLReason.
@@ -42,50 +110,245 @@ const (
 	IdxRevDate int = 0
 	// CRL Reason.
 	IdxCRLReason int = 1
+	// Hold instruction or compromise time of the OpenSSL specific reasons.
+	IdxCRLReasonArg int = 2
 )
 
-// Scan reads a file and parses each line into an IntermediateEntry.
-func (h FileDBClient) Scan(ctx context.Context) (entries []IntermidiateEntry, err error) {
-	file, err := os.Open(h.dbFile)
+// Values of the OpenSSL specific CRL reasons, which are followed by the argument.
+const (
+	// certificateHold with the hold instruction.
+	HoldInstructionValue = "holdInstruction"
+	// keyCompromise with the compromise time.
+	KeyTimeValue = "keyTime"
+	// CACompromise with the compromise time.
+	CAKeyTimeValue = "CAkeyTime"
+)
+
+// Names of the hold instructions (RFC 5280: 5.3.2.), in addition to the OIDs.
+var holdInstructionNames = map[string]struct{}{
+	"holdInstructionNone":       {},
+	"holdInstructionCallIssuer": {},
+	"holdInstructionReject":     {},
+}
+
+var oidRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)+$`)
+
+// fileDBAttr is the attributes of the DB file.
+type fileDBAttr struct {
+	// The subjects of the certificates that are not revoked must be unique
+	uniqueSubject bool
+}
+
+// verifyComplete returns an error if the DB file seems to be partially written,
+// which means that the last line is not terminated by a newline.
+func (h FileDBClient) verifyComplete(data []byte) error {
+	if len(data) != 0 && data[len(data)-1] != '\n' {
+		return FileDBIncompleteError{file: h.dbFile, msg: "the last line is not terminated"}
+	}
+
+	return nil
+}
+
+// readAttr reads the attribute file. If the file does not exist, the default
+// attributes are returned. The unknown attributes are ignored.
+func (h FileDBClient) readAttr() (fileDBAttr, error) {
+	var attr fileDBAttr
+
+	attrFile := h.dbFile + FileDBAttrSuffix
+	data, err := os.ReadFile(attrFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return attr, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("could not read file DB %s: %w", h.dbFile, err)
+		return attr, fmt.Errorf("could not read file DB attributes %s: %w", attrFile, err)
 	}
-	defer func() {
-		closeErr := file.Close()
-		if err == nil {
-			err = closeErr
+
+	for idx, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
 		}
-	}()
-
-	scanner := bufio.NewScanner(file)
-	entries = make([]IntermidiateEntry, 0)
-
-	for scanner.Scan() {
-		var entry IntermidiateEntry
-		entry.Ca = h.caName
-
-		s := scanner.Text()
-		cols := strings.Split(s, "\t")
-
-		for idx := range cols {
-			switch idx {
-			case FileDBColRevTypeIdx:
-				entry.RevType = cols[idx]
-			case FileDBColExpDateIdx:
-				entry.ExpDate = cols[idx]
-			case FileDBColRevDateAndCRLReasonIdx:
-				rc := strings.SplitN(cols[idx], ",", IdxCRLReason+1)
-				entry.RevDate = rc[IdxRevDate]
-				if len(rc) == IdxCRLReason+1 {
-					entry.CRLReason = rc[IdxCRLReason]
-				}
-			case FileDBColSerialIdx:
-				entry.Serial = cols[idx]
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return attr, FileDBParseError{file: attrFile, line: idx + 1, msg: "attribute must be 'name = value'"}
+		}
+
+		if strings.TrimSpace(key) == "unique_subject" {
+			switch strings.ToLower(strings.TrimSpace(value)) {
+			case "yes", "y", "true", "t", "1":
+				attr.uniqueSubject = true
+			case "no", "n", "false", "f", "0":
+				attr.uniqueSubject = false
 			default:
+				return attr, FileDBParseError{
+					file: attrFile, line: idx + 1, msg: fmt.Sprintf("unique_subject must be yes or no: %q", value),
+				}
 			}
 		}
+	}
+
+	return attr, nil
+}
+
+// parseRevocation parses the comma delimited Revocation Date and CRL Reason.
+// The OpenSSL specific reasons are converted to the CRL reasons, after their
+// arguments are verified. The arguments, which are the hold instruction and the
+// compromise time, are ignored, because the responses do not include them.
+func parseRevocation(col string) (string, string, error) {
+	vals := strings.Split(col, ",")
+	if vals[IdxRevDate] == "" {
+		return "", "", errors.New("revocation date is empty")
+	}
+	if len(vals) > IdxCRLReasonArg+1 {
+		return "", "", fmt.Errorf("too many values of revocation: %q", col)
+	}
+	if len(vals) == IdxCRLReason+1 && vals[IdxCRLReason] == "" {
+		return "", "", errors.New("CRL reason is empty")
+	}
+	if len(vals) == IdxRevDate+1 {
+		return vals[IdxRevDate], "", nil
+	}
+
+	reason := vals[IdxCRLReason]
+	var arg string
+	if len(vals) == IdxCRLReasonArg+1 {
+		arg = vals[IdxCRLReasonArg]
+	}
+
+	switch reason {
+	case HoldInstructionValue, CertificateHolValue:
+		if reason == HoldInstructionValue && arg == "" {
+			return "", "", errors.New("hold instruction is missing")
+		}
+		if _, ok := holdInstructionNames[arg]; arg != "" && !ok && !oidRegexp.MatchString(arg) {
+			return "", "", fmt.Errorf("invalid hold instruction: %q", arg)
+		}
+		return vals[IdxRevDate], CertificateHolValue, nil
+	case KeyTimeValue, CAKeyTimeValue:
+		if _, err := time.Parse(ASN1GeneralizedTime, arg); err != nil {
+			return "", "", fmt.Errorf("invalid compromise time: %q", arg)
+		}
+		if reason == KeyTimeValue {
+			return vals[IdxRevDate], KeyCompromisValue, nil
+		}
+		return vals[IdxRevDate], CACompromisValue, nil
+	}
+
+	if arg != "" {
+		return "", "", fmt.Errorf("CRL reason %s does not take argument: %q", reason, arg)
+	}
+
+	exch := NewEntryExchange()
+	if _, err := exch.VerifyCRLReason(reason); err != nil {
+		return "", "", fmt.Errorf("undefined CRL reason: %q", reason)
+	}
+
+	return vals[IdxRevDate], reason, nil
+}
+
+// parseLine parses a line of the DB file into an IntermediateEntry.
+func (h FileDBClient) parseLine(line string) (IntermidiateEntry, error) {
+	entry := IntermidiateEntry{Ca: h.caName}
+
+	cols := strings.Split(line, "\t")
+	if len(cols) != FileDBColNum {
+		return entry, fmt.Errorf("%d columns, %d required", len(cols), FileDBColNum)
+	}
+
+	entry.RevType = cols[FileDBColRevTypeIdx]
+	entry.ExpDate = cols[FileDBColExpDateIdx]
+	entry.Serial = cols[FileDBColSerialIdx]
+	entry.Subject = cols[FileDBColSubjectIdx]
+
+	if entry.Serial == "" {
+		return entry, errors.New("serial number is empty")
+	}
+
+	switch entry.RevType {
+	case string(Valid), fileDBExpired:
+		if cols[FileDBColRevDateAndCRLReasonIdx] != "" {
+			return entry, fmt.Errorf("revocation exists but status is %s", entry.RevType)
+		}
+	case string(Revoked):
+		revDate, reason, err := parseRevocation(cols[FileDBColRevDateAndCRLReasonIdx])
+		if err != nil {
+			return entry, err
+		}
+		entry.RevDate = revDate
+		entry.CRLReason = reason
+	default:
+		return entry, fmt.Errorf("undefined status: %q", entry.RevType)
+	}
+
+	return entry, nil
+}
+
+// Scan reads a file and parses each line into an IntermediateEntry. The lines
+// of the expired status "E" are skipped, so that the entries have only the
+// statuses accepted by EntryExchange.VerifyRevType. If a line does not follow
+// the format, it returns FileDBParseError with the line number.
+// If the subjects must be unique, the line whose subject is not unique is
+// logged with the logger of the context, and skipped. OpenSSL rejects the
+// duplicate subject when the certificate is issued, so it is not a format
+// error of the DB file.
+// If the watch is enabled and the file seems to be partially written, it
+// returns FileDBIncompleteError.
+func (h FileDBClient) Scan(ctx context.Context) ([]IntermidiateEntry, error) {
+	data, err := os.ReadFile(h.dbFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file DB %s: %w", h.dbFile, err)
+	}
+
+	if h.watch {
+		if err := h.verifyComplete(data); err != nil {
+			return nil, err
+		}
+	}
+
+	attr, err := h.readAttr()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]IntermidiateEntry, 0)
+	// The line numbers of the subjects of the certificates that are not revoked
+	subjects := make(map[string]int)
+
+	for idx, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry, err := h.parseLine(line)
+		if err != nil {
+			return nil, FileDBParseError{file: h.dbFile, line: idx + 1, msg: err.Error()}
+		}
+
+		// As OpenSSL, the expired certificates are not unique subjects
+		if entry.RevType == fileDBExpired {
+			continue
+		}
+
+		if attr.uniqueSubject && entry.RevType != string(Revoked) {
+			if dup, ok := subjects[entry.Subject]; ok {
+				zerolog.Ctx(ctx).Warn().Str("file", h.dbFile).Int("line", idx+1).Str("serial", entry.Serial).
+					Msgf("Subject is not unique with line %d, the entry is skipped.", dup)
+				continue
+			}
+			subjects[entry.Subject] = idx + 1
+		}
+
 		entries = append(entries, entry)
 	}
 
 	return entries, nil
 }
+
+// WatchUpdates watches the DB file and its attribute file until the context is
+// done or an error occurs, and sends to the channel when the files are written,
+// or replaced by rename.
+func (h FileDBClient) WatchUpdates(ctx context.Context, updated chan<- struct{}) error {
+	return watchFiles(ctx, []string{h.dbFile, h.dbFile + FileDBAttrSuffix}, nil, h.debounce, updated)
+}
@@ -2,7 +2,13 @@ package db
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -30,6 +36,7 @@ func TestFileDBClient_Scan(t *testing.T) {
 					"230925234911Z",
 					"",
 					"",
+					"/C=US/O=Example Organization/CN=Sub CA OCSP Responder",
 				},
 				{
 					"test-ca",
@@ -38,6 +45,7 @@ func TestFileDBClient_Scan(t *testing.T) {
 					"330823234911Z",
 					"230826234911Z",
 					"unspecified",
+					"/C=US/O=Example Organization/CN=unknown",
 				},
 				{
 					"test-ca",
@@ -46,15 +54,9 @@ func TestFileDBClient_Scan(t *testing.T) {
 					"330823234911Z",
 					"",
 					"",
+					"/C=US/O=Example Organization/CN=good",
 				},
-				{
-					"test-ca",
-					"8CA7B3FE5D7F007673C18CCC6A1F818085CDC5F7",
-					"E",
-					"19000914235323Z",
-					"",
-					"",
-				},
+				// The expired entry is skipped
 				{
 					"test-ca",
 					"1984",
@@ -62,14 +64,43 @@ func TestFileDBClient_Scan(t *testing.T) {
 					"250717054417Z",
 					"240717082249Z",
 					"",
+					"/C=XX/ST=XXX/L=XXXX/O=XXXXX/OU=XX/CN=XY/emailAddress=X@X",
 				},
-				{ // Not tab delimited
+				{ // Hold instruction
 					"test-ca",
-					"",
-					"V  330823234911Z    1F8ACD3265E5BA098DEC495EECE41C11BA093463  unknown  /C=US/O=Example Organization/CN=good",
-					"",
-					"",
-					"",
+					"3A",
+					"R",
+					"330823234911Z",
+					"230826234911Z",
+					"certificateHold",
+					"/C=US/O=Example Organization/CN=hold",
+				},
+				{
+					"test-ca",
+					"3B",
+					"R",
+					"330823234911Z",
+					"230826234911Z",
+					"certificateHold",
+					"/C=US/O=Example Organization/CN=hold",
+				},
+				{ // Compromise time
+					"test-ca",
+					"3C",
+					"R",
+					"330823234911Z",
+					"230826234911Z",
+					"keyCompromise",
+					"/C=US/O=Example Organization/CN=key",
+				},
+				{
+					"test-ca",
+					"3D",
+					"R",
+					"330823234911Z",
+					"230826234911Z",
+					"CACompromise",
+					"/C=US/O=Example Organization/CN=key",
 				},
 			},
 			"",
@@ -108,3 +139,313 @@ func TestFileDBClient_Scan(t *testing.T) {
 		})
 	}
 }
+
+func TestFileDBClient_Scan_Errors(t *testing.T) {
+	t.Parallel()
+
+	valid := "V\t330925234911Z\t\t01\tunknown\t/CN=good\n"
+	data := []struct {
+		testcase string
+		content  string
+		attr     string
+		// want
+		errMsg string
+	}{
+		{"not tab delimited", valid + "V  330925234911Z    02  unknown  /CN=good\n", "", "line 2: 1 columns, 6 required"},
+		{"four columns", "# comment\nV\t330925234911Z\t\t02\n", "", "line 2: 4 columns, 6 required"},
+		{"serial is empty", "V\t330925234911Z\t\t\tunknown\t/CN=good\n", "", "line 1: serial number is empty"},
+		{"undefined status", "X\t330925234911Z\t\t02\tunknown\t/CN=good\n", "", `line 1: undefined status: "X"`},
+		{
+			"valid with revocation", "V\t330925234911Z\t230925234911Z\t02\tunknown\t/CN=good\n", "",
+			"line 1: revocation exists but status is V",
+		},
+		{
+			"revoked without revocation date", "R\t330925234911Z\t,keyCompromise\t02\tunknown\t/CN=bad\n", "",
+			"line 1: revocation date is empty",
+		},
+		{
+			"empty CRL reason", "R\t330925234911Z\t230925234911Z,\t02\tunknown\t/CN=bad\n", "",
+			"line 1: CRL reason is empty",
+		},
+		{
+			"undefined CRL reason", "R\t330925234911Z\t230925234911Z,stolen\t02\tunknown\t/CN=bad\n", "",
+			`line 1: undefined CRL reason: "stolen"`,
+		},
+		{
+			"argument of CRL reason", "R\t330925234911Z\t230925234911Z,superseded,x\t02\tunknown\t/CN=bad\n", "",
+			`line 1: CRL reason superseded does not take argument: "x"`,
+		},
+		{
+			"hold instruction is missing", "R\t330925234911Z\t230925234911Z,holdInstruction\t02\tunknown\t/CN=bad\n", "",
+			"line 1: hold instruction is missing",
+		},
+		{
+			"invalid hold instruction",
+			"R\t330925234911Z\t230925234911Z,certificateHold,wait\t02\tunknown\t/CN=bad\n", "",
+			`line 1: invalid hold instruction: "wait"`,
+		},
+		{
+			"invalid compromise time", "R\t330925234911Z\t230925234911Z,keyTime,yesterday\t02\tunknown\t/CN=bad\n", "",
+			`line 1: invalid compromise time: "yesterday"`,
+		},
+		{"invalid attribute", valid, "unique_subject\n", "line 1: attribute must be 'name = value'"},
+		{"invalid unique_subject", valid, "unique_subject = maybe\n", `line 1: unique_subject must be yes or no: " maybe"`},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			file := filepath.Join(t.TempDir(), "index.txt")
+			if err := os.WriteFile(file, []byte(d.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if d.attr != "" {
+				if err := os.WriteFile(file+FileDBAttrSuffix, []byte(d.attr), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			client := NewFileDBClient("test-ca", file)
+			_, err := client.Scan(context.Background())
+
+			var parseErr FileDBParseError
+			if !errors.As(err, &parseErr) || !strings.HasSuffix(err.Error(), d.errMsg) {
+				t.Fatalf("Expected error message ends with '%s' but got: %v", d.errMsg, err)
+			}
+		})
+	}
+
+	t.Run("subject is unique without attributes", func(t *testing.T) {
+		t.Parallel()
+
+		file := filepath.Join(t.TempDir(), "index.txt")
+		if err := os.WriteFile(file, []byte(valid+"V\t330925234911Z\t\t02\tunknown\t/CN=good\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := NewFileDBClient("test-ca", file).Scan(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 {
+			t.Errorf("Expected 2 entries but got: %d", len(entries))
+		}
+	})
+
+	t.Run("subject is not unique", func(t *testing.T) {
+		t.Parallel()
+
+		content := valid + "R\t330925234911Z\t230925234911Z\t02\tunknown\t/CN=good\n\n" +
+			"V\t330925234911Z\t\t03\tunknown\t/CN=good\n"
+		file := filepath.Join(t.TempDir(), "index.txt")
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file+FileDBAttrSuffix, []byte("# attributes\nunique_subject = yes\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		// The duplicate is skipped, and the others are scanned
+		entries, err := NewFileDBClient("test-ca", file).Scan(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 || entries[0].Serial != "01" || entries[1].Serial != "02" {
+			t.Errorf("Expected the entries of 01 and 02 but got: %v", entries)
+		}
+	})
+
+	t.Run("lines end with CRLF", func(t *testing.T) {
+		t.Parallel()
+
+		content := strings.ReplaceAll(valid+"R\t330925234911Z\t230925234911Z,keyCompromise\t02\tunknown\t/CN=bad\n",
+			"\n", "\r\n")
+		file := filepath.Join(t.TempDir(), "index.txt")
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := NewFileDBClient("test-ca", file).Scan(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 || entries[0].Subject != "/CN=good" || entries[1].Subject != "/CN=bad" {
+			t.Errorf("Expected the subjects without CR but got: %v", entries)
+		}
+	})
+
+	t.Run("expired subject is not unique", func(t *testing.T) {
+		t.Parallel()
+
+		file := filepath.Join(t.TempDir(), "index.txt")
+		if err := os.WriteFile(file, []byte("E\t200925234911Z\t\t02\tunknown\t/CN=good\n"+valid), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file+FileDBAttrSuffix, []byte("unique_subject = yes\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := NewFileDBClient("test-ca", file).Scan(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].RevType != "V" {
+			t.Errorf("Expected the valid entry only but got: %v", entries)
+		}
+	})
+}
+
+func TestFileDBClient_Scan_Watch(t *testing.T) {
+	t.Parallel()
+
+	data := []struct {
+		testcase string
+		content  string
+		// want
+		entries int
+		errMsg  string
+	}{
+		{
+			"complete file",
+			"V\t330925234911Z\t\t01\tunknown\t/CN=good\nR\t330925234911Z\t230925234911Z,keyCompromise\t02\tunknown\t/CN=bad\n",
+			2, "",
+		},
+		{"empty file", "", 0, ""},
+		{
+			"last line is not terminated",
+			"V\t330925234911Z\t\t01\tunknown\t/CN=good\nR\t330925234911Z\t230925234911Z",
+			0, "the last line is not terminated",
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			file := filepath.Join(t.TempDir(), "index.txt")
+			if err := os.WriteFile(file, []byte(d.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			client := NewFileDBClient("test-ca", file, WithFileWatch(0))
+			entries, err := client.Scan(context.Background())
+			if d.errMsg != "" {
+				var incomplete FileDBIncompleteError
+				if !errors.As(err, &incomplete) || !strings.HasSuffix(err.Error(), d.errMsg) {
+					t.Fatalf("Expected error message ends with '%s' but got: %v", d.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(entries) != d.entries {
+				t.Errorf("Expected %d entries but got: %d", d.entries, len(entries))
+			}
+		})
+	}
+}
+
+func TestFileDBClient_WatchUpdates(t *testing.T) {
+	t.Parallel()
+
+	data := []struct {
+		testcase string
+		update   func(t *testing.T, file string)
+	}{
+		{
+			"write in place",
+			func(t *testing.T, file string) {
+				t.Helper()
+				if err := os.WriteFile(file, []byte("V\t330925234911Z\t\t02\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			"replace by rename",
+			func(t *testing.T, file string) {
+				t.Helper()
+				tmp := file + ".tmp"
+				if err := os.WriteFile(tmp, []byte("V\t330925234911Z\t\t02\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Rename(tmp, file); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			"burst of writes",
+			func(t *testing.T, file string) {
+				t.Helper()
+				for i := range 5 {
+					line := fmt.Sprintf("V\t330925234911Z\t\t%02d\n", i)
+					if err := os.WriteFile(file, []byte(line), 0o600); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			file := filepath.Join(dir, "index.txt")
+			if err := os.WriteFile(file, []byte("V\t330925234911Z\t\t01\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			debounce := 200 * time.Millisecond
+			client := NewFileDBClient("test-ca", file, WithFileWatch(debounce))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			updated := make(chan struct{}, 10)
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- client.WatchUpdates(ctx, updated)
+			}()
+
+			// The other files in the directory are ignored
+			time.Sleep(debounce)
+			if err := os.WriteFile(filepath.Join(dir, "other.txt"), []byte("other"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case <-updated:
+				t.Fatal("Update of the other file is notified.")
+			case <-time.After(debounce * 2):
+			}
+			d.update(t, file)
+
+			select {
+			case <-updated:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Update is not notified.")
+			}
+
+			// The burst must be notified once
+			select {
+			case <-updated:
+				t.Error("Update is notified more than once.")
+			case <-time.After(debounce * 3):
+			}
+
+			cancel()
+			select {
+			case err := <-errCh:
+				if err != nil {
+					t.Errorf("Expected nil when the context is done but got: %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("WatchUpdates did not return.")
+			}
+		})
+	}
+}
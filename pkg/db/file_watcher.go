@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultWatchDebounce is the default duration to wait for the burst of the
+// changes of the watched files to end.
+const DefaultWatchDebounce = time.Second
+
+var ErrWatcherClosed = errors.New("file watcher is closed")
+
+// watchFiles watches the files and the files in the directories until the
+// context is done or an error occurs, and sends to the channel when any of the
+// files is written, created, renamed or removed. The directories of the files
+// are watched, so that the files atomically replaced by rename are also watched.
+// The changes within the debounce duration of each other are notified once.
+func watchFiles(
+	ctx context.Context, files []string, dirs []string, debounce time.Duration, updated chan<- struct{},
+) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("could not create file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	targets := make(map[string]struct{}, len(files))
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		targets[abs] = struct{}{}
+
+		if err := watcher.Add(filepath.Dir(abs)); err != nil {
+			return fmt.Errorf("could not watch %s: %w", file, err)
+		}
+	}
+
+	dirTargets := make(map[string]struct{}, len(dirs))
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		dirTargets[abs] = struct{}{}
+
+		if err := watcher.Add(abs); err != nil {
+			return fmt.Errorf("could not watch %s: %w", dir, err)
+		}
+	}
+
+	// The timer is started by the first change of a burst
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return ErrWatcherClosed
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			_, ok = targets[filepath.Clean(event.Name)]
+			if _, inDir := dirTargets[filepath.Dir(event.Name)]; !ok && !inDir {
+				continue
+			}
+			timer.Reset(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return ErrWatcherClosed
+			}
+			return fmt.Errorf("file watcher failed: %w", err)
+		case <-timer.C:
+			select {
+			case updated <- struct{}{}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,490 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPFields is the mapping of the JSON paths of the responses to the attributes
+// of IntermidiateEntry. The paths are in the dot notation, such as "data.items",
+// "$.data.items" or "status.0.type". Items is the path to the array of the
+// items from the root of the response, and "$" or the empty path is the root
+// itself. The other paths are relative to each item. If the path of CA is
+// empty, all items are the entries of the CA. Otherwise, the item without the
+// CA fails the scan, so that the items are not dropped silently.
+type HTTPFields struct {
+	Items     string
+	CA        string
+	Serial    string
+	RevType   string
+	ExpDate   string
+	RevDate   string
+	CRLReason string
+	Subject   string
+}
+
+// DefaultHTTPFields is the mapping of the fields that have the same names as
+// the attributes of the DynamoDB items.
+var DefaultHTTPFields = HTTPFields{
+	Items:     "items",
+	CA:        "ca",
+	Serial:    "serial",
+	RevType:   "rev_type",
+	ExpDate:   "exp_date",
+	RevDate:   "rev_date",
+	CRLReason: "crl_reason",
+	Subject:   "subject",
+}
+
+// HTTPPagination is the type of the pagination of the endpoint.
+type HTTPPagination string
+
+const (
+	// All items are in a response.
+	HTTPNoPagination HTTPPagination = "none"
+	// The next page is requested with the cursor of the previous response.
+	HTTPCursorPagination HTTPPagination = "cursor"
+	// The next page is requested with the offset of the items.
+	HTTPOffsetPagination HTTPPagination = "offset"
+)
+
+// Default values of HTTPDBClient.
+const (
+	// The number of seconds for timeout of a request.
+	DefaultHTTPTimeout = 60
+	// The number of retries of a request.
+	DefaultHTTPRetries = 3
+	// The wait before the first retry, which is doubled for each retry.
+	DefaultHTTPRetryWait = time.Second
+	// The number of items of a page of the offset pagination.
+	DefaultHTTPLimit = 100
+)
+
+var (
+	ErrHTTPURLInvalid   = errors.New("URL must start with 'http://' or 'https://'")
+	ErrHTTPCursorLooped = errors.New("next cursor is the same as a previous one")
+)
+
+// HTTPStatusError is used when the endpoint responds with an unexpected status.
+type HTTPStatusError struct {
+	url  string
+	code int
+}
+
+func (e HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d from %s", e.code, e.url)
+}
+
+// retryable returns true if the status may be recovered by a retry.
+func (e HTTPStatusError) retryable() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= http.StatusInternalServerError
+}
+
+// HTTPDBClient is an implementation of the CADBClient interface. It scans the
+// certificate revocation status from a JSON HTTP endpoint, such as an inventory
+// service of the CA. The pages of the endpoint are requested in order, and the
+// values of the items are mapped to the entries with the JSON paths.
+type HTTPDBClient struct {
+	client  *http.Client
+	url     *url.URL
+	caName  string
+	timeout int
+	// Options
+	fields       HTTPFields
+	pagination   HTTPPagination
+	cursorParam  string
+	nextCursor   string
+	offsetParam  string
+	limitParam   string
+	limit        int
+	token        string
+	rootCAs      *x509.CertPool
+	retries      int
+	retryWait    time.Duration
+	parsedFields map[string]jsonPath
+}
+
+// WithHTTPFields sets the mapping of the JSON paths. If not set,
+// DefaultHTTPFields is used.
+func WithHTTPFields(fields HTTPFields) func(*HTTPDBClient) {
+	return func(h *HTTPDBClient) {
+		h.fields = fields
+	}
+}
+
+// WithHTTPCursorPagination enables the cursor pagination. The next page is
+// requested with the query parameter of the cursor, whose value is read from
+// the path of the next cursor in the previous response. The pages end when the
+// next cursor is null, empty or not found.
+func WithHTTPCursorPagination(cursorParam string, nextCursor string) func(*HTTPDBClient) {
+	return func(h *HTTPDBClient) {
+		h.pagination = HTTPCursorPagination
+		h.cursorParam = cursorParam
+		h.nextCursor = nextCursor
+	}
+}
+
+// WithHTTPOffsetPagination enables the offset pagination. Each page is
+// requested with the query parameters of the offset and the limit. The pages
+// end when a page has no items, since the endpoint may return fewer items than
+// the limit. If the limit is 0 or less than 0, DefaultHTTPLimit is used.
+func WithHTTPOffsetPagination(offsetParam string, limitParam string, limit int) func(*HTTPDBClient) {
+	return func(h *HTTPDBClient) {
+		h.pagination = HTTPOffsetPagination
+		h.offsetParam = offsetParam
+		h.limitParam = limitParam
+		h.limit = limit
+	}
+}
+
+// WithHTTPBearerToken sets the bearer token of the Authorization header.
+func WithHTTPBearerToken(token string) func(*HTTPDBClient) {
+	return func(h *HTTPDBClient) {
+		h.token = token
+	}
+}
+
+// WithHTTPRootCAs pins the CA certificates that verify the server certificate.
+// The CA certificates of the system are not trusted.
+func WithHTTPRootCAs(rootCAs *x509.CertPool) func(*HTTPDBClient) {
+	return func(h *HTTPDBClient) {
+		h.rootCAs = rootCAs
+	}
+}
+
+// WithHTTPRetries sets the number of retries of a request, and the wait before
+// the first retry, which is doubled for each retry. The requests are retried
+// when the connection fails, or the endpoint responds with 429 or 5xx.
+func WithHTTPRetries(retries int, wait time.Duration) func(*HTTPDBClient) {
+	return func(h *HTTPDBClient) {
+		h.retries = retries
+		h.retryWait = wait
+	}
+}
+
+// NewHTTPDBClient creates and returns a new instance of HTTPDBClient. The
+// timeout is the number of seconds for timeout of each request.
+func NewHTTPDBClient(
+	endpoint string, caName string, timeout int, opts ...func(*HTTPDBClient),
+) (HTTPDBClient, error) {
+	h := HTTPDBClient{
+		caName:     caName,
+		timeout:    timeout,
+		fields:     DefaultHTTPFields,
+		pagination: HTTPNoPagination,
+		retries:    DefaultHTTPRetries,
+		retryWait:  DefaultHTTPRetryWait,
+	}
+
+	for _, opt := range opts {
+		opt(&h)
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return h, fmt.Errorf("could not parse URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return h, ErrHTTPURLInvalid
+	}
+	h.url = u
+
+	if h.timeout <= 0 {
+		h.timeout = DefaultHTTPTimeout
+	}
+	if h.limit <= 0 {
+		h.limit = DefaultHTTPLimit
+	}
+
+	paths := map[string]string{
+		"items":      h.fields.Items,
+		"ca":         h.fields.CA,
+		"serial":     h.fields.Serial,
+		"rev_type":   h.fields.RevType,
+		"exp_date":   h.fields.ExpDate,
+		"rev_date":   h.fields.RevDate,
+		"crl_reason": h.fields.CRLReason,
+		"subject":    h.fields.Subject,
+	}
+	if h.pagination == HTTPCursorPagination {
+		paths["next_cursor"] = h.nextCursor
+	}
+
+	h.parsedFields = make(map[string]jsonPath, len(paths))
+	for name, path := range paths {
+		if path == "" {
+			continue
+		}
+		parsed, err := parseJSONPath(path)
+		if err != nil {
+			return h, fmt.Errorf("invalid path of %s: %w", name, err)
+		}
+		h.parsedFields[name] = parsed
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if h.rootCAs != nil {
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    h.rootCAs,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	h.client = &http.Client{
+		Transport: transport,
+		Timeout:   time.Second * time.Duration(h.timeout),
+	}
+
+	return h, nil
+}
+
+// jsonPath is a path of the JSON value in the dot notation. The numeric keys
+// are the indexes of the arrays.
+type jsonPath []string
+
+// parseJSONPath parses the path in the dot notation, which may start with "$".
+func parseJSONPath(path string) (jsonPath, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return jsonPath{}, nil
+	}
+
+	keys := strings.Split(path, ".")
+	for _, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("empty key in path: %q", path)
+		}
+	}
+
+	return keys, nil
+}
+
+// lookup returns the value of the path. If the value is not found, it returns false.
+func (p jsonPath) lookup(v any) (any, bool) {
+	for _, key := range p {
+		switch val := v.(type) {
+		case map[string]any:
+			child, ok := val[key]
+			if !ok {
+				return nil, false
+			}
+			v = child
+		case []any:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(val) {
+				return nil, false
+			}
+			v = val[idx]
+		default:
+			return nil, false
+		}
+	}
+
+	return v, true
+}
+
+// jsonValueString converts the JSON value to the string of IntermidiateEntry.
+// null is converted to the empty string. If the value is the serial number,
+// the integer is converted in the base of serial number, and the numbers of
+// the other values are the decimal text. If the date is RFC 3339, it is
+// converted to GeneralizedTime. The values of the other types are converted
+// as they are, and rejected by the verification.
+func jsonValueString(v any, serial bool, date bool) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if date {
+			if t, err := time.Parse(time.RFC3339, val); err == nil {
+				return t.UTC().Format(ASN1GeneralizedTime)
+			}
+		}
+		return val
+	case json.Number:
+		if !serial {
+			return val.String()
+		}
+		if n, ok := new(big.Int).SetString(val.String(), 10); ok {
+			return n.Text(SerialBase)
+		}
+		return val.String()
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// field returns the string of the value of the field in the item.
+func (h HTTPDBClient) field(item any, name string, date bool) string {
+	path, ok := h.parsedFields[name]
+	if !ok {
+		return ""
+	}
+
+	v, ok := path.lookup(item)
+	if !ok {
+		return ""
+	}
+
+	return jsonValueString(v, name == "serial", date)
+}
+
+// get requests the URL and decodes the JSON response. The request is retried
+// when the connection fails, or the endpoint responds with 429 or 5xx.
+func (h HTTPDBClient) get(ctx context.Context, u string) (any, error) {
+	wait := h.retryWait
+	for attempt := 0; ; attempt++ {
+		res, err := h.request(ctx, u)
+		if err == nil {
+			return h.decode(res)
+		}
+
+		var statusErr HTTPStatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			return nil, err
+		}
+		if attempt >= h.retries {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// request sends the request, and returns the response of the status 200.
+func (h HTTPDBClient) request(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not request HTTP DB: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		// The body is drained to reuse the connection
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		return nil, HTTPStatusError{url: h.url.Redacted(), code: res.StatusCode}
+	}
+
+	return res, nil
+}
+
+// decode decodes the JSON body of the response, and closes it.
+func (h HTTPDBClient) decode(res *http.Response) (any, error) {
+	defer res.Body.Close()
+
+	dec := json.NewDecoder(res.Body)
+	dec.UseNumber()
+	var body any
+	if err := dec.Decode(&body); err != nil {
+		return nil, fmt.Errorf("could not decode response of HTTP DB: %w", err)
+	}
+
+	return body, nil
+}
+
+// pageURL returns the URL of the page with the query parameters of the pagination.
+func (h HTTPDBClient) pageURL(cursor string, offset int) string {
+	u := *h.url
+	query := u.Query()
+
+	switch h.pagination {
+	case HTTPCursorPagination:
+		if cursor != "" {
+			query.Set(h.cursorParam, cursor)
+		}
+	case HTTPOffsetPagination:
+		query.Set(h.offsetParam, strconv.Itoa(offset))
+		query.Set(h.limitParam, strconv.Itoa(h.limit))
+	case HTTPNoPagination:
+	}
+
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Scan requests the pages of the endpoint, and converts their items into
+// IntermediateEntries. The items of the other CAs are ignored.
+func (h HTTPDBClient) Scan(ctx context.Context) ([]IntermidiateEntry, error) {
+	entries := make([]IntermidiateEntry, 0)
+	cursors := make(map[string]struct{})
+	var cursor string
+	offset := 0
+
+	for {
+		body, err := h.get(ctx, h.pageURL(cursor, offset))
+		if err != nil {
+			return nil, err
+		}
+
+		itemsValue, ok := h.parsedFields["items"].lookup(body)
+		items, isArray := itemsValue.([]any)
+		if !ok || !isArray {
+			return nil, fmt.Errorf("items are not found in response of HTTP DB: %s", h.fields.Items)
+		}
+
+		for _, item := range items {
+			entry := IntermidiateEntry{
+				Ca:        h.field(item, "ca", false),
+				Serial:    h.field(item, "serial", false),
+				RevType:   h.field(item, "rev_type", false),
+				ExpDate:   h.field(item, "exp_date", true),
+				RevDate:   h.field(item, "rev_date", true),
+				CRLReason: h.field(item, "crl_reason", false),
+				Subject:   h.field(item, "subject", false),
+			}
+			if h.fields.CA == "" {
+				entry.Ca = h.caName
+			}
+			if entry.Ca == "" {
+				return nil, fmt.Errorf("CA is not found in item of HTTP DB: %s", h.fields.CA)
+			}
+			if entry.Ca != h.caName {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+
+		switch h.pagination {
+		case HTTPCursorPagination:
+			cursor = h.field(body, "next_cursor", false)
+			if cursor == "" {
+				return entries, nil
+			}
+			if _, ok := cursors[cursor]; ok {
+				return nil, ErrHTTPCursorLooped
+			}
+			cursors[cursor] = struct{}{}
+		case HTTPOffsetPagination:
+			// The endpoint may cap the limit, so only the empty page ends the pages
+			if len(items) == 0 {
+				return entries, nil
+			}
+			offset += len(items)
+		case HTTPNoPagination:
+			return entries, nil
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package db
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testHTTPToken = "test-token"
+
+var testHTTPItems = []string{
+	`{"ca": "test-ca", "serial": "8CA7B3FE5D7F007673C18CCC6A1F818085CDC5F5", "rev_type": "V",
+		"exp_date": "330925234911Z", "rev_date": null, "crl_reason": null, "subject": "CN=good"}`,
+	`{"ca": "other-ca", "serial": "1F8ACD3265E5BA098DEC495EECE41C11BA093463", "rev_type": "V",
+		"exp_date": "330823234911Z", "rev_date": null, "crl_reason": null}`,
+	`{"ca": "test-ca", "serial": "2D7BB5572221AFA7D7FB30C8D19D3F693BFEEE14", "rev_type": "R",
+		"exp_date": "330823234911Z", "rev_date": "230826234911Z", "crl_reason": "unspecified"}`,
+}
+
+// testHTTPHandler serves the items in the pagination of the paths.
+func testHTTPHandler(t *testing.T) http.Handler {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /none", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, `{"items": [%s]}`, strings.Join(testHTTPItems, ","))
+	})
+	mux.HandleFunc("GET /cursor", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page_token"))
+		next := `"` + strconv.Itoa(page+1) + `"`
+		if page+1 == len(testHTTPItems) {
+			next = "null"
+		}
+		fmt.Fprintf(w, `{"meta": {"next": %s}, "items": [%s]}`, next, testHTTPItems[page])
+	})
+	// The cursors are the numbers of 10 times the pages
+	mux.HandleFunc("GET /numcursor", func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strconv.Atoi(r.URL.Query().Get("page_token"))
+		page := token / 10
+		next := strconv.Itoa((page + 1) * 10)
+		if page+1 == len(testHTTPItems) {
+			next = "null"
+		}
+		fmt.Fprintf(w, `{"meta": {"next": %s}, "items": [%s]}`, next, testHTTPItems[page])
+	})
+	// The limit is capped to 1
+	mux.HandleFunc("GET /capped", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		end := min(offset+1, len(testHTTPItems))
+		fmt.Fprintf(w, `{"items": [%s]}`, strings.Join(testHTTPItems[offset:end], ","))
+	})
+	mux.HandleFunc("GET /offset", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		end := min(offset+limit, len(testHTTPItems))
+		fmt.Fprintf(w, `{"items": [%s]}`, strings.Join(testHTTPItems[offset:end], ","))
+	})
+	mux.HandleFunc("GET /mapped", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[
+			{"serialNumber": 4660, "status": {"state": "R", "revokedAt": "2023-08-26T23:49:11Z",
+				"reasons": ["keyCompromise"]}, "notAfter": "2033-08-23T23:49:11Z"}
+		]`)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testHTTPToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func TestHTTPDBClient_Scan(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(testHTTPHandler(t))
+	t.Cleanup(server.Close)
+
+	want := []IntermidiateEntry{
+		{"test-ca", "8CA7B3FE5D7F007673C18CCC6A1F818085CDC5F5", "V", "330925234911Z", "", "", "CN=good"},
+		{"test-ca", "2D7BB5572221AFA7D7FB30C8D19D3F693BFEEE14", "R", "330823234911Z", "230826234911Z", "unspecified", ""},
+	}
+
+	data := []struct {
+		testcase string
+		path     string
+		opts     []func(*HTTPDBClient)
+		// want
+		entries []IntermidiateEntry
+	}{
+		{"no pagination", "/none", nil, want},
+		{
+			"cursor pagination", "/cursor",
+			[]func(*HTTPDBClient){WithHTTPCursorPagination("page_token", "$.meta.next")},
+			want,
+		},
+		{
+			"cursor pagination of numeric cursor", "/numcursor",
+			[]func(*HTTPDBClient){WithHTTPCursorPagination("page_token", "$.meta.next")},
+			want,
+		},
+		{
+			"offset pagination of partial last page", "/offset",
+			[]func(*HTTPDBClient){WithHTTPOffsetPagination("offset", "limit", 2)},
+			want,
+		},
+		{
+			"offset pagination of full last page", "/offset",
+			[]func(*HTTPDBClient){WithHTTPOffsetPagination("offset", "limit", 1)},
+			want,
+		},
+		{
+			"offset pagination of capped limit", "/capped",
+			[]func(*HTTPDBClient){WithHTTPOffsetPagination("offset", "limit", 2)},
+			want,
+		},
+		{
+			"mapped fields of integer and RFC 3339", "/mapped",
+			[]func(*HTTPDBClient){WithHTTPFields(HTTPFields{
+				Items:     "$",
+				Serial:    "serialNumber",
+				RevType:   "status.state",
+				ExpDate:   "notAfter",
+				RevDate:   "status.revokedAt",
+				CRLReason: "status.reasons.0",
+			})},
+			[]IntermidiateEntry{
+				{"test-ca", "1234", "R", "20330823234911Z", "20230826234911Z", "keyCompromise", ""},
+			},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			opts := append([]func(*HTTPDBClient){WithHTTPBearerToken(testHTTPToken)}, d.opts...)
+			client, err := NewHTTPDBClient(server.URL+d.path, "test-ca", 5, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := client.Scan(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(d.entries, entries); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestHTTPDBClient_Scan_Errors(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /invalid", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"items": [`)
+	})
+	mux.HandleFunc("GET /object", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"items": {"serial": "01"}}`)
+	})
+	mux.HandleFunc("GET /noca", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"items": [{"serial": "01", "rev_type": "V", "exp_date": "330925234911Z"}]}`)
+	})
+	mux.HandleFunc("GET /loop", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"next": "same", "items": []}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	data := []struct {
+		testcase string
+		path     string
+		opts     []func(*HTTPDBClient)
+		// want
+		errMsg string
+	}{
+		{"not found", "/notfound", nil, "unexpected status 404"},
+		{"invalid JSON", "/invalid", nil, "could not decode response of HTTP DB"},
+		{"items of object", "/object", nil, "items are not found"},
+		{"item without CA", "/noca", nil, "CA is not found in item of HTTP DB: ca"},
+		{
+			"looped cursor", "/loop",
+			[]func(*HTTPDBClient){WithHTTPCursorPagination("cursor", "next")},
+			ErrHTTPCursorLooped.Error(),
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := NewHTTPDBClient(server.URL+d.path, "test-ca", 5, d.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.Scan(context.Background()); err == nil || !strings.Contains(err.Error(), d.errMsg) {
+				t.Errorf("Expected error message contains '%s' but got: %v", d.errMsg, err)
+			}
+		})
+	}
+
+	t.Run("missing token", func(t *testing.T) {
+		t.Parallel()
+
+		authServer := httptest.NewServer(testHTTPHandler(t))
+		t.Cleanup(authServer.Close)
+
+		client, err := NewHTTPDBClient(authServer.URL+"/none", "test-ca", 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var statusErr HTTPStatusError
+		if _, err := client.Scan(context.Background()); !errors.As(err, &statusErr) ||
+			statusErr.code != http.StatusUnauthorized {
+			t.Errorf("Expected HTTPStatusError of 401 but got: %v", err)
+		}
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := NewHTTPDBClient("ftp://ca.example.com", "test-ca", 5); !errors.Is(err, ErrHTTPURLInvalid) {
+			t.Errorf("Expected ErrHTTPURLInvalid but got: %v", err)
+		}
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		t.Parallel()
+
+		fields := DefaultHTTPFields
+		fields.Serial = "status..serial"
+		_, err := NewHTTPDBClient(server.URL, "test-ca", 5, WithHTTPFields(fields))
+		if err == nil || !strings.Contains(err.Error(), "invalid path of serial") {
+			t.Errorf("Expected invalid path of serial but got: %v", err)
+		}
+	})
+}
+
+func TestHTTPDBClient_Scan_Retries(t *testing.T) {
+	t.Parallel()
+
+	data := []struct {
+		testcase string
+		status   int
+		failures int32
+		retries  int
+		// want
+		requests int32
+		errMsg   string
+	}{
+		{"recovered from 503", http.StatusServiceUnavailable, 2, 2, 3, ""},
+		{"recovered from 429", http.StatusTooManyRequests, 1, 2, 2, ""},
+		{"exhausted retries", http.StatusInternalServerError, 3, 2, 3, "unexpected status 500"},
+		{"not retried 403", http.StatusForbidden, 1, 2, 1, "unexpected status 403"},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if requests.Add(1) <= d.failures {
+					w.WriteHeader(d.status)
+					return
+				}
+				fmt.Fprint(w, `{"items": []}`)
+			}))
+			t.Cleanup(server.Close)
+
+			client, err := NewHTTPDBClient(server.URL, "test-ca", 5, WithHTTPRetries(d.retries, time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = client.Scan(context.Background())
+			switch {
+			case d.errMsg == "" && err != nil:
+				t.Fatal(err)
+			case d.errMsg != "" && (err == nil || !strings.Contains(err.Error(), d.errMsg)):
+				t.Errorf("Expected error message contains '%s' but got: %v", d.errMsg, err)
+			}
+
+			if got := requests.Load(); got != d.requests {
+				t.Errorf("Expected %d requests but got %d", d.requests, got)
+			}
+		})
+	}
+}
+
+func TestHTTPDBClient_Scan_TLS(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"items": []}`)
+	}))
+	t.Cleanup(server.Close)
+
+	pinned := x509.NewCertPool()
+	pinned.AddCert(server.Certificate())
+	other := x509.NewCertPool()
+	other.AddCert(testCreateCA(t, "Other CA").cert)
+
+	data := []struct {
+		testcase string
+		opts     []func(*HTTPDBClient)
+		// want
+		err bool
+	}{
+		{"pinned CA", []func(*HTTPDBClient){WithHTTPRootCAs(pinned)}, false},
+		{"other CA", []func(*HTTPDBClient){WithHTTPRootCAs(other)}, true},
+		{"system CAs", nil, true},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			opts := append([]func(*HTTPDBClient){WithHTTPRetries(0, 0)}, d.opts...)
+			client, err := NewHTTPDBClient(server.URL, "test-ca", 5, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = client.Scan(context.Background())
+			var certErr x509.UnknownAuthorityError
+			if d.err != errors.As(err, &certErr) {
+				t.Errorf("Expected unknown authority error: %v but got: %v", d.err, err)
+			}
+		})
+	}
+}
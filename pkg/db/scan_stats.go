@@ -0,0 +1,50 @@
+package db
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ScanStats holds the statistics of a database scan.
+type ScanStats struct {
+	// The number of read capacity units consumed by the scan.
+	ConsumedCapacity float64
+	// The number of items evaluated by the database before any filter is applied.
+	ScannedCount int
+	// The number of requests sent to the database.
+	Requests int
+}
+
+// ScanStatsReporter is implemented by the CADBClient that reports
+// the statistics of the last scan.
+type ScanStatsReporter interface {
+	LastScanStats() ScanStats
+}
+
+func (s *ScanStats) add(capacity *types.ConsumedCapacity, scannedCount int32) {
+	s.Requests++
+	s.ScannedCount += int(scannedCount)
+	if capacity != nil && capacity.CapacityUnits != nil {
+		s.ConsumedCapacity += *capacity.CapacityUnits
+	}
+}
+
+// scanStatsStore stores the statistics of the last scan. It is shared between
+// the copies of the client.
+type scanStatsStore struct {
+	stats ScanStats
+	mu    sync.Mutex
+}
+
+func (s *scanStatsStore) set(stats ScanStats) {
+	s.mu.Lock()
+	s.stats = stats
+	s.mu.Unlock()
+}
+
+func (s *scanStatsStore) get() ScanStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
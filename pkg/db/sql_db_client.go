@@ -0,0 +1,219 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLColumns is the mapping of the columns of a table to the attributes of
+// IntermidiateEntry.
+type SQLColumns struct {
+	CA        string
+	Serial    string
+	RevType   string
+	ExpDate   string
+	RevDate   string
+	CRLReason string
+}
+
+// DefaultSQLColumns is the mapping of the columns that have the same names as
+// the attributes of the DynamoDB items.
+var DefaultSQLColumns = SQLColumns{
+	CA:        "ca",
+	Serial:    "serial",
+	RevType:   "rev_type",
+	ExpDate:   "exp_date",
+	RevDate:   "rev_date",
+	CRLReason: "crl_reason",
+}
+
+// DefaultSQLTimeout is the default number of seconds for timeout of a scan.
+const DefaultSQLTimeout = 60
+
+var (
+	ErrSQLSourceMissing   = errors.New("either table or query must be set")
+	ErrSQLSourceExclusive = errors.New("table and query are exclusive")
+)
+
+// SQLDBClient is an implementation of the CADBClient interface. It scans the
+// certificate revocation status from a database with database/sql. The entries
+// are read from a table with a mapping of the columns, or by a custom query.
+// The driver of the database must be registered by the caller.
+type SQLDBClient struct {
+	db      *sql.DB
+	driver  string
+	caName  string
+	timeout int
+	// Options
+	table   string
+	columns SQLColumns
+	query   string
+}
+
+// WithSQLTable sets the table and the mapping of its columns. The entries of
+// the CA are read by the query that filters the CA column.
+func WithSQLTable(table string, columns SQLColumns) func(*SQLDBClient) {
+	return func(s *SQLDBClient) {
+		s.table = table
+		s.columns = columns
+	}
+}
+
+// WithSQLQuery sets the custom query, which returns the columns of ca, serial,
+// rev_type, exp_date, rev_date and crl_reason in this order. The query is
+// executed without arguments, and the rows of the other CAs are ignored.
+func WithSQLQuery(query string) func(*SQLDBClient) {
+	return func(s *SQLDBClient) {
+		s.query = query
+	}
+}
+
+// NewSQLDBClient creates and returns a new instance of SQLDBClient. The database
+// is opened with the driver and the DSN, but the connection is not established
+// until the first scan. Either WithSQLTable or WithSQLQuery must be set.
+func NewSQLDBClient(
+	driver string, dsn string, caName string, timeout int, opts ...func(*SQLDBClient),
+) (SQLDBClient, error) {
+	s := SQLDBClient{
+		driver:  driver,
+		caName:  caName,
+		timeout: timeout,
+	}
+
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	if s.table == "" && s.query == "" {
+		return s, ErrSQLSourceMissing
+	}
+	if s.table != "" && s.query != "" {
+		return s, ErrSQLSourceExclusive
+	}
+
+	if s.timeout <= 0 {
+		s.timeout = DefaultSQLTimeout
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return s, fmt.Errorf("could not open SQL DB: %w", err)
+	}
+	s.db = db
+
+	return s, nil
+}
+
+// placeholder returns the placeholder of the first argument of the driver.
+func (s SQLDBClient) placeholder() string {
+	switch s.driver {
+	case "postgres", "pgx":
+		return "$1"
+	default:
+		return "?"
+	}
+}
+
+// statement returns the query and its arguments of the scan.
+func (s SQLDBClient) statement() (string, []any) {
+	if s.query != "" {
+		return s.query, nil
+	}
+
+	cols := []string{
+		s.columns.CA, s.columns.Serial, s.columns.RevType,
+		s.columns.ExpDate, s.columns.RevDate, s.columns.CRLReason,
+	}
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s = %s",
+		strings.Join(cols, ", "), s.table, s.columns.CA, s.placeholder(),
+	)
+
+	return query, []any{s.caName}
+}
+
+// sqlValueString converts the value of a column to the string of IntermidiateEntry.
+// NULL is converted to the empty string, and the time is converted to
+// GeneralizedTime. If the value is the serial number, the integer is converted
+// in the base of serial number, and the integers of the other columns are the
+// decimal text. The values of the other types are converted as they are, and
+// rejected by the verification.
+func sqlValueString(v any, serial bool) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(val)
+	case string:
+		return val
+	case time.Time:
+		return val.UTC().Format(ASN1GeneralizedTime)
+	case int64:
+		if !serial {
+			return strconv.FormatInt(val, 10)
+		}
+		return strconv.FormatInt(val, SerialBase)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// Scan reads the rows of the CA and converts them into IntermediateEntries.
+func (s SQLDBClient) Scan(ctx context.Context) (entries []IntermidiateEntry, err error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(s.timeout))
+	defer cancel()
+
+	query, args := s.statement()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query SQL DB: %w", err)
+	}
+	defer func() {
+		closeErr := rows.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	entries = make([]IntermidiateEntry, 0)
+	values := make([]any, 6)
+	dests := make([]any, len(values))
+	for i := range values {
+		dests[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dests...); err != nil {
+			return nil, fmt.Errorf("could not read row of SQL DB: %w", err)
+		}
+
+		entry := IntermidiateEntry{
+			Ca:        sqlValueString(values[0], false),
+			Serial:    sqlValueString(values[1], true),
+			RevType:   sqlValueString(values[2], false),
+			ExpDate:   sqlValueString(values[3], false),
+			RevDate:   sqlValueString(values[4], false),
+			CRLReason: sqlValueString(values[5], false),
+		}
+		if entry.Ca != s.caName {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read rows of SQL DB: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Close closes the database.
+func (s SQLDBClient) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,184 @@
+//go:build cgo
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func testCreateSQLiteDB(t *testing.T, stmts ...string) string {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "ca.db")
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dsn
+}
+
+func TestSQLDBClient_Scan(t *testing.T) {
+	t.Parallel()
+
+	dsn := testCreateSQLiteDB(t,
+		`CREATE TABLE ca_db (ca TEXT, serial TEXT, rev_type TEXT, exp_date TEXT, rev_date TEXT, crl_reason TEXT)`,
+		`INSERT INTO ca_db VALUES
+			('test-ca', '8CA7B3FE5D7F007673C18CCC6A1F818085CDC5F5', 'V', '330925234911Z', NULL, NULL),
+			('test-ca', '2D7BB5572221AFA7D7FB30C8D19D3F693BFEEE14', 'R', '330823234911Z', '230826234911Z', 'unspecified'),
+			('other-ca', '1F8ACD3265E5BA098DEC495EECE41C11BA093463', 'V', '330823234911Z', NULL, NULL)`,
+		`CREATE TABLE certs (
+			issuer TEXT, serial_number INTEGER, status TEXT, not_after DATETIME, revoked_at DATETIME, reason TEXT
+		)`,
+		`INSERT INTO certs VALUES
+			('test-ca', 4660, 'R', '2033-08-23 23:49:11', '2023-08-26 23:49:11', 'keyCompromise')`,
+	)
+
+	want := []IntermidiateEntry{
+		{"test-ca", "8CA7B3FE5D7F007673C18CCC6A1F818085CDC5F5", "V", "330925234911Z", "", "", ""},
+		{"test-ca", "2D7BB5572221AFA7D7FB30C8D19D3F693BFEEE14", "R", "330823234911Z", "230826234911Z", "unspecified", ""},
+	}
+
+	data := []struct {
+		testcase string
+		opts     []func(*SQLDBClient)
+		// want
+		entries []IntermidiateEntry
+		errMsg  string
+	}{
+		{
+			"table with default columns",
+			[]func(*SQLDBClient){WithSQLTable("ca_db", DefaultSQLColumns)},
+			want, "",
+		},
+		{
+			"custom query",
+			[]func(*SQLDBClient){WithSQLQuery(
+				"SELECT ca, serial, rev_type, exp_date, rev_date, crl_reason FROM ca_db ORDER BY rowid",
+			)},
+			want, "",
+		},
+		{
+			"table with mapped columns of integer and datetime",
+			[]func(*SQLDBClient){WithSQLTable("certs", SQLColumns{
+				CA:        "issuer",
+				Serial:    "serial_number",
+				RevType:   "status",
+				ExpDate:   "not_after",
+				RevDate:   "revoked_at",
+				CRLReason: "reason",
+			})},
+			[]IntermidiateEntry{
+				{"test-ca", "1234", "R", "20330823234911Z", "20230826234911Z", "keyCompromise", ""},
+			},
+			"",
+		},
+		{
+			"custom query of integer not serial",
+			[]func(*SQLDBClient){WithSQLQuery(
+				"SELECT issuer, serial_number, status, not_after, revoked_at, 10 FROM certs",
+			)},
+			[]IntermidiateEntry{
+				{"test-ca", "1234", "R", "20330823234911Z", "20230826234911Z", "10", ""},
+			},
+			"",
+		},
+		{
+			"table not found",
+			[]func(*SQLDBClient){WithSQLTable("not_found", DefaultSQLColumns)},
+			nil, "could not query SQL DB: no such table: not_found",
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := NewSQLDBClient("sqlite3", dsn, "test-ca", 0, d.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			entries, err := client.Scan(context.TODO())
+			if d.errMsg != "" {
+				if err == nil || err.Error() != d.errMsg {
+					t.Fatalf("Expected error is %q but got: %v", d.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(d.entries, entries); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestSQLDBClient_Scan_Timeout(t *testing.T) {
+	t.Parallel()
+
+	dsn := testCreateSQLiteDB(t)
+	// The recursive query does not end until the context is done
+	client, err := NewSQLDBClient("sqlite3", dsn, "test-ca", 1, WithSQLQuery(
+		`WITH RECURSIVE r(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM r)
+		SELECT 'test-ca', n, 'V', '', '', '' FROM r WHERE n < 0`,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	start := time.Now()
+	if _, err := client.Scan(context.TODO()); err == nil {
+		t.Fatal("Expected error but got nil.")
+	}
+	if time.Since(start) > time.Second*5 {
+		t.Errorf("Scan must be canceled by the timeout: %v", time.Since(start))
+	}
+}
+
+func TestNewSQLDBClient_Errors(t *testing.T) {
+	t.Parallel()
+
+	data := []struct {
+		testcase string
+		opts     []func(*SQLDBClient)
+		err      error
+	}{
+		{"no table and query", nil, ErrSQLSourceMissing},
+		{
+			"table and query",
+			[]func(*SQLDBClient){WithSQLTable("ca_db", DefaultSQLColumns), WithSQLQuery("SELECT 1")},
+			ErrSQLSourceExclusive,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := NewSQLDBClient("sqlite3", "", "test-ca", 0, d.opts...); !errors.Is(err, d.err) {
+				t.Errorf("Expected error is %v but got: %v", d.err, err)
+			}
+		})
+	}
+}
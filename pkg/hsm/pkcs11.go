@@ -0,0 +1,36 @@
+package hsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// PKCS11Key is a reference to a private key stored in a PKCS #11 token.
+// The public key that has the same label must also be stored in the token.
+type PKCS11Key struct {
+	// Path of the PKCS #11 module (shared library).
+	Module string
+	// Label of the token that holds the key.
+	TokenLabel string
+	// Label of the private key and the public key.
+	KeyLabel string
+	// User PIN to log in to the token.
+	PIN string
+}
+
+// ErrPKCS11Unsupported is returned when the binary was built without cgo,
+// which is required to load a PKCS #11 module.
+var ErrPKCS11Unsupported = errors.New("PKCS #11 is not supported by this build, cgo is required")
+
+type pkcs11Error struct {
+	op  string
+	err error
+}
+
+func (e pkcs11Error) Error() string {
+	return fmt.Sprintf("pkcs11: failed to %s: %s", e.op, e.err)
+}
+
+func (e pkcs11Error) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,474 @@
+//go:build cgo
+
+package hsm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// Ed25519 constants of PKCS #11 v3.0, which github.com/miekg/pkcs11 does not define.
+const (
+	ckkECEdwards = 0x00000040
+	ckmEdDSA     = 0x00001057
+)
+
+// RFC 5480: 2.1.1.1. Named Curve.
+var (
+	oidNamedCurveP224 = asn1.ObjectIdentifier{1, 3, 132, 0, 33}
+	oidNamedCurveP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidNamedCurveP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	oidNamedCurveP521 = asn1.ObjectIdentifier{1, 3, 132, 0, 35}
+	// RFC 8410: 3. Curve25519 and Curve448 Algorithm Identifiers.
+	oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// RFC 8017: 9.2. EMSA-PKCS1-v1_5, DER encoding prefixes of DigestInfo.
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1: {
+		0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14,
+	},
+	crypto.SHA224: {
+		0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x04,
+		0x05, 0x00, 0x04, 0x1c,
+	},
+	crypto.SHA256: {
+		0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01,
+		0x05, 0x00, 0x04, 0x20,
+	},
+	crypto.SHA384: {
+		0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02,
+		0x05, 0x00, 0x04, 0x30,
+	},
+	crypto.SHA512: {
+		0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03,
+		0x05, 0x00, 0x04, 0x40,
+	},
+}
+
+// pkcs11Module is a loaded and initialized PKCS #11 module. A module can be
+// initialized once per process, so it is shared by the signers of the module,
+// and finalized when the last signer is closed.
+type pkcs11Module struct {
+	ctx  *pkcs11.Ctx
+	refs int
+}
+
+var (
+	modulesMu sync.Mutex
+	modules   = make(map[string]*pkcs11Module)
+)
+
+// acquireModule returns the context of the module, which is loaded and
+// initialized if it is not yet.
+func acquireModule(path string) (*pkcs11.Ctx, error) {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+
+	if mod, ok := modules[path]; ok {
+		mod.refs++
+		return mod.ctx, nil
+	}
+
+	ctx := pkcs11.New(path)
+	if ctx == nil {
+		return nil, pkcs11Error{"load module", fmt.Errorf("could not load %s", path)}
+	}
+
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, pkcs11Error{"initialize module", err}
+	}
+
+	modules[path] = &pkcs11Module{ctx: ctx, refs: 1}
+	return ctx, nil
+}
+
+// releaseModule finalizes and unloads the module, if it is not used by the
+// other signers.
+func releaseModule(path string) error {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+
+	mod, ok := modules[path]
+	if !ok {
+		return nil
+	}
+
+	mod.refs--
+	if mod.refs > 0 {
+		return nil
+	}
+	delete(modules, path)
+
+	err := mod.ctx.Finalize()
+	mod.ctx.Destroy()
+	if err != nil {
+		return pkcs11Error{"finalize module", err}
+	}
+	return nil
+}
+
+// PKCS11Signer is an implementation of crypto.Signer. It signs digests with
+// a private key stored in a PKCS #11 token, so that the private key never
+// leaves the token. RSA (PKCS #1 v1.5), ECDSA and Ed25519 keys are supported.
+type PKCS11Signer struct {
+	mu      sync.Mutex
+	module  string
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	privKey pkcs11.ObjectHandle
+	pubKey  crypto.PublicKey
+	mech    uint
+}
+
+// NewPKCS11Signer loads the PKCS #11 module, logs in to the token, and creates
+// and returns a new instance of PKCS11Signer for the referenced key. The
+// module is shared by the signers of the same module path.
+// Close must be called to close the session and release the module.
+func NewPKCS11Signer(key PKCS11Key) (*PKCS11Signer, error) {
+	ctx, err := acquireModule(key.Module)
+	if err != nil {
+		return nil, err
+	}
+
+	signer := &PKCS11Signer{module: key.Module, ctx: ctx}
+	if err := signer.open(key); err != nil {
+		signer.Close()
+		return nil, err
+	}
+
+	return signer, nil
+}
+
+func (s *PKCS11Signer) open(key PKCS11Key) error {
+	slot, err := findSlot(s.ctx, key.TokenLabel)
+	if err != nil {
+		return err
+	}
+
+	session, err := s.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return pkcs11Error{"open session", err}
+	}
+	s.session = session
+
+	err = s.ctx.Login(session, pkcs11.CKU_USER, key.PIN)
+	if err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+		return pkcs11Error{"log in", err}
+	}
+
+	s.privKey, err = findObject(s.ctx, session, pkcs11.CKO_PRIVATE_KEY, key.KeyLabel)
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := findObject(s.ctx, session, pkcs11.CKO_PUBLIC_KEY, key.KeyLabel)
+	if err != nil {
+		return err
+	}
+
+	s.pubKey, err = readPublicKey(s.ctx, session, pubKey)
+	if err != nil {
+		return err
+	}
+
+	// Reject the key here, so that Sign never starts an unknown mechanism
+	s.mech, err = signMechanism(s.pubKey)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// signMechanism returns the PKCS #11 signing mechanism of the public key type.
+func signMechanism(pub crypto.PublicKey) (uint, error) {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return pkcs11.CKM_RSA_PKCS, nil
+	case *ecdsa.PublicKey:
+		return pkcs11.CKM_ECDSA, nil
+	case ed25519.PublicKey:
+		return ckmEdDSA, nil
+	default:
+		return 0, pkcs11Error{"select mechanism", fmt.Errorf("unsupported public key type: %T", pub)}
+	}
+}
+
+func findSlot(ctx *pkcs11.Ctx, tokenLabel string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, pkcs11Error{"get slot list", err}
+	}
+
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			return 0, pkcs11Error{"get token info", err}
+		}
+		// Token labels are padded with blank characters
+		if strings.TrimRight(info.Label, " \x00") == tokenLabel {
+			return slot, nil
+		}
+	}
+
+	return 0, pkcs11Error{"find token", fmt.Errorf("token %q is not found", tokenLabel)}
+}
+
+func findObject(
+	ctx *pkcs11.Ctx, session pkcs11.SessionHandle, class uint, label string,
+) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+
+	if err := ctx.FindObjectsInit(session, template); err != nil {
+		return 0, pkcs11Error{"find key", err}
+	}
+
+	objs, _, err := ctx.FindObjects(session, 2)
+	if finalErr := ctx.FindObjectsFinal(session); err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return 0, pkcs11Error{"find key", err}
+	}
+
+	kind := "private key"
+	if class == pkcs11.CKO_PUBLIC_KEY {
+		kind = "public key"
+	}
+
+	switch len(objs) {
+	case 0:
+		return 0, pkcs11Error{"find key", fmt.Errorf("%s %q is not found", kind, label)}
+	case 1:
+		return objs[0], nil
+	default:
+		return 0, pkcs11Error{"find key", fmt.Errorf("%s %q is not unique", kind, label)}
+	}
+}
+
+func readPublicKey(
+	ctx *pkcs11.Ctx, session pkcs11.SessionHandle, obj pkcs11.ObjectHandle,
+) (crypto.PublicKey, error) {
+	attrs, err := ctx.GetAttributeValue(session, obj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil {
+		return nil, pkcs11Error{"read public key", err}
+	}
+
+	keyType, err := ulongValue(attrs[0].Value)
+	if err != nil {
+		return nil, pkcs11Error{"read public key", err}
+	}
+
+	switch keyType {
+	case pkcs11.CKK_RSA:
+		return readRSAPublicKey(ctx, session, obj)
+	case pkcs11.CKK_EC, ckkECEdwards:
+		return readECPublicKey(ctx, session, obj)
+	default:
+		return nil, pkcs11Error{"read public key", fmt.Errorf("unsupported key type: 0x%x", keyType)}
+	}
+}
+
+// ulongValue decodes a CK_ULONG attribute value, which is stored in the native
+// byte order and size.
+func ulongValue(b []byte) (uint64, error) {
+	switch len(b) {
+	case 4:
+		return uint64(binary.NativeEndian.Uint32(b)), nil
+	case 8:
+		return binary.NativeEndian.Uint64(b), nil
+	default:
+		return 0, fmt.Errorf("unexpected CK_ULONG size: %d", len(b))
+	}
+}
+
+func readRSAPublicKey(
+	ctx *pkcs11.Ctx, session pkcs11.SessionHandle, obj pkcs11.ObjectHandle,
+) (crypto.PublicKey, error) {
+	attrs, err := ctx.GetAttributeValue(session, obj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+	})
+	if err != nil {
+		return nil, pkcs11Error{"read RSA public key", err}
+	}
+
+	exp := new(big.Int).SetBytes(attrs[1].Value)
+	if !exp.IsInt64() {
+		return nil, pkcs11Error{"read RSA public key", errors.New("public exponent is too large")}
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(attrs[0].Value),
+		E: int(exp.Int64()),
+	}, nil
+}
+
+func readECPublicKey(
+	ctx *pkcs11.Ctx, session pkcs11.SessionHandle, obj pkcs11.ObjectHandle,
+) (crypto.PublicKey, error) {
+	attrs, err := ctx.GetAttributeValue(session, obj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, pkcs11Error{"read EC public key", err}
+	}
+
+	var curveOID asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(attrs[0].Value, &curveOID); err != nil {
+		// PKCS #11 v3.0 also allows Edwards curves to be specified by name
+		var curveName string
+		if _, nameErr := asn1.Unmarshal(attrs[0].Value, &curveName); nameErr != nil || curveName != "edwards25519" {
+			return nil, pkcs11Error{"read EC public key", err}
+		}
+		curveOID = oidEd25519
+	}
+
+	// CKA_EC_POINT is DER-encoding of the OCTET STRING
+	var point []byte
+	if _, err := asn1.Unmarshal(attrs[1].Value, &point); err != nil {
+		return nil, pkcs11Error{"read EC public key", err}
+	}
+
+	var curve elliptic.Curve
+	switch {
+	case curveOID.Equal(oidEd25519):
+		if len(point) != ed25519.PublicKeySize {
+			return nil, pkcs11Error{"read EC public key", errors.New("invalid Ed25519 public key size")}
+		}
+		return ed25519.PublicKey(point), nil
+	case curveOID.Equal(oidNamedCurveP224):
+		curve = elliptic.P224()
+	case curveOID.Equal(oidNamedCurveP256):
+		curve = elliptic.P256()
+	case curveOID.Equal(oidNamedCurveP384):
+		curve = elliptic.P384()
+	case curveOID.Equal(oidNamedCurveP521):
+		curve = elliptic.P521()
+	default:
+		return nil, pkcs11Error{"read EC public key", fmt.Errorf("unsupported curve: %s", curveOID)}
+	}
+
+	pub, err := ecdsa.ParseUncompressedPublicKey(curve, point)
+	if err != nil {
+		return nil, pkcs11Error{"read EC public key", err}
+	}
+
+	return pub, nil
+}
+
+// Public returns the public key of the private key in the token.
+func (s *PKCS11Signer) Public() crypto.PublicKey {
+	return s.pubKey
+}
+
+// Sign signs the digest with the private key in the token. For Ed25519, the
+// digest must be the message itself and opts.HashFunc() must be zero.
+// RSA-PSS is not supported.
+func (s *PKCS11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	data := digest
+
+	switch s.pubKey.(type) {
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return nil, pkcs11Error{"sign", errors.New("RSA-PSS is not supported")}
+		}
+		prefix, ok := digestInfoPrefixes[opts.HashFunc()]
+		if !ok {
+			return nil, pkcs11Error{"sign", fmt.Errorf("unsupported hash function: %s", opts.HashFunc())}
+		}
+		data = append(append([]byte{}, prefix...), digest...)
+	case *ecdsa.PublicKey:
+	case ed25519.PublicKey:
+		if opts.HashFunc() != crypto.Hash(0) {
+			return nil, pkcs11Error{"sign", errors.New("Ed25519 does not support pre-hashed messages")}
+		}
+	default:
+		return nil, pkcs11Error{"sign", fmt.Errorf("unsupported public key type: %T", s.pubKey)}
+	}
+
+	sig, err := s.sign(s.mech, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := s.pubKey.(*ecdsa.PublicKey); ok {
+		return ecdsaSignatureToDER(sig)
+	}
+
+	return sig, nil
+}
+
+func (s *PKCS11Signer) sign(mech uint, data []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.ctx.SignInit(s.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mech, nil)}, s.privKey)
+	if err != nil {
+		return nil, pkcs11Error{"sign", err}
+	}
+
+	sig, err := s.ctx.Sign(s.session, data)
+	if err != nil {
+		return nil, pkcs11Error{"sign", err}
+	}
+
+	return sig, nil
+}
+
+// ecdsaSignatureToDER converts a PKCS #11 ECDSA signature (r || s) to
+// the ASN.1 DER encoded Ecdsa-Sig-Value.
+func ecdsaSignatureToDER(sig []byte) ([]byte, error) {
+	if len(sig) == 0 || len(sig)%2 != 0 {
+		return nil, pkcs11Error{"sign", errors.New("invalid ECDSA signature length")}
+	}
+
+	half := len(sig) / 2
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{
+		new(big.Int).SetBytes(sig[:half]),
+		new(big.Int).SetBytes(sig[half:]),
+	})
+}
+
+// Close closes the session and releases the module, which is unloaded when
+// the last signer of the module is closed. The login state is shared by the
+// sessions of the token, so it is not logged out explicitly, and ends when the
+// last session of the token is closed.
+func (s *PKCS11Signer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx == nil {
+		return nil
+	}
+
+	if s.session != 0 {
+		_ = s.ctx.CloseSession(s.session)
+	}
+	s.ctx = nil
+
+	return releaseModule(s.module)
+}
@@ -0,0 +1,31 @@
+//go:build !cgo
+
+package hsm
+
+import (
+	"crypto"
+	"io"
+)
+
+// PKCS11Signer is not available without cgo.
+type PKCS11Signer struct{}
+
+// NewPKCS11Signer always returns ErrPKCS11Unsupported without cgo.
+func NewPKCS11Signer(key PKCS11Key) (*PKCS11Signer, error) {
+	return nil, ErrPKCS11Unsupported
+}
+
+// Public is not available without cgo.
+func (s *PKCS11Signer) Public() crypto.PublicKey {
+	return nil
+}
+
+// Sign always returns ErrPKCS11Unsupported without cgo.
+func (s *PKCS11Signer) Sign(_ io.Reader, _ []byte, _ crypto.SignerOpts) ([]byte, error) {
+	return nil, ErrPKCS11Unsupported
+}
+
+// Close does nothing without cgo.
+func (s *PKCS11Signer) Close() error {
+	return nil
+}
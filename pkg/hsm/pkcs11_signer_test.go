@@ -0,0 +1,241 @@
+//go:build cgo
+
+package hsm
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+)
+
+// The SoftHSM token is created by scripts/setup-softhsm-testdata.sh.
+const (
+	testTokenLabel = "dyocsp-test"
+	testPIN        = "1234"
+)
+
+func testPKCS11Module(t *testing.T) string {
+	t.Helper()
+
+	module := os.Getenv("DYOCSP_TEST_PKCS11_MODULE")
+	if module == "" {
+		t.Skip("DYOCSP_TEST_PKCS11_MODULE is not set")
+	}
+	return module
+}
+
+func testParseCertificate(t *testing.T, file string) *x509.Certificate {
+	t.Helper()
+
+	certPem, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(certPem)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestPKCS11Signer_Sign(t *testing.T) {
+	t.Parallel()
+
+	module := testPKCS11Module(t)
+
+	data := []struct {
+		testCase string
+		keyLabel string
+		certFile string
+	}{
+		{"RSA", "sub-ocsp-rsa", "../../testdata/sub-ocsp-rsa.crt"},
+		{"ECDSA", "sub-ocsp-ecparam", "../../testdata/sub-ocsp-ecparam.crt"},
+	}
+
+	for _, d := range data {
+		t.Run(d.testCase, func(t *testing.T) {
+			signer, err := NewPKCS11Signer(PKCS11Key{
+				Module:     module,
+				TokenLabel: testTokenLabel,
+				KeyLabel:   d.keyLabel,
+				PIN:        testPIN,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer signer.Close()
+
+			cert := testParseCertificate(t, d.certFile)
+			pub, ok := signer.Public().(interface{ Equal(x crypto.PublicKey) bool })
+			if !ok || !pub.Equal(cert.PublicKey) {
+				t.Fatal("Public key of the signer does not match the certificate.")
+			}
+
+			digest := sha256.Sum256([]byte("dyocsp"))
+			sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			switch pub := signer.Public().(type) {
+			case *rsa.PublicKey:
+				err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+			case *ecdsa.PublicKey:
+				if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+					t.Fatal("ECDSA signature verification failed.")
+				}
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestPKCS11Signer_SharedModule(t *testing.T) {
+	t.Parallel()
+
+	module := testPKCS11Module(t)
+
+	signers := make([]*PKCS11Signer, 0, 2)
+	for _, keyLabel := range []string{"sub-ocsp-rsa", "sub-ocsp-ecparam"} {
+		signer, err := NewPKCS11Signer(PKCS11Key{module, testTokenLabel, keyLabel, testPIN})
+		if err != nil {
+			t.Fatal(err)
+		}
+		signers = append(signers, signer)
+	}
+
+	// The module is still initialized for the other signer
+	if err := signers[0].Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256([]byte("dyocsp"))
+	sig, err := signers[1].Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, ok := signers[1].Public().(*ecdsa.PublicKey)
+	if !ok || !ecdsa.VerifyASN1(pub, digest[:], sig) {
+		t.Fatal("ECDSA signature verification failed.")
+	}
+
+	if err := signers[1].Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPKCS11Signer_NotFound(t *testing.T) {
+	t.Parallel()
+
+	module := testPKCS11Module(t)
+
+	data := []struct {
+		testCase string
+		key      PKCS11Key
+	}{
+		{"Token", PKCS11Key{module, "no-such-token", "sub-ocsp-rsa", testPIN}},
+		{"Key", PKCS11Key{module, testTokenLabel, "no-such-key", testPIN}},
+		{"PIN", PKCS11Key{module, testTokenLabel, "sub-ocsp-rsa", "0000"}},
+	}
+
+	for _, d := range data {
+		t.Run(d.testCase, func(t *testing.T) {
+			_, err := NewPKCS11Signer(d.key)
+			if err == nil {
+				t.Fatal("Expected error but got nil.")
+			}
+		})
+	}
+}
+
+func TestECDSASignatureToDER(t *testing.T) {
+	t.Parallel()
+
+	raw := make([]byte, 64)
+	raw[31] = 1
+	raw[63] = 2
+
+	der, err := ecdsaSignatureToDER(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02}
+	if string(der) != string(expected) {
+		t.Errorf("Expected %x but got: %x", expected, der)
+	}
+
+	_, err = ecdsaSignatureToDER(raw[:63])
+	if err == nil {
+		t.Error("Expected error for odd signature length but got nil.")
+	}
+}
+
+func TestSignMechanism(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dhKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []struct {
+		testcase string
+		pub      crypto.PublicKey
+		// want
+		mech uint
+		err  bool
+	}{
+		{"RSA", &rsaKey.PublicKey, pkcs11.CKM_RSA_PKCS, false},
+		{"ECDSA", &ecKey.PublicKey, pkcs11.CKM_ECDSA, false},
+		{"Ed25519", edPub, ckmEdDSA, false},
+		{"X25519", dhKey.PublicKey(), 0, true},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			mech, err := signMechanism(d.pub)
+			if d.err != (err != nil) {
+				t.Fatalf("Expected error: %v but got: %v", d.err, err)
+			}
+			if mech != d.mech {
+				t.Errorf("Expected mechanism 0x%x but got: 0x%x", d.mech, mech)
+			}
+
+			if d.err {
+				signer := &PKCS11Signer{pubKey: d.pub}
+				if _, err := signer.Sign(rand.Reader, []byte("digest"), crypto.Hash(0)); err == nil {
+					t.Error("Expected error for unsupported key type but got nil.")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package dyocsp
+
+import "sync/atomic"
+
+// Readiness reports whether the process is ready to accept requests. The
+// process is marked not ready when it starts shutting down, so that the load
+// balancer stops routing new requests while the servers are drained.
+type Readiness struct {
+	shuttingDown atomic.Bool
+}
+
+// NewReadiness creates a new instance of dyocsp.Readiness, which is ready.
+func NewReadiness() *Readiness {
+	return &Readiness{}
+}
+
+// MarkShuttingDown marks the process not ready. It cannot be undone.
+func (r *Readiness) MarkShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Ready returns true if the process is not shutting down.
+func (r *Readiness) Ready() bool {
+	return !r.shuttingDown.Load()
+}
@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
-	"crypto/sha1"
+	_ "crypto/sha1" // Register hash functions of IssuerHash
+	_ "crypto/sha256"
+	_ "crypto/sha512"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
 	"io"
@@ -23,9 +27,25 @@ type KeyFormat int
 const (
 	// PKCS #8 key format.
 	PKCS8 KeyFormat = iota
+	// PKCS #1 RSA key format.
+	PKCS1
+	// SEC 1 EC key format.
+	SEC1
+	// Passphrase-encrypted PKCS #8 key format.
+	EncryptedPKCS8
+	// The key is held by an external crypto.Signer (e.g. PKCS #11 token).
+	ExternalSigner
 	FormatUnknown
 )
 
+// PEM block types of each key format.
+const (
+	pemTypePKCS8          = "PRIVATE KEY"
+	pemTypePKCS1          = "RSA PRIVATE KEY"
+	pemTypeSEC1           = "EC PRIVATE KEY"
+	pemTypeEncryptedPKCS8 = "ENCRYPTED PRIVATE KEY"
+)
+
 // KeyAlg is a supported signing key algorithm.
 type KeyAlg int
 
@@ -34,6 +54,8 @@ const (
 	AlgRSA KeyAlg = iota
 	// ECDSA signing key algorithm.
 	AlgECDSA
+	// Ed25519 signing key algorithm.
+	AlgEd25519
 	AlgUnknown
 )
 
@@ -56,34 +78,76 @@ func (e invalidPKIResourceError) Error() string {
 
 // IssuerHash is used to compare the hashes of the responder's
 // issuer and the requested issuer to check if they are the same.
+// The hashes are precomputed for each supported hash algorithm of CertID.
 type IssuerHash struct {
 	// SHA-1 hash.
 	SHA1 []byte
+	// SHA-256 hash.
+	SHA256 []byte
+	// SHA-384 hash.
+	SHA384 []byte
+	// SHA-512 hash.
+	SHA512 []byte
+}
+
+// Hash returns the hash computed with the hash algorithm. If the hash algorithm
+// is not supported, it returns false.
+func (h IssuerHash) Hash(alg crypto.Hash) ([]byte, bool) {
+	switch alg {
+	case crypto.SHA1:
+		return h.SHA1, true
+	case crypto.SHA256:
+		return h.SHA256, true
+	case crypto.SHA384:
+		return h.SHA384, true
+	case crypto.SHA512:
+		return h.SHA512, true
+	default:
+		return nil, false
+	}
 }
 
-func createIssuerSHA1Hash(input []byte) ([]byte, error) {
+func createIssuerHashWith(alg crypto.Hash, input []byte) ([]byte, error) {
 	cInput := make([]byte, len(input))
 	copy(cInput, input)
 
-	sha := sha1.New()
+	h := alg.New()
 
-	_, err := io.Writer.Write(sha, cInput)
+	_, err := io.Writer.Write(h, cInput)
 	if err != nil {
 		return nil, err
 	}
 
-	return sha.Sum(nil), nil
+	return h.Sum(nil), nil
 }
 
 func createIssuerHash(input []byte) (IssuerHash, error) {
 	var iHash IssuerHash
 
-	sha1Sum, err := createIssuerSHA1Hash(input)
+	sha1Sum, err := createIssuerHashWith(crypto.SHA1, input)
 	if err != nil {
 		return iHash, err
 	}
 	iHash.SHA1 = sha1Sum
 
+	sha256Sum, err := createIssuerHashWith(crypto.SHA256, input)
+	if err != nil {
+		return iHash, err
+	}
+	iHash.SHA256 = sha256Sum
+
+	sha384Sum, err := createIssuerHashWith(crypto.SHA384, input)
+	if err != nil {
+		return iHash, err
+	}
+	iHash.SHA384 = sha384Sum
+
+	sha512Sum, err := createIssuerHashWith(crypto.SHA512, input)
+	if err != nil {
+		return iHash, err
+	}
+	iHash.SHA512 = sha512Sum
+
 	return iHash, nil
 }
 
@@ -102,7 +166,7 @@ const (
 // the response cache and verify the issuer in OCSP requests.
 type Responder struct {
 	rCert      *x509.Certificate
-	rPrivKey   crypto.PrivateKey
+	rSigner    crypto.Signer
 	rKeyFormat KeyFormat
 	rKeyAlg    KeyAlg
 	issuerCert *x509.Certificate
@@ -115,14 +179,44 @@ type Responder struct {
 }
 
 func detectPrivKeyPemFormat(pem []byte) KeyFormat {
-	p8Re := regexp.MustCompile("BEGIN PRIVATE KEY")
+	p8Re := regexp.MustCompile("BEGIN " + pemTypePKCS8)
 	if p8Re.Match(pem) {
 		return PKCS8
 	}
 
+	p1Re := regexp.MustCompile("BEGIN " + pemTypePKCS1)
+	if p1Re.Match(pem) {
+		return PKCS1
+	}
+
+	sec1Re := regexp.MustCompile("BEGIN " + pemTypeSEC1)
+	if sec1Re.Match(pem) {
+		return SEC1
+	}
+
+	encP8Re := regexp.MustCompile("BEGIN " + pemTypeEncryptedPKCS8)
+	if encP8Re.Match(pem) {
+		return EncryptedPKCS8
+	}
+
 	return FormatUnknown
 }
 
+// decodePrivKeyPem returns the first PEM block of the type. Other blocks
+// such as "EC PARAMETERS" are skipped.
+func decodePrivKeyPem(rest []byte, blockType string) (*pem.Block, bool) {
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, false
+		}
+		if block.Type == blockType {
+			return block, true
+		}
+	}
+}
+
 func parsePKCS8PrivKey(block *pem.Block) (crypto.PrivateKey, error) {
 	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
 	if err != nil {
@@ -133,17 +227,74 @@ func parsePKCS8PrivKey(block *pem.Block) (crypto.PrivateKey, error) {
 	return key, nil
 }
 
-func detectPrivKeyAlgorithm(key crypto.PrivateKey) (KeyAlg, bool) {
-	_, ok := key.(*rsa.PrivateKey)
+func parsePKCS1PrivKey(block *pem.Block) (crypto.PrivateKey, error) {
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to parse a PKCS #1, ASN.1 DER private key: %w", err,
+		)
+	}
+	return key, nil
+}
+
+func parseSEC1PrivKey(block *pem.Block) (crypto.PrivateKey, error) {
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to parse a SEC 1, ASN.1 DER private key: %w", err,
+		)
+	}
+	return key, nil
+}
+
+func parsePrivKeyPem(rPrivKeyPem []byte, rKeyFormat KeyFormat, passphrase []byte) (crypto.PrivateKey, error) {
+	var blockType string
+	switch rKeyFormat {
+	case PKCS8:
+		blockType = pemTypePKCS8
+	case PKCS1:
+		blockType = pemTypePKCS1
+	case SEC1:
+		blockType = pemTypeSEC1
+	case EncryptedPKCS8:
+		blockType = pemTypeEncryptedPKCS8
+	case FormatUnknown:
+		return nil, invalidPKIResourceError{responderKey, "Found unsupported key format."}
+	}
+
+	keyblock, ok := decodePrivKeyPem(rPrivKeyPem, blockType)
+	if !ok {
+		return nil, invalidPKIResourceError{responderKey, "Could not decode PEM block."}
+	}
+
+	switch rKeyFormat {
+	case PKCS1:
+		return parsePKCS1PrivKey(keyblock)
+	case SEC1:
+		return parseSEC1PrivKey(keyblock)
+	case EncryptedPKCS8:
+		return parseEncryptedPKCS8PrivKey(keyblock.Bytes, passphrase)
+	default:
+		return parsePKCS8PrivKey(keyblock)
+	}
+}
+
+func detectPubKeyAlgorithm(pub crypto.PublicKey) (KeyAlg, bool) {
+	_, ok := pub.(*rsa.PublicKey)
 	if ok {
 		return AlgRSA, true
 	}
 
-	_, ok = key.(*ecdsa.PrivateKey)
+	_, ok = pub.(*ecdsa.PublicKey)
 	if ok {
 		return AlgECDSA, true
 	}
 
+	_, ok = pub.(ed25519.PublicKey)
+	if ok {
+		return AlgEd25519, true
+	}
+
 	return AlgUnknown, false
 }
 
@@ -177,33 +328,80 @@ func detectAuthType(cert *x509.Certificate) AuthorizedType {
 	return Itself
 }
 
+// ResponderOption is type of an functional option for dyocsp.BuildResponder.
+type ResponderOption func(*responderSpec)
+
+type responderSpec struct {
+	keyPassphrase []byte
+}
+
+// WithKeyPassphrase sets the passphrase to decrypt a passphrase-encrypted
+// PKCS #8 private key. It is ignored when the private key is not encrypted.
+func WithKeyPassphrase(passphrase []byte) func(*responderSpec) {
+	return func(s *responderSpec) {
+		s.keyPassphrase = passphrase
+	}
+}
+
 // BuildResponder verifies the provided certificates and private key
-// formats. It takes a PEM format responder certificate, a PKCS#8 encoded PEM format
-// responder private key, and a PEM format issuer certificate as input. It then creates and
+// formats. It takes a PEM format responder certificate, a PEM format responder
+// private key, and a PEM format issuer certificate as input. It then creates and
 // returns a new dyocsp.Responder instance.
-func BuildResponder(rCertPem, rPrivKeyPem, issuerCertPem []byte, nowT time.Time) (*Responder, error) {
-	// Parse Responder Certificate
-	rCertblock, _ := pem.Decode(rCertPem)
-	rCert, err := x509.ParseCertificate(rCertblock.Bytes)
-	if err != nil {
-		return nil, err
+// The private key can be encoded in PKCS #8, PKCS #1 (RSA), SEC 1 (EC), or
+// passphrase-encrypted PKCS #8 with the WithKeyPassphrase option.
+func BuildResponder(
+	rCertPem, rPrivKeyPem, issuerCertPem []byte, nowT time.Time, opts ...ResponderOption,
+) (*Responder, error) {
+	var spec responderSpec
+	for _, opt := range opts {
+		opt(&spec)
 	}
 
 	// Parse Responder Key
 	rKeyFormat := detectPrivKeyPemFormat(rPrivKeyPem)
-	keyblock, _ := pem.Decode(rPrivKeyPem)
-	var rPrivKey crypto.PrivateKey
-	switch rKeyFormat {
-	case PKCS8:
-		rPrivKey, err = parsePKCS8PrivKey(keyblock)
-		if err != nil {
-			return nil, err
+	rPrivKey, err := parsePrivKeyPem(rPrivKeyPem, rKeyFormat, spec.keyPassphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := rPrivKey.(crypto.Signer)
+	if !ok {
+		return nil, invalidPKIResourceError{
+			responderKey, "Could not detect singing algorithm from private key.",
 		}
-	case FormatUnknown:
-		return nil, invalidPKIResourceError{responderKey, "Found unsupported key format."}
 	}
 
-	rKeyAlg, ok := detectPrivKeyAlgorithm(rPrivKey)
+	return buildResponder(rCertPem, signer, rKeyFormat, issuerCertPem, nowT)
+}
+
+// BuildResponderWithSigner is the same as BuildResponder, except that the
+// responses are signed by the crypto.Signer instead of a PEM format private key.
+// This allows the signing key to be kept outside of the process, such as in
+// a PKCS #11 token.
+func BuildResponderWithSigner(
+	rCertPem []byte, signer crypto.Signer, issuerCertPem []byte, nowT time.Time,
+) (*Responder, error) {
+	if signer == nil {
+		return nil, invalidPKIResourceError{responderKey, "signer is not provided."}
+	}
+
+	return buildResponder(rCertPem, signer, ExternalSigner, issuerCertPem, nowT)
+}
+
+func buildResponder(
+	rCertPem []byte, signer crypto.Signer, rKeyFormat KeyFormat, issuerCertPem []byte, nowT time.Time,
+) (*Responder, error) {
+	// Parse Responder Certificate
+	rCertblock, _ := pem.Decode(rCertPem)
+	if rCertblock == nil {
+		return nil, invalidPKIResourceError{responderCert, "PEM block is not found."}
+	}
+	rCert, err := x509.ParseCertificate(rCertblock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rKeyAlg, ok := detectPubKeyAlgorithm(signer.Public())
 	if !ok {
 		return nil, invalidPKIResourceError{
 			responderKey, "Could not detect singing algorithm from private key.",
@@ -212,6 +410,9 @@ func BuildResponder(rCertPem, rPrivKeyPem, issuerCertPem []byte, nowT time.Time)
 
 	// Parse Issuer Certificate
 	iCertblock, _ := pem.Decode(issuerCertPem)
+	if iCertblock == nil {
+		return nil, invalidPKIResourceError{issuerCert, "PEM block is not found."}
+	}
 	iCert, err := x509.ParseCertificate(iCertblock.Bytes)
 	if err != nil {
 		return nil, err
@@ -226,7 +427,7 @@ func BuildResponder(rCertPem, rPrivKeyPem, issuerCertPem []byte, nowT time.Time)
 
 	responder := &Responder{
 		rCert:          rCert,
-		rPrivKey:       rPrivKey,
+		rSigner:        signer,
 		rKeyFormat:     rKeyFormat,
 		rKeyAlg:        rKeyAlg,
 		issuerCert:     iCert,
@@ -290,55 +491,26 @@ func (r *Responder) verifyRCertBeforeDateNotFuture(nowT time.Time) error {
 	return nil
 }
 
-func (r *Responder) getPrivateKeyAsRSA() (*rsa.PrivateKey, bool) {
-	priv, ok := r.rPrivKey.(*rsa.PrivateKey)
-	if !ok {
-		return nil, false
-	}
-	return priv, true
-}
-
-func (r *Responder) getPrivateKeyAsECDSA() (*ecdsa.PrivateKey, bool) {
-	priv, ok := r.rPrivKey.(*ecdsa.PrivateKey)
-	if !ok {
-		return nil, false
-	}
-	return priv, true
-}
+// verifyRKeyPairValid verifies that the public key returned by the signer is
+// the pair of the responder certificate's public key. Because the private key
+// may be held by an external signer, only the public key is compared.
+func (r *Responder) verifyRKeyPairValid() error {
+	pub := r.rSigner.Public()
 
-func (r *Responder) verifyRKeyRSAPairValid() error {
-	priv, ok := r.getPrivateKeyAsRSA()
-	if !ok {
+	alg, ok := detectPubKeyAlgorithm(pub)
+	if !ok || alg != r.rKeyAlg {
 		return invalidPKIResourceError{responderKey, "key algorithm has been modified."}
 	}
 
-	pub, ok := r.rCert.PublicKey.(*rsa.PublicKey)
-	if !ok {
+	certAlg, _ := detectPubKeyAlgorithm(r.rCert.PublicKey)
+	if certAlg != alg {
 		return invalidPKIResourceError{
 			responderCert, "algorithm of private key does not matche the public key.",
 		}
 	}
 
-	if !priv.PublicKey.Equal(pub) {
-		return invalidPKIResourceError{responderKey, "private key is not pair of the public key."}
-	}
-	return nil
-}
-
-func (r *Responder) verifyRKeyECDSAPairValid() error {
-	priv, ok := r.getPrivateKeyAsECDSA()
-	if !ok {
-		return invalidPKIResourceError{responderKey, "key algorithm has been modified."}
-	}
-
-	pub, ok := r.rCert.PublicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return invalidPKIResourceError{
-			responderCert, "algorithm of private key does not matche the public key.",
-		}
-	}
-
-	if !priv.PublicKey.Equal(pub) {
+	eq, ok := pub.(interface{ Equal(x crypto.PublicKey) bool })
+	if !ok || !eq.Equal(r.rCert.PublicKey) {
 		return invalidPKIResourceError{responderKey, "private key is not pair of the public key."}
 	}
 
@@ -368,10 +540,8 @@ func (r *Responder) Verify(nowT time.Time) error {
 	}
 
 	switch r.rKeyAlg {
-	case AlgRSA:
-		err = r.verifyRKeyRSAPairValid()
-	case AlgECDSA:
-		err = r.verifyRKeyECDSAPairValid()
+	case AlgRSA, AlgECDSA, AlgEd25519:
+		err = r.verifyRKeyPairValid()
 	case AlgUnknown:
 		return invalidPKIResourceError{responderKey, "key algorithm has been modified."}
 	}
@@ -387,24 +557,51 @@ func (r *Responder) Verify(nowT time.Time) error {
 // The type of signature algorithm used depends on the specific
 // type of private key being used by the responder.
 func (r *Responder) SignCacheResponse(cache cache.ResponseCache) (cache.ResponseCache, error) {
-	var priv crypto.Signer
 	var err error
 
-	var ok bool
+	priv := r.rSigner
+	if err := r.verifySignerAlgorithm(); err != nil {
+		return cache, err
+	}
+
+	var res []byte
 	switch r.rKeyAlg {
-	case AlgRSA:
-		priv, ok = r.getPrivateKeyAsRSA()
-	case AlgECDSA:
-		priv, ok = r.getPrivateKeyAsECDSA()
+	case AlgEd25519:
+		// ocsp.CreateResponse does not support Ed25519
+		res, err = createOCSPResponse(r.issuerCert, r.rCert, cache.Template(), priv, nil)
+	default:
+		res, err = ocsp.CreateResponse(r.issuerCert, r.rCert, cache.Template(), priv)
 	}
-	if !ok {
-		return cache, invalidPKIResourceError{
-			responderKey,
-			"The private key algorithm has been modified since the configuration was set up.",
-		}
+	if err != nil {
+		return cache, err
 	}
 
-	res, err := ocsp.CreateResponse(r.issuerCert, r.rCert, cache.Template(), priv)
+	_, err = cache.SetResponse(res)
+	if err != nil {
+		return cache, err
+	}
+
+	return cache, nil
+}
+
+// SignNonceResponse signs a response of the template on demand, and echoes
+// the nonce extension of the request in the response extensions (RFC 8954).
+// The extensions are added to the response extensions after the nonce.
+// Unlike SignCacheResponse, the response is not cached because the nonce
+// differs for each request.
+func (r *Responder) SignNonceResponse(
+	template ocsp.Response, nonce pkix.Extension, extensions ...pkix.Extension,
+) ([]byte, error) {
+	return r.signResponse([]ocsp.Response{template}, append([]pkix.Extension{nonce}, extensions...))
+}
+
+// SignNegativeResponse signs the pre-signed cache.ResponseCache created by
+// cache.CreateNegativeResponseCache. The extensions are set to the response
+// extensions, such as the extended revoke extension.
+func (r *Responder) SignNegativeResponse(
+	cache cache.ResponseCache, extensions ...pkix.Extension,
+) (cache.ResponseCache, error) {
+	res, err := r.signResponse([]ocsp.Response{cache.Template()}, extensions)
 	if err != nil {
 		return cache, err
 	}
@@ -416,3 +613,34 @@ func (r *Responder) SignCacheResponse(cache cache.ResponseCache) (cache.Response
 
 	return cache, nil
 }
+
+// SignMultiResponse signs a response that has a SingleResponse for each template
+// on demand, for the request that has multiple CertIDs. The extensions are set
+// to the response extensions.
+func (r *Responder) SignMultiResponse(templates []ocsp.Response, extensions ...pkix.Extension) ([]byte, error) {
+	return r.signResponse(templates, extensions)
+}
+
+func (r *Responder) signResponse(templates []ocsp.Response, extensions []pkix.Extension) ([]byte, error) {
+	if err := r.verifySignerAlgorithm(); err != nil {
+		return nil, err
+	}
+
+	if r.AuthType == Delegation && len(templates) != 0 {
+		templates = append([]ocsp.Response{}, templates...)
+		templates[0].Certificate = r.rCert
+	}
+
+	return createOCSPResponses(r.issuerCert, r.rCert, templates, r.rSigner, extensions)
+}
+
+func (r *Responder) verifySignerAlgorithm() error {
+	alg, ok := detectPubKeyAlgorithm(r.rSigner.Public())
+	if !ok || alg != r.rKeyAlg {
+		return invalidPKIResourceError{
+			responderKey,
+			"The private key algorithm has been modified since the configuration was set up.",
+		}
+	}
+	return nil
+}
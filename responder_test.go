@@ -1,6 +1,13 @@
 package dyocsp
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"io"
 	"math/big"
 	"os"
 	"reflect"
@@ -166,7 +173,7 @@ func TestBuildResponder(t *testing.T) {
 				t.Errorf("Type of rCert is not x509.Certificate: %#v", reflect.TypeOf(responder.rCert))
 			}
 
-			if responder.rPrivKey == nil {
+			if responder.rSigner == nil {
 				t.Errorf("Issuer Private key is not set on Responder")
 			}
 
@@ -182,6 +189,256 @@ func TestBuildResponder(t *testing.T) {
 	}
 }
 
+func TestBuildResponder_KeyFormats(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		testCase string
+		// test data
+		rCertFile    string
+		rPrivKeyFile string
+		passphrase   []byte
+		// want
+		keyFormat KeyFormat
+		keyAlg    KeyAlg
+		errMsg    string
+	}{
+		{
+			"OK: rsa pkcs1 key",
+			"sub-ocsp-rsa.crt",
+			"sub-ocsp-rsa-pkcs1.key",
+			nil,
+			PKCS1,
+			AlgRSA,
+			"",
+		},
+		{
+			"OK: ec sec1 key with EC PARAMETERS block",
+			"sub-ocsp-ecparam.crt",
+			"sub-ocsp-ecparam-sec1.key",
+			nil,
+			SEC1,
+			AlgECDSA,
+			"",
+		},
+		{
+			"OK: rsa encrypted pkcs8 key (aes-256-cbc, hmacWithSHA256)",
+			"sub-ocsp-rsa.crt",
+			"sub-ocsp-rsa-pkcs8-enc.key",
+			[]byte("dyocsp-test"),
+			EncryptedPKCS8,
+			AlgRSA,
+			"",
+		},
+		{
+			"OK: ec encrypted pkcs8 key (aes-128-cbc, hmacWithSHA1)",
+			"sub-ocsp-ecparam.crt",
+			"sub-ocsp-ecparam-pkcs8-enc.key",
+			[]byte("dyocsp-test"),
+			EncryptedPKCS8,
+			AlgECDSA,
+			"",
+		},
+		{
+			"OK: ed25519 pkcs8 key",
+			"sub-ocsp-ed25519.crt",
+			"sub-ocsp-ed25519-pkcs8.key",
+			nil,
+			PKCS8,
+			AlgEd25519,
+			"",
+		},
+		{
+			"NG: ed25519 key is not pair of the rsa public key",
+			"sub-ocsp-rsa.crt",
+			"sub-ocsp-ed25519-pkcs8.key",
+			nil,
+			PKCS8,
+			AlgEd25519,
+			"invalid responder certificate: algorithm of private key does not matche the public key.",
+		},
+		{
+			"NG: encrypted pkcs8 key without passphrase",
+			"sub-ocsp-rsa.crt",
+			"sub-ocsp-rsa-pkcs8-enc.key",
+			nil,
+			EncryptedPKCS8,
+			AlgRSA,
+			"invalid private Key: passphrase is required for encrypted private key.",
+		},
+		{
+			"NG: encrypted pkcs8 key with wrong passphrase",
+			"sub-ocsp-rsa.crt",
+			"sub-ocsp-rsa-pkcs8-enc.key",
+			[]byte("wrong"),
+			EncryptedPKCS8,
+			AlgRSA,
+			"failed to decrypt a PKCS #8 encrypted private key: " +
+				"failed to decrypt private key, the passphrase may be incorrect",
+		},
+	}
+
+	for _, d := range data {
+		d := d
+		t.Run(d.testCase, func(t *testing.T) {
+			t.Parallel()
+
+			rCertFilePem, err := os.ReadFile("testdata/" + d.rCertFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rPrivKeyPem, err := os.ReadFile("testdata/" + d.rPrivKeyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			issuerCertPem, err := os.ReadFile("testdata/sub-ca-rsa.crt")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			responder, err := BuildResponder(
+				rCertFilePem, rPrivKeyPem, issuerCertPem, time.Date(2024, 8, 9, 12, 30, 0, 0, time.UTC),
+				WithKeyPassphrase(d.passphrase),
+			)
+
+			if d.errMsg == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				if err == nil || err.Error() != d.errMsg {
+					t.Fatalf("Expected '%#v' error msg but got: %#v", d.errMsg, err)
+				}
+				return
+			}
+
+			if responder.rKeyFormat != d.keyFormat {
+				t.Errorf("Expected key format %d but got: %d", d.keyFormat, responder.rKeyFormat)
+			}
+
+			if responder.rKeyAlg != d.keyAlg {
+				t.Errorf("Expected key algorithm %d but got: %d", d.keyAlg, responder.rKeyAlg)
+			}
+		})
+	}
+}
+
+// externalSigner hides the concrete private key type, as an HSM backed
+// crypto.Signer does.
+type externalSigner struct {
+	signer crypto.Signer
+}
+
+func (s externalSigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+func (s externalSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.signer.Sign(rand, digest, opts)
+}
+
+func TestBuildResponderWithSigner(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		testCase string
+		// test data
+		rCertFile    string
+		rPrivKeyFile string
+		// want
+		keyAlg KeyAlg
+		errMsg string
+	}{
+		{"OK: rsa signer", "sub-ocsp-rsa.crt", "sub-ocsp-rsa-pkcs8.key", AlgRSA, ""},
+		{"OK: ecdsa signer", "sub-ocsp-ecparam.crt", "sub-ocsp-ecparam-pkcs8.key", AlgECDSA, ""},
+		{"OK: ed25519 signer", "sub-ocsp-ed25519.crt", "sub-ocsp-ed25519-pkcs8.key", AlgEd25519, ""},
+		{
+			"NG: signer is not pair of the public key",
+			"sub-ocsp-rsa.crt",
+			"sub-ca-rsa-pkcs8.key",
+			AlgRSA,
+			"invalid private Key: private key is not pair of the public key.",
+		},
+		{
+			"NG: signer algorithm does not match the public key",
+			"sub-ocsp-rsa.crt",
+			"sub-ocsp-ecparam-pkcs8.key",
+			AlgECDSA,
+			"invalid responder certificate: algorithm of private key does not matche the public key.",
+		},
+	}
+
+	for _, d := range data {
+		d := d
+		t.Run(d.testCase, func(t *testing.T) {
+			t.Parallel()
+
+			rCertFilePem, err := os.ReadFile("testdata/" + d.rCertFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rPrivKeyPem, err := os.ReadFile("testdata/" + d.rPrivKeyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rPrivKey, err := parsePrivKeyPem(rPrivKeyPem, PKCS8, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			issuerCertPem, err := os.ReadFile("testdata/sub-ca-rsa.crt")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signer := externalSigner{rPrivKey.(crypto.Signer)}
+			responder, err := BuildResponderWithSigner(
+				rCertFilePem, signer, issuerCertPem, time.Date(2024, 8, 9, 12, 30, 0, 0, time.UTC),
+			)
+
+			if d.errMsg == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				if err == nil || err.Error() != d.errMsg {
+					t.Fatalf("Expected '%#v' error msg but got: %#v", d.errMsg, err)
+				}
+				return
+			}
+
+			if responder.rKeyFormat != ExternalSigner {
+				t.Errorf("Expected key format %d but got: %d", ExternalSigner, responder.rKeyFormat)
+			}
+
+			if responder.rKeyAlg != d.keyAlg {
+				t.Errorf("Expected key algorithm %d but got: %d", d.keyAlg, responder.rKeyAlg)
+			}
+
+			resCache, err := cache.CreatePreSignedResponseCache(
+				db.CertificateEntry{
+					Ca:        "ca",
+					Serial:    big.NewInt(1),
+					RevType:   "V",
+					ExpDate:   time.Date(2033, 8, 9, 12, 30, 0, 0, time.UTC),
+					CRLReason: db.NotRevoked,
+				},
+				time.Date(2023, 8, 9, 12, 30, 0, 0, time.UTC), time.Second*120,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = responder.SignCacheResponse(resCache)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
 func TestBuildResponder_IssuerKeyNameHashes(t *testing.T) {
 	t.Parallel()
 	data := []struct {
@@ -314,6 +571,29 @@ func testCreateDelegatedResponder(t *testing.T) *Responder {
 	return responder
 }
 
+func testCreateSelfSignedResponder(t *testing.T) *Responder {
+	t.Helper()
+
+	certPem, err := os.ReadFile("testdata/self-ecparam.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privKeyPem, err := os.ReadFile("testdata/self-ecparam.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responder, err := BuildResponder(
+		certPem, privKeyPem, certPem, time.Date(2024, 8, 9, 12, 30, 0, 0, time.UTC),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return responder
+}
+
 func TestResponder_SignCacheResponse(t *testing.T) {
 	t.Parallel()
 
@@ -418,3 +698,124 @@ func TestResponder_SignCacheResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestIssuerHash_Hash(t *testing.T) {
+	t.Parallel()
+
+	responder := testCreateDelegatedResponder(t)
+
+	data := []struct {
+		alg     crypto.Hash
+		wantLen int
+		wantOK  bool
+	}{
+		{crypto.SHA1, sha1.Size, true},
+		{crypto.SHA256, sha256.Size, true},
+		{crypto.SHA384, sha512.Size384, true},
+		{crypto.SHA512, sha512.Size, true},
+		{crypto.MD5, 0, false},
+	}
+
+	for _, d := range data {
+		for _, iHash := range []IssuerHash{responder.IssuerNameHash, responder.IssuerKeyHash} {
+			hash, ok := iHash.Hash(d.alg)
+			if ok != d.wantOK {
+				t.Fatalf("%s: Expected %t but got: %t", d.alg, d.wantOK, ok)
+			}
+			if len(hash) != d.wantLen {
+				t.Errorf("%s: Expected hash length %d but got: %d", d.alg, d.wantLen, len(hash))
+			}
+		}
+	}
+
+	nameHash, _ := responder.IssuerNameHash.Hash(crypto.SHA256)
+	want := sha256.Sum256(responder.issuerCert.RawSubject)
+	if !reflect.DeepEqual(nameHash, want[:]) {
+		t.Errorf("Expected IssuerNameHash.SHA256 %x but got: %x", want, nameHash)
+	}
+}
+
+func TestResponder_SignCacheResponse_Ed25519(t *testing.T) {
+	t.Parallel()
+
+	rCertFilePem, err := os.ReadFile("testdata/sub-ocsp-ed25519.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rPrivKeyPem, err := os.ReadFile("testdata/sub-ocsp-ed25519-pkcs8.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuerCertPem, err := os.ReadFile("testdata/sub-ca-rsa.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responder, err := BuildResponder(
+		rCertFilePem, rPrivKeyPem, issuerCertPem, time.Date(2024, 8, 9, 12, 30, 0, 0, time.UTC),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, ok := new(big.Int).SetString("72344BF34067BBA31EF44587CBFB16631332CD23", db.SerialBase)
+	if !ok {
+		t.Fatal("String could not be *big.Int.")
+	}
+
+	resCache, err := cache.CreatePreSignedResponseCache(
+		db.CertificateEntry{
+			Ca:        "ca",
+			Serial:    serial,
+			RevType:   "R",
+			ExpDate:   time.Date(2033, 8, 9, 12, 30, 0, 0, time.UTC),
+			RevDate:   time.Date(2033, 8, 9, 11, 30, 0, 0, time.UTC),
+			CRLReason: db.KeyCompromise,
+		},
+		time.Date(2023, 8, 9, 12, 30, 0, 0, time.UTC), time.Second*120,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resCache, err = responder.SignCacheResponse(resCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// golang.org/x/crypto/ocsp can not verify Ed25519 signature
+	res, err := ocsp.ParseResponse(resCache.Response(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resASN1 responseASN1
+	if _, err := asn1.Unmarshal(resCache.Response(), &resASN1); err != nil {
+		t.Fatal(err)
+	}
+	var basicRes basicResponse
+	if _, err := asn1.Unmarshal(resASN1.Response.Response, &basicRes); err != nil {
+		t.Fatal(err)
+	}
+	if !basicRes.SignatureAlgorithm.Algorithm.Equal(oidSignatureEd25519) {
+		t.Errorf("Expected Ed25519 signature algorithm but got: %s", basicRes.SignatureAlgorithm.Algorithm)
+	}
+
+	pub, ok := responder.rCert.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		t.Fatal("Public key is not Ed25519.")
+	}
+	if !ed25519.Verify(pub, res.TBSResponseData, res.Signature) {
+		t.Error("Ed25519 signature is not valid.")
+	}
+
+	if res.Status != ocsp.Revoked || res.RevocationReason != ocsp.KeyCompromise {
+		t.Errorf("Unexpected status %d and reason: %d", res.Status, res.RevocationReason)
+	}
+
+	if res.SerialNumber.Cmp(serial) != 0 {
+		t.Errorf("Expected serial %x but got: %x", serial, res.SerialNumber)
+	}
+}
@@ -126,6 +126,27 @@ extra_ecparam_sign_ocsp_responder_by_sub_ca() {
     -days 36500
 }
 
+extra_ed25519_sign_ocsp_responder_by_sub_ca() {
+  echo -e "\nSigning Ed25519 OCSP Responder by Sub CA..."
+
+  openssl genpkey -algorithm ed25519 \
+    -out "${SUB_CA_HOME}/private/sub-ocsp-ed25519.key"
+
+  openssl req \
+    -new \
+    -key "${SUB_CA_HOME}/private/sub-ocsp-ed25519.key" \
+    -subj "/C=${DUMMY_C}/O=${DUMMY_O}/CN=Sub CA Ed25519 OCSP Responder" \
+    -out "${SUB_CA_HOME}/sub-ocsp-ed25519.csr"
+
+  openssl ca \
+    -batch \
+    -config "$SUB_CA_CONF" \
+    -in "${SUB_CA_HOME}/sub-ocsp-ed25519.csr" \
+    -out "${SUB_CA_HOME}/sub-ocsp-ed25519.crt" \
+    -extensions ocsp_ext \
+    -days 36500
+}
+
 extra_sign_expired_ocsp_responder_by_sub_ca() {
   echo -e "\nSigning OCSP Responder by Sub CA..."
 
@@ -280,6 +301,7 @@ main() {
   sign_ocsp_responder_by_root_ca
   sign_ocsp_responder_by_sub_ca
   extra_ecparam_sign_ocsp_responder_by_sub_ca
+  extra_ed25519_sign_ocsp_responder_by_sub_ca
   extra_sign_expired_ocsp_responder_by_sub_ca
   extra_sign_future_ocsp_responder_by_sub_ca
 
@@ -0,0 +1,25 @@
+#!/bin/bash
+
+# Imports the responder keys of ./testdata into a SoftHSM token for the
+# PKCS #11 tests. Run the tests with:
+#   SOFTHSM2_CONF=./tmp-softhsm/softhsm2.conf \
+#   DYOCSP_TEST_PKCS11_MODULE=/usr/lib/softhsm/libsofthsm2.so go test ./pkg/hsm/...
+
+set -ex
+
+TOKEN_DIR="$(pwd)/tmp-softhsm"
+TOKEN_LABEL="dyocsp-test"
+PIN="1234"
+SO_PIN="12345678"
+
+mkdir -p "$TOKEN_DIR/tokens"
+echo "directories.tokendir = $TOKEN_DIR/tokens" > "$TOKEN_DIR/softhsm2.conf"
+export SOFTHSM2_CONF="$TOKEN_DIR/softhsm2.conf"
+
+softhsm2-util --init-token --free --label "$TOKEN_LABEL" --pin "$PIN" --so-pin "$SO_PIN"
+
+softhsm2-util --import ./testdata/sub-ocsp-rsa-pkcs8.key \
+  --token "$TOKEN_LABEL" --label sub-ocsp-rsa --id 01 --pin "$PIN"
+
+softhsm2-util --import ./testdata/sub-ocsp-ecparam-pkcs8.key \
+  --token "$TOKEN_LABEL" --label sub-ocsp-ecparam --id 02 --pin "$PIN"
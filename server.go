@@ -1,7 +1,9 @@
 package dyocsp
 
 import (
+	"crypto/tls"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/yuxki/dyocsp/pkg/config"
@@ -12,6 +14,15 @@ func CreateHTTPServer(
 	cfg config.DyOCSPConfig,
 	handler http.Handler,
 ) *http.Server {
+	// HTTP/2 is negotiated by ALPN over TLS, and is accepted with prior
+	// knowledge (h2c) over plain HTTP.
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	if cfg.HTTP2 {
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+	}
+
 	return &http.Server{
 		Addr:              host,
 		Handler:           handler,
@@ -20,5 +31,49 @@ func CreateHTTPServer(
 		IdleTimeout:       time.Second * time.Duration(cfg.WriteTimeout),
 		ReadHeaderTimeout: time.Second * time.Duration(cfg.ReadHeaderTimeout),
 		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		Protocols:         protocols,
 	}
 }
+
+// CreateHTTPSServer creates a server same as CreateHTTPServer, which serves
+// over TLS with the tlsConfig. The server should be started by
+// ListenAndServeTLS with empty file names.
+func CreateHTTPSServer(
+	host string,
+	cfg config.DyOCSPConfig,
+	handler http.Handler,
+	tlsConfig *tls.Config,
+) *http.Server {
+	server := CreateHTTPServer(host, cfg, handler)
+	server.TLSConfig = tlsConfig
+
+	return server
+}
+
+// RoutePaths returns a handler that passes the requests of the paths to their
+// handlers, and the other requests to next. The paths are matched exactly, except
+// that a path ending with a slash matches all the paths under it, and the longest
+// one is used. It is used to serve the endpoints for the operators on the same
+// listener as the OCSP responder. http.ServeMux is not used, because it redirects
+// the paths of the GET requests that contain "//" in base64.
+func RoutePaths(next http.Handler, routes map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h, ok := routes[r.URL.Path]; ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		var matched string
+		for path := range routes {
+			if strings.HasSuffix(path, "/") && strings.HasPrefix(r.URL.Path, path) && len(path) > len(matched) {
+				matched = path
+			}
+		}
+		if matched != "" {
+			routes[matched].ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,45 @@
+package dyocsp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoutePaths(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	health := NewHealthHandler(NewReadiness(), WithMinEntries(0))
+	handler := RoutePaths(next, map[string]http.Handler{
+		HealthzPath: health,
+		ReadyzPath:  health,
+		MetricsPath: NewMetrics(),
+		"/sub/": http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		}),
+	})
+
+	data := []struct {
+		path string
+		code int
+	}{
+		{HealthzPath, http.StatusOK},
+		{ReadyzPath, http.StatusOK},
+		{MetricsPath, http.StatusOK},
+		{"/sub/path", http.StatusAccepted},
+		{"/sub", http.StatusTeapot},
+		// The GET request of OCSP, which contains "//"
+		{"/MEMwQTA//MD0wOzAJBgUrDgMCGgUABBQ", http.StatusTeapot},
+	}
+
+	for _, d := range data {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, d.path, nil))
+		if rec.Code != d.code {
+			t.Errorf("%s: Expected status code is %d but got: %d", d.path, d.code, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package dyocsp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/yuxki/dyocsp/pkg/config"
+)
+
+// DefaultCertReloadInterval is the default interval of checking the changes of
+// the certificate files.
+const DefaultCertReloadInterval = time.Minute
+
+var errNoClientCA = errors.New("no certificate is found in the client CA file")
+
+// CertReloader loads the TLS certificate and key of the server, and reloads them
+// when the files are changed. The files are checked at most once per interval
+// in the TLS handshakes, so that the renewed certificate is served without restart.
+type CertReloader struct {
+	certFile  string
+	keyFile   string
+	interval  time.Duration
+	logger    *zerolog.Logger
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+// CertReloaderOption is type of an functional option for dyocsp.CertReloader.
+type CertReloaderOption func(*CertReloader)
+
+// WithReloadInterval sets the interval of checking the changes of the files.
+// Default value is DefaultCertReloadInterval (1 minute). If 0 or less than 0 is set,
+// the default value is used.
+func WithReloadInterval(interval time.Duration) func(*CertReloader) {
+	return func(c *CertReloader) {
+		c.interval = interval
+	}
+}
+
+// WithReloaderLogger sets logger. If not set, global logger is used.
+func WithReloaderLogger(logger *zerolog.Logger) func(*CertReloader) {
+	return func(c *CertReloader) {
+		c.logger = logger
+	}
+}
+
+// NewCertReloader creates a new instance of dyocsp.CertReloader, and loads the
+// certificate and key from the PEM files.
+func NewCertReloader(certFile, keyFile string, opts ...CertReloaderOption) (*CertReloader, error) {
+	reloader := CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: DefaultCertReloadInterval,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(&reloader)
+	}
+
+	if reloader.interval <= 0 {
+		reloader.interval = DefaultCertReloadInterval
+	}
+
+	if reloader.logger == nil {
+		reloader.logger = &log.Logger
+	}
+
+	modTime, err := reloader.lastModified()
+	if err != nil {
+		return nil, err
+	}
+	if err := reloader.load(modTime); err != nil {
+		return nil, err
+	}
+
+	return &reloader, nil
+}
+
+// lastModified returns the latest modification time of the certificate and key files.
+func (c *CertReloader) lastModified() (time.Time, error) {
+	certStat, err := os.Stat(c.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyStat, err := os.Stat(c.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if keyStat.ModTime().After(certStat.ModTime()) {
+		return keyStat.ModTime(), nil
+	}
+	return certStat.ModTime(), nil
+}
+
+func (c *CertReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	c.cert = &cert
+	c.modTime = modTime
+	c.checkedAt = c.now()
+
+	return nil
+}
+
+// GetCertificate returns the loaded certificate. It is set to
+// tls.Config.GetCertificate. If the files have been changed since they were
+// loaded, it reloads them. When the reloading fails, the loaded certificate is
+// returned, so that the server keeps serving until the files are fixed.
+func (c *CertReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.now().Sub(c.checkedAt) < c.interval {
+		return c.cert, nil
+	}
+	c.checkedAt = c.now()
+
+	modTime, err := c.lastModified()
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to check the TLS certificate files.")
+		return c.cert, nil
+	}
+	if modTime.Equal(c.modTime) {
+		return c.cert, nil
+	}
+
+	if err := c.load(modTime); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to reload the TLS certificate, the loaded one is used.")
+		return c.cert, nil
+	}
+	c.logger.Info().Msgf("TLS certificate is reloaded: %s", c.certFile)
+
+	return c.cert, nil
+}
+
+// tlsVersions are the supported minimum TLS versions of the configuration.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// CreateTLSConfig creates a tls.Config that serves the certificate of the reloader.
+// If cfg.TLSClientCA is set, the clients must present certificates issued by
+// the CAs in the file (mutual TLS).
+func CreateTLSConfig(cfg config.DyOCSPConfig, reloader *CertReloader) (*tls.Config, error) {
+	minVersion, ok := tlsVersions[cfg.TLSMinVersion]
+	if !ok {
+		minVersion = tls.VersionTLS12
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if cfg.TLSClientCA != "" {
+		pem, err := os.ReadFile(cfg.TLSClientCA)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: %s", errNoClientCA, cfg.TLSClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
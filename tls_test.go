@@ -0,0 +1,301 @@
+package dyocsp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yuxki/dyocsp/pkg/config"
+)
+
+type testTLSCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPem []byte
+	keyPem  []byte
+}
+
+// testCreateTLSCert creates a certificate signed by the parent. If the parent is
+// nil, the certificate is a self-signed CA certificate.
+func testCreateTLSCert(t *testing.T, cn string, parent *testTLSCert) testTLSCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	parentCert, parentKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testTLSCert{
+		cert:    cert,
+		key:     key,
+		certPem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPem:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+func testWriteTLSCert(t *testing.T, dir string, cert testTLSCert) (certFile, keyFile string) {
+	t.Helper()
+
+	certFile = filepath.Join(dir, "server.crt")
+	keyFile = filepath.Join(dir, "server.key")
+	if err := os.WriteFile(certFile, cert.certPem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, cert.keyPem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestCertReloader_GetCertificate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	first := testCreateTLSCert(t, "first", nil)
+	certFile, keyFile := testWriteTLSCert(t, dir, first)
+
+	reloader, err := NewCertReloader(certFile, keyFile, WithReloadInterval(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nowT := time.Now()
+	reloader.now = func() time.Time { return nowT }
+	reloader.checkedAt = nowT
+
+	serialOf := func() *big.Int {
+		t.Helper()
+		cert, err := reloader.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert.Leaf.SerialNumber
+	}
+
+	// The files are renewed
+	second := testCreateTLSCert(t, "second", nil)
+	testWriteTLSCert(t, dir, second)
+	later := time.Now().Add(time.Hour)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The files are not checked until the interval passes
+	if serialOf().Cmp(first.cert.SerialNumber) != 0 {
+		t.Error("Expected the first certificate before the interval passes")
+	}
+
+	nowT = nowT.Add(time.Minute)
+	if serialOf().Cmp(second.cert.SerialNumber) != 0 {
+		t.Error("Expected the renewed certificate after the interval passes")
+	}
+
+	// The broken files are not loaded
+	if err := os.WriteFile(keyFile, []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(keyFile, later.Add(time.Hour), later.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	nowT = nowT.Add(time.Minute)
+	if serialOf().Cmp(second.cert.SerialNumber) != 0 {
+		t.Error("Expected the loaded certificate when the files are broken")
+	}
+}
+
+func TestCreateTLSConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca := testCreateTLSCert(t, "ca", nil)
+	certFile, keyFile := testWriteTLSCert(t, dir, testCreateTLSCert(t, "server", &ca))
+
+	clientCA := filepath.Join(dir, "client-ca.crt")
+	if err := os.WriteFile(clientCA, ca.certPem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []struct {
+		testcase   string
+		cfg        config.DyOCSPConfig
+		minVersion uint16
+		clientAuth tls.ClientAuthType
+		err        error
+	}{
+		{"TLS 1.2", config.DyOCSPConfig{TLSMinVersion: "1.2"}, tls.VersionTLS12, tls.NoClientCert, nil},
+		{"TLS 1.3", config.DyOCSPConfig{TLSMinVersion: "1.3"}, tls.VersionTLS13, tls.NoClientCert, nil},
+		{
+			"mutual TLS",
+			config.DyOCSPConfig{TLSMinVersion: "1.2", TLSClientCA: clientCA},
+			tls.VersionTLS12, tls.RequireAndVerifyClientCert, nil,
+		},
+		{
+			"no certificate in client CA",
+			config.DyOCSPConfig{TLSMinVersion: "1.2", TLSClientCA: keyFile},
+			0, 0, errNoClientCA,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			tlsConfig, err := CreateTLSConfig(d.cfg, reloader)
+			if !errors.Is(err, d.err) {
+				t.Fatalf("Expected error is %#v but got: %#v", d.err, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if tlsConfig.MinVersion != d.minVersion {
+				t.Errorf("Expected min version is %x but got: %x", d.minVersion, tlsConfig.MinVersion)
+			}
+			if tlsConfig.ClientAuth != d.clientAuth {
+				t.Errorf("Expected client auth is %v but got: %v", d.clientAuth, tlsConfig.ClientAuth)
+			}
+		})
+	}
+}
+
+func TestCreateHTTPSServer(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca := testCreateTLSCert(t, "ca", nil)
+	client := testCreateTLSCert(t, "client", &ca)
+	certFile, keyFile := testWriteTLSCert(t, dir, testCreateTLSCert(t, "server", &ca))
+
+	clientCA := filepath.Join(dir, "client-ca.crt")
+	if err := os.WriteFile(clientCA, ca.certPem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DyOCSPConfig{
+		ReadHeaderTimeout: 10,
+		TLSMinVersion:     "1.2",
+		TLSClientCA:       clientCA,
+		HTTP2:             true,
+	}
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig, err := CreateTLSConfig(cfg, reloader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	server := CreateHTTPSServer("127.0.0.1:0", cfg, handler, tlsConfig)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = server.ServeTLS(ln, "", "")
+	}()
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	url := "https://" + ln.Addr().String()
+
+	data := []struct {
+		testcase   string
+		clientCert bool
+		ok         bool
+	}{
+		{"client certificate is verified", true, true},
+		{"client certificate is required", false, false},
+	}
+
+	for _, d := range data {
+		clientTLS := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+		if d.clientCert {
+			clientTLS.Certificates = []tls.Certificate{{
+				Certificate: [][]byte{client.cert.Raw},
+				PrivateKey:  client.key,
+			}}
+		}
+		transport := &http.Transport{TLSClientConfig: clientTLS, ForceAttemptHTTP2: true}
+
+		res, err := (&http.Client{Transport: transport}).Get(url)
+		if !d.ok {
+			if err == nil {
+				_ = res.Body.Close()
+				t.Errorf("%s: Expected error but got nil", d.testcase)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", d.testcase, err)
+		}
+		_ = res.Body.Close()
+
+		if res.ProtoMajor != 2 {
+			t.Errorf("%s: Expected HTTP/2 but got: %s", d.testcase, res.Proto)
+		}
+	}
+}
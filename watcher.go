@@ -0,0 +1,56 @@
+package dyocsp
+
+import (
+	"context"
+	"time"
+)
+
+// CADBWatcher is an interface that represents a watcher of a database.
+// WatchUpdates sends to the channel when the database is updated, until the
+// context is done or an error occurs.
+type CADBWatcher interface {
+	WatchUpdates(ctx context.Context, updated chan<- struct{}) error
+}
+
+// watchUpdates refreshes the caches when the watcher notifies the update of
+// the database, until the context is done. When the watcher fails, it watches
+// the database again after the retry wait. The updates missed in the meantime
+// are reconciled by the next batch.
+func (c *CacheBatch) watchUpdates(ctx context.Context) {
+	logger := c.logger.With().Str("feed", "watch").Logger()
+	ctx = logger.WithContext(ctx)
+
+	updated := make(chan struct{})
+	for {
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- c.watcher.WatchUpdates(ctx, updated)
+		}()
+		logger.Info().Msg("Watching the database.")
+
+		var err error
+	watch:
+		for {
+			select {
+			case <-updated:
+				logger.Info().Msg("Database is updated, refreshing the caches.")
+				if err := c.Refresh(ctx); err != nil && ctx.Err() == nil {
+					logger.Error().Err(err).Msg("Out-of-cycle cache refresh failed, the served caches are kept.")
+				}
+			case err = <-errCh:
+				break watch
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Error().Err(err).Msgf("Watcher stopped, watching again in %v.", c.changeFeedRetry)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.changeFeedRetry):
+		}
+	}
+}
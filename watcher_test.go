@@ -0,0 +1,109 @@
+package dyocsp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yuxki/dyocsp/pkg/cache"
+	"github.com/yuxki/dyocsp/pkg/date"
+	"github.com/yuxki/dyocsp/pkg/db"
+)
+
+// StubCADBWatcher notifies the updates sent to its trigger channel. If failFirst
+// is true, the first watch fails.
+type StubCADBWatcher struct {
+	trigger   chan struct{}
+	failFirst bool
+	watches   int
+}
+
+func (s *StubCADBWatcher) WatchUpdates(ctx context.Context, updated chan<- struct{}) error {
+	s.watches++
+	if s.failFirst && s.watches == 1 {
+		return errStubWatch
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.trigger:
+			select {
+			case updated <- struct{}{}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+func TestCacheBatch_Run_Watcher(t *testing.T) {
+	t.Parallel()
+
+	data := []struct {
+		testcase  string
+		failFirst bool
+	}{
+		{"watch updates", false},
+		{"watch again after failure", true},
+	}
+
+	for _, d := range data {
+		t.Run(d.testcase, func(t *testing.T) {
+			t.Parallel()
+
+			client := &StubScriptedCADBClient{scans: [][]db.IntermidiateEntry{
+				testIntermidiateEntries("01"),
+				nil,
+				testIntermidiateEntries("01", "02"),
+			}}
+			watcher := &StubCADBWatcher{trigger: make(chan struct{}), failFirst: d.failFirst}
+			store := cache.NewResponseCacheStore()
+			notifyCh := make(chan struct{}, 1)
+			batch, err := NewCacheBatch(
+				"test-ca",
+				store,
+				client,
+				testCreateDelegatedResponder(t),
+				date.NowGMT(),
+				WithIntervalSec(60),
+				WithUpdatedNotifyChan(notifyCh),
+				WithWatcher(watcher),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			batch.changeFeedRetry = 10 * time.Millisecond
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go batch.Run(ctx)
+			<-notifyCh
+
+			// The failed refresh keeps the served caches
+			watcher.trigger <- struct{}{}
+			// The trigger is received after the refresh of the previous update
+			watcher.trigger <- struct{}{}
+			testWaitForCache(t, store, 2, func(_ *cache.ResponseCache, ok bool) bool {
+				return ok
+			})
+			testWaitForCache(t, store, 1, func(_ *cache.ResponseCache, ok bool) bool {
+				return ok
+			})
+
+			// The generation is stored after the caches are updated
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				gen, ok := batch.Generation()
+				if ok && gen.Refreshes == 1 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("Expected refreshes is 1 but got: %d", gen.Refreshes)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}